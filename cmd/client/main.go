@@ -12,6 +12,8 @@ import (
 	pb "github.com/truongtu268/project_maker/proto/user"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func main() {
@@ -31,17 +33,26 @@ func main() {
 	updateEmail := updateCmd.String("email", "", "New email (optional)")
 	updatePassword := updateCmd.String("password", "", "New password (optional)")
 	updateFullName := updateCmd.String("fullname", "", "New full name (optional)")
+	updateVersion := updateCmd.Int64("version", 0, "Only update if the user is at this version (optional)")
 
 	deleteCmd := flag.NewFlagSet("delete", flag.ExitOnError)
 	deleteUserID := deleteCmd.Int64("id", 0, "ID of the user to delete")
+	deleteVersion := deleteCmd.Int64("version", 0, "Only delete if the user is at this version (optional)")
 
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
 	listPage := listCmd.Int("page", 1, "Page number")
 	listPageSize := listCmd.Int("pagesize", 10, "Page size")
+	listQuery := listCmd.String("query", "", "Search username, email and full name (optional)")
+	listSortBy := listCmd.String("sortby", "", "Sort by id, username, email, full_name or created_at (optional)")
+	listSortOrder := listCmd.String("sortorder", "", "Sort order, asc or desc (optional)")
+
+	loginCmd := flag.NewFlagSet("login", flag.ExitOnError)
+	loginName := loginCmd.String("login", "", "Username or email")
+	loginPassword := loginCmd.String("password", "", "Password")
 
 	// Check if a command was provided
 	if len(os.Args) < 2 {
-		fmt.Println("Expected 'create', 'get', 'update', 'delete', or 'list' subcommand")
+		fmt.Println("Expected 'create', 'get', 'update', 'delete', 'list', or 'login' subcommand")
 		os.Exit(1)
 	}
 
@@ -60,6 +71,11 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Attach the access token obtained from the login command, if any
+	if token := os.Getenv("ACCESS_TOKEN"); token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
 	// Parse and execute the appropriate command
 	switch os.Args[1] {
 	case "create":
@@ -98,7 +114,31 @@ func main() {
 				updateCmd.PrintDefaults()
 				os.Exit(1)
 			}
-			updateUser(ctx, client, *updateUserID, *updateUsername, *updateEmail, *updatePassword, *updateFullName)
+			// Only send the fields given on the command line, so that passing an
+			// empty value clears it
+			updateFlags := map[string]string{
+				"username": "username",
+				"email":    "email",
+				"password": "password",
+				"fullname": "full_name",
+			}
+			var paths []string
+			updateCmd.Visit(func(f *flag.Flag) {
+				if path, ok := updateFlags[f.Name]; ok {
+					paths = append(paths, path)
+				}
+			})
+			if len(paths) == 0 {
+				updateCmd.PrintDefaults()
+				os.Exit(1)
+			}
+			values := &pb.UserUpdate{
+				Username: *updateUsername,
+				Email:    *updateEmail,
+				Password: *updatePassword,
+				FullName: *updateFullName,
+			}
+			updateUser(ctx, client, *updateUserID, *updateVersion, values, paths)
 		}
 
 	case "delete":
@@ -111,7 +151,7 @@ func main() {
 				deleteCmd.PrintDefaults()
 				os.Exit(1)
 			}
-			deleteUser(ctx, client, *deleteUserID)
+			deleteUser(ctx, client, *deleteUserID, *deleteVersion)
 		}
 
 	case "list":
@@ -120,11 +160,24 @@ func main() {
 			log.Fatalf("Failed to parse list command: %v", err)
 		}
 		if listCmd.Parsed() {
-			listUsers(ctx, client, *listPage, *listPageSize)
+			listUsers(ctx, client, *listPage, *listPageSize, *listQuery, *listSortBy, *listSortOrder)
+		}
+
+	case "login":
+		err = loginCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Failed to parse login command: %v", err)
+		}
+		if loginCmd.Parsed() {
+			if *loginName == "" || *loginPassword == "" {
+				loginCmd.PrintDefaults()
+				os.Exit(1)
+			}
+			login(ctx, client, *loginName, *loginPassword)
 		}
 
 	default:
-		fmt.Println("Expected 'create', 'get', 'update', 'delete', or 'list' subcommand")
+		fmt.Println("Expected 'create', 'get', 'update', 'delete', 'list', or 'login' subcommand")
 		os.Exit(1)
 	}
 }
@@ -150,13 +203,12 @@ func getUser(ctx context.Context, client pb.UserServiceClient, id int64) {
 	log.Printf("User: %v", resp.User)
 }
 
-func updateUser(ctx context.Context, client pb.UserServiceClient, id int64, username, email, password, fullName string) {
+func updateUser(ctx context.Context, client pb.UserServiceClient, id, version int64, values *pb.UserUpdate, paths []string) {
 	resp, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{
-		Id:       id,
-		Username: &username,
-		Email:    &email,
-		Password: &password,
-		FullName: &fullName,
+		Id:              id,
+		User:            values,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: paths},
+		ExpectedVersion: version,
 	})
 	if err != nil {
 		log.Fatalf("Could not update user: %v", err)
@@ -164,24 +216,40 @@ func updateUser(ctx context.Context, client pb.UserServiceClient, id int64, user
 	log.Printf("User updated: %v", resp.User)
 }
 
-func deleteUser(ctx context.Context, client pb.UserServiceClient, id int64) {
-	resp, err := client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: id})
+func deleteUser(ctx context.Context, client pb.UserServiceClient, id, version int64) {
+	resp, err := client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: id, ExpectedVersion: version})
 	if err != nil {
 		log.Fatalf("Could not delete user: %v", err)
 	}
 	log.Printf("User deleted, success: %v", resp.Success)
 }
 
-func listUsers(ctx context.Context, client pb.UserServiceClient, page, pageSize int) {
+func listUsers(ctx context.Context, client pb.UserServiceClient, page, pageSize int, query, sortBy, sortOrder string) {
 	resp, err := client.ListUsers(ctx, &pb.ListUsersRequest{
-		Page:     int32(page),
-		PageSize: int32(pageSize),
+		Page:      int32(page),
+		PageSize:  int32(pageSize),
+		Query:     query,
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
 	})
 	if err != nil {
 		log.Fatalf("Could not list users: %v", err)
 	}
-	log.Printf("Total users: %d", resp.TotalCount)
+	log.Printf("Total users: %d", resp.GetTotalCount())
 	for i, user := range resp.Users {
 		log.Printf("[%d] %v", i+1, user)
 	}
 }
+
+func login(ctx context.Context, client pb.UserServiceClient, login, password string) {
+	resp, err := client.Login(ctx, &pb.LoginRequest{
+		Login:    login,
+		Password: password,
+	})
+	if err != nil {
+		log.Fatalf("Could not log in: %v", err)
+	}
+	log.Printf("Logged in as: %v", resp.User)
+	log.Printf("Access token (expires in %ds): %s", resp.ExpiresIn, resp.AccessToken)
+	log.Printf("Refresh token: %s", resp.RefreshToken)
+}
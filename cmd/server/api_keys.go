@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateAPIKey implements the CreateAPIKey RPC method
+func (s *server) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid expires_at: %v", err)
+		}
+		expiresAt = &t
+	}
+
+	apiKey, key, err := s.apiKeyService.CreateAPIKey(ctx, req.UserId, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CreateAPIKeyResponse{
+		ApiKey: toPBAPIKey(apiKey),
+		Key:    key,
+	}, nil
+}
+
+// ListAPIKeys implements the ListAPIKeys RPC method
+func (s *server) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	apiKeys, err := s.apiKeyService.ListAPIKeys(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	pbAPIKeys := make([]*pb.APIKey, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		pbAPIKeys[i] = toPBAPIKey(apiKey)
+	}
+
+	return &pb.ListAPIKeysResponse{
+		ApiKeys: pbAPIKeys,
+	}, nil
+}
+
+// RevokeAPIKey implements the RevokeAPIKey RPC method
+func (s *server) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.apiKeyService.RevokeAPIKey(ctx, req.UserId, req.KeyId); err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeAPIKeyResponse{
+		Success: true,
+	}, nil
+}
+
+// toPBAPIKey converts a domain API key into its protobuf representation
+func toPBAPIKey(k *user.APIKey) *pb.APIKey {
+	scopes := make([]string, len(k.Scopes))
+	for i, scope := range k.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	pbAPIKey := &pb.APIKey{
+		Id:        k.ID,
+		UserId:    k.UserID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    scopes,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+	}
+	if k.ExpiresAt != nil {
+		pbAPIKey.ExpiresAt = k.ExpiresAt.Format(time.RFC3339)
+	}
+	if k.LastUsedAt != nil {
+		pbAPIKey.LastUsedAt = k.LastUsedAt.Format(time.RFC3339)
+	}
+	return pbAPIKey
+}
+
+// incomingHeaderMatcher forwards the X-Api-Key and X-Organization-Id headers
+// to gRPC under the metadata keys read by the auth interceptor and keeps the
+// default mapping for everything else
+func incomingHeaderMatcher(key string) (string, bool) {
+	for _, header := range []string{auth.APIKeyHeader, auth.OrganizationHeader} {
+		if strings.EqualFold(key, header) {
+			return header, true
+		}
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/service"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Login implements the Login RPC method
+func (s *server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.TokenResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	pair, err := s.authService.Login(ctx, req.Organization, req.Login, req.Password, req.Device)
+	if err != nil {
+		return nil, err
+	}
+
+	return toTokenResponse(pair), nil
+}
+
+// RefreshToken implements the RefreshToken RPC method
+func (s *server) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.TokenResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	pair, err := s.authService.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return toTokenResponse(pair), nil
+}
+
+// Logout implements the Logout RPC method
+func (s *server) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.authService.Logout(ctx, req.RefreshToken); err != nil {
+		return nil, err
+	}
+
+	return &pb.LogoutResponse{
+		Success: true,
+	}, nil
+}
+
+// toTokenResponse converts a token pair into its protobuf representation
+func toTokenResponse(pair *service.TokenPair) *pb.TokenResponse {
+	if pair.MFAToken != "" {
+		return &pb.TokenResponse{
+			MfaRequired: true,
+			MfaToken:    pair.MFAToken,
+		}
+	}
+
+	return &pb.TokenResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(pair.ExpiresIn / time.Second),
+		User:         toPBUser(pair.User),
+	}
+}
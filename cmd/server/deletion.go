@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/service"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RestoreUser implements the RestoreUser RPC method
+func (s *server) RestoreUser(ctx context.Context, req *pb.RestoreUserRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.userService.RestoreUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// PurgeUser implements the PurgeUser RPC method
+func (s *server) PurgeUser(ctx context.Context, req *pb.PurgeUserRequest) (*pb.PurgeUserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err := s.userService.PurgeUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.PurgeUserResponse{
+		Success: true,
+	}, nil
+}
+
+// startPurgeWorker periodically hard deletes users that have been soft deleted
+// for longer than the retention period. It stops when ctx is canceled.
+func startPurgeWorker(ctx context.Context, userService *service.UserService, retention, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Deleted user purge is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := userService.PurgeDeletedUsers(auth.SystemContext(ctx), retention)
+			if err != nil {
+				log.Printf("Failed to purge deleted users: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d deleted users", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ifMatchMetadataKey is the metadata key the gateway forwards the HTTP
+// If-Match header under
+const ifMatchMetadataKey = runtime.MetadataPrefix + "if-match"
+
+// etagMetadataKey is the response header metadata key carrying the version of
+// the returned user, exposed as the HTTP ETag header by the gateway
+const etagMetadataKey = "etag"
+
+// formatETag formats a user version as a strong HTTP entity tag
+func formatETag(version int64) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(version, 10))
+}
+
+// parseETag parses an entity tag produced by formatETag. Weak tags are
+// accepted and "*" matches any version, which is reported as 0.
+func parseETag(etag string) (int64, error) {
+	etag = strings.TrimSpace(etag)
+	if etag == "*" {
+		return 0, nil
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	version, err := strconv.ParseInt(strings.Trim(etag, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q", etag)
+	}
+	return version, nil
+}
+
+// expectedVersion returns the version a write is conditional on: the one set
+// on the request, otherwise the one from the If-Match header, otherwise 0
+func expectedVersion(ctx context.Context, requested int64) (int64, error) {
+	if requested != 0 {
+		return requested, nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, nil
+	}
+
+	values := md.Get(ifMatchMetadataKey)
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	version, err := parseETag(values[0])
+	if err != nil {
+		return 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return version, nil
+}
+
+// setETag sends the version of u as the ETag response header
+func setETag(ctx context.Context, u *user.User) {
+	// Only fails when the headers were already sent, which never happens
+	// before the handler returns
+	_ = grpc.SetHeader(ctx, metadata.Pairs(etagMetadataKey, formatETag(u.Version)))
+}
+
+// outgoingHeaderMatcher exposes the ETag metadata as a plain HTTP header and
+// keeps the default Grpc-Metadata- prefix for everything else
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == etagMetadataKey {
+		return "ETag", true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// preconditionFailedWriter turns the 409 Conflict the gateway uses for
+// codes.Aborted into 412 Precondition Failed
+type preconditionFailedWriter struct {
+	http.ResponseWriter
+}
+
+func (w preconditionFailedWriter) WriteHeader(code int) {
+	if code == http.StatusConflict {
+		code = http.StatusPreconditionFailed
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// httpErrorHandler reports version conflicts of requests sent with If-Match
+// as 412 Precondition Failed, as HTTP clients expect, and sends the retry
+// delay of errors carrying one as the Retry-After header
+func httpErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if status.Code(err) == codes.Aborted && r.Header.Get("If-Match") != "" {
+		w = preconditionFailedWriter{w}
+	}
+	setRetryAfter(w, err)
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateGroup implements the CreateGroup RPC method
+func (s *server) CreateGroup(ctx context.Context, req *pb.CreateGroupRequest) (*pb.GroupResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	group, err := s.groupService.CreateGroup(ctx, req.Name, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GroupResponse{
+		Group: toPBGroup(group),
+	}, nil
+}
+
+// GetGroup implements the GetGroup RPC method
+func (s *server) GetGroup(ctx context.Context, req *pb.GetGroupRequest) (*pb.GroupResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	group, err := s.groupService.GetGroup(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GroupResponse{
+		Group: toPBGroup(group),
+	}, nil
+}
+
+// ListGroups implements the ListGroups RPC method
+func (s *server) ListGroups(ctx context.Context, req *pb.ListGroupsRequest) (*pb.ListGroupsResponse, error) {
+	groups, err := s.groupService.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListGroupsResponse{
+		Groups: toPBGroups(groups),
+	}, nil
+}
+
+// UpdateGroup implements the UpdateGroup RPC method
+func (s *server) UpdateGroup(ctx context.Context, req *pb.UpdateGroupRequest) (*pb.GroupResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	group, err := s.groupService.UpdateGroup(ctx, req.Id, req.Name, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GroupResponse{
+		Group: toPBGroup(group),
+	}, nil
+}
+
+// DeleteGroup implements the DeleteGroup RPC method
+func (s *server) DeleteGroup(ctx context.Context, req *pb.DeleteGroupRequest) (*pb.DeleteGroupResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.groupService.DeleteGroup(ctx, req.Id); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteGroupResponse{
+		Success: true,
+	}, nil
+}
+
+// AddGroupMember implements the AddGroupMember RPC method
+func (s *server) AddGroupMember(ctx context.Context, req *pb.AddGroupMemberRequest) (*pb.AddGroupMemberResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var err error
+	switch member := req.Member.(type) {
+	case *pb.AddGroupMemberRequest_UserId:
+		err = s.groupService.AddUser(ctx, req.GroupId, member.UserId)
+	case *pb.AddGroupMemberRequest_MemberGroupId:
+		err = s.groupService.AddSubgroup(ctx, req.GroupId, member.MemberGroupId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.AddGroupMemberResponse{
+		Success: true,
+	}, nil
+}
+
+// RemoveGroupMember implements the RemoveGroupMember RPC method
+func (s *server) RemoveGroupMember(ctx context.Context, req *pb.RemoveGroupMemberRequest) (*pb.RemoveGroupMemberResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var err error
+	switch member := req.Member.(type) {
+	case *pb.RemoveGroupMemberRequest_UserId:
+		err = s.groupService.RemoveUser(ctx, req.GroupId, member.UserId)
+	case *pb.RemoveGroupMemberRequest_MemberGroupId:
+		err = s.groupService.RemoveSubgroup(ctx, req.GroupId, member.MemberGroupId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RemoveGroupMemberResponse{
+		Success: true,
+	}, nil
+}
+
+// ListGroupMembers implements the ListGroupMembers RPC method
+func (s *server) ListGroupMembers(ctx context.Context, req *pb.ListGroupMembersRequest) (*pb.ListGroupMembersResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	users, groups, err := s.groupService.ListMembers(ctx, req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListGroupMembersResponse{
+		Users:  toPBUsers(users),
+		Groups: toPBGroups(groups),
+	}, nil
+}
+
+// ListUserGroups implements the ListUserGroups RPC method
+func (s *server) ListUserGroups(ctx context.Context, req *pb.ListUserGroupsRequest) (*pb.ListUserGroupsResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	groups, err := s.groupService.ListUserGroups(ctx, req.UserId, req.DirectOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListUserGroupsResponse{
+		Groups: toPBGroups(groups),
+	}, nil
+}
+
+// GrantGroupRole implements the GrantGroupRole RPC method
+func (s *server) GrantGroupRole(ctx context.Context, req *pb.GrantGroupRoleRequest) (*pb.GroupRolesResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	roles, err := s.groupService.GrantRole(ctx, req.GroupId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return toGroupRolesResponse(req.GroupId, roles), nil
+}
+
+// RevokeGroupRole implements the RevokeGroupRole RPC method
+func (s *server) RevokeGroupRole(ctx context.Context, req *pb.RevokeGroupRoleRequest) (*pb.GroupRolesResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	roles, err := s.groupService.RevokeRole(ctx, req.GroupId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return toGroupRolesResponse(req.GroupId, roles), nil
+}
+
+// toGroupRolesResponse converts the roles of a group into their protobuf representation
+func toGroupRolesResponse(groupID int64, roles []user.Role) *pb.GroupRolesResponse {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+
+	return &pb.GroupRolesResponse{
+		GroupId: groupID,
+		Roles:   names,
+	}
+}
+
+// toPBGroup converts a domain group into its protobuf representation
+func toPBGroup(group *user.Group) *pb.Group {
+	return &pb.Group{
+		Id:             group.ID,
+		OrganizationId: group.OrganizationID,
+		Name:           group.Name,
+		Description:    group.Description,
+		CreatedAt:      group.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      group.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// toPBGroups converts a list of domain groups into their protobuf representation
+func toPBGroups(groups []*user.Group) []*pb.Group {
+	pbGroups := make([]*pb.Group, len(groups))
+	for i, group := range groups {
+		pbGroups[i] = toPBGroup(group)
+	}
+	return pbGroups
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/config"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/oidc"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListIdentities implements the ListIdentities RPC method
+func (s *server) ListIdentities(ctx context.Context, req *pb.ListIdentitiesRequest) (*pb.ListIdentitiesResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	identities, err := s.identityService.ListIdentities(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	pbIdentities := make([]*pb.LinkedIdentity, len(identities))
+	for i, identity := range identities {
+		pbIdentities[i] = toPBLinkedIdentity(identity)
+	}
+
+	return &pb.ListIdentitiesResponse{
+		Identities: pbIdentities,
+	}, nil
+}
+
+// UnlinkIdentity implements the UnlinkIdentity RPC method
+func (s *server) UnlinkIdentity(ctx context.Context, req *pb.UnlinkIdentityRequest) (*pb.UnlinkIdentityResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.identityService.UnlinkIdentity(ctx, req.UserId, req.IdentityId); err != nil {
+		return nil, err
+	}
+
+	return &pb.UnlinkIdentityResponse{
+		Success: true,
+	}, nil
+}
+
+// toPBLinkedIdentity converts a domain linked identity into its protobuf representation
+func toPBLinkedIdentity(identity *user.LinkedIdentity) *pb.LinkedIdentity {
+	pbIdentity := &pb.LinkedIdentity{
+		Id:        identity.ID,
+		UserId:    identity.UserID,
+		Provider:  identity.Provider,
+		Issuer:    identity.Issuer,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt.Format(time.RFC3339),
+	}
+	if identity.LastLoginAt != nil {
+		pbIdentity.LastLoginAt = identity.LastLoginAt.Format(time.RFC3339)
+	}
+	return pbIdentity
+}
+
+// newExternalProviders creates the configured external identity providers
+func newExternalProviders(cfg config.SocialLoginConfig) []*oidc.ExternalProvider {
+	providers := make([]*oidc.ExternalProvider, len(cfg.Providers))
+	for i, p := range cfg.Providers {
+		providers[i] = oidc.NewExternalProvider(p.Name, p.IssuerURL, p.ClientID, p.ClientSecret, strings.Fields(p.Scopes), nil)
+	}
+	return providers
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateInvitation implements the CreateInvitation RPC method
+func (s *server) CreateInvitation(ctx context.Context, req *pb.CreateInvitationRequest) (*pb.InvitationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	invitation, err := s.invitationService.CreateInvitation(ctx, req.Email, req.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.InvitationResponse{
+		Invitation: toPBInvitation(invitation),
+	}, nil
+}
+
+// ListInvitations implements the ListInvitations RPC method
+func (s *server) ListInvitations(ctx context.Context, req *pb.ListInvitationsRequest) (*pb.ListInvitationsResponse, error) {
+	invitations, err := s.invitationService.ListInvitations(ctx, req.PendingOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	pbInvitations := make([]*pb.Invitation, len(invitations))
+	for i, invitation := range invitations {
+		pbInvitations[i] = toPBInvitation(invitation)
+	}
+
+	return &pb.ListInvitationsResponse{
+		Invitations: pbInvitations,
+	}, nil
+}
+
+// RevokeInvitation implements the RevokeInvitation RPC method
+func (s *server) RevokeInvitation(ctx context.Context, req *pb.RevokeInvitationRequest) (*pb.RevokeInvitationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.invitationService.RevokeInvitation(ctx, req.Id); err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeInvitationResponse{
+		Success: true,
+	}, nil
+}
+
+// AcceptInvitation implements the AcceptInvitation RPC method
+func (s *server) AcceptInvitation(ctx context.Context, req *pb.AcceptInvitationRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.invitationService.AcceptInvitation(ctx, req.Token, req.Username, req.Password, req.FullName)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// toPBInvitation converts a domain invitation into its protobuf representation
+func toPBInvitation(invitation *user.Invitation) *pb.Invitation {
+	roles := make([]string, len(invitation.Roles))
+	for i, role := range invitation.Roles {
+		roles[i] = string(role)
+	}
+
+	pbInvitation := &pb.Invitation{
+		Id:             invitation.ID,
+		OrganizationId: invitation.OrganizationID,
+		Email:          invitation.Email,
+		Roles:          roles,
+		CreatedAt:      invitation.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:      invitation.ExpiresAt.Format(time.RFC3339),
+	}
+	if invitation.InvitedBy != nil {
+		pbInvitation.InvitedBy = *invitation.InvitedBy
+	}
+	if invitation.AcceptedAt != nil {
+		pbInvitation.AcceptedAt = invitation.AcceptedAt.Format(time.RFC3339)
+	}
+	if invitation.AcceptedUserID != nil {
+		pbInvitation.AcceptedUserId = *invitation.AcceptedUserID
+	}
+	if invitation.RevokedAt != nil {
+		pbInvitation.RevokedAt = invitation.RevokedAt.Format(time.RFC3339)
+	}
+	return pbInvitation
+}
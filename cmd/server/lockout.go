@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/truongtu268/project_maker/config"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnlockUser implements the UnlockUser RPC method
+func (s *server) UnlockUser(ctx context.Context, req *pb.UnlockUserRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.lockoutService.UnlockUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// newLockoutPolicy creates the lockout policy described by the configuration
+func newLockoutPolicy(cfg config.LockoutConfig) *user.LockoutPolicy {
+	return &user.LockoutPolicy{
+		MaxAttempts:   cfg.MaxAttempts,
+		MaxIPAttempts: cfg.MaxIPAttempts,
+		BaseDuration:  cfg.Duration,
+		MaxDuration:   cfg.MaxDuration,
+		ResetAfter:    cfg.ResetAfter,
+	}
+}
+
+// setRetryAfter sets the Retry-After header, in whole seconds, from the
+// RetryInfo details of a status error
+func setRetryAfter(w http.ResponseWriter, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+			seconds := math.Ceil(info.RetryDelay.AsDuration().Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+			return
+		}
+	}
+}
@@ -19,6 +19,10 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/truongtu268/project_maker/config"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/grpcerr"
+	"github.com/truongtu268/project_maker/internal/oidc"
 	"github.com/truongtu268/project_maker/internal/repository"
 	"github.com/truongtu268/project_maker/internal/service"
 	pb "github.com/truongtu268/project_maker/proto/user"
@@ -32,7 +36,19 @@ import (
 // server is the gRPC server implementation
 type server struct {
 	pb.UnimplementedUserServiceServer
-	userService *service.UserService
+	userService          *service.UserService
+	authService          *service.AuthService
+	verificationService  *service.EmailVerificationService
+	passwordResetService *service.PasswordResetService
+	lockoutService       *service.LockoutService
+	mfaService           *service.MFAService
+	sessionService       *service.SessionService
+	apiKeyService        *service.APIKeyService
+	oauthService         *service.OAuthService
+	identityService      *service.IdentityService
+	organizationService  *service.OrganizationService
+	groupService         *service.GroupService
+	invitationService    *service.InvitationService
 }
 
 // CreateUser implements the CreateUser RPC method
@@ -46,16 +62,10 @@ func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 	if err != nil {
 		return nil, err
 	}
+	setETag(ctx, user)
 
 	return &pb.UserResponse{
-		User: &pb.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			FullName:  user.FullName,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-		},
+		User: toPBUser(user),
 	}, nil
 }
 
@@ -68,21 +78,12 @@ func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserR
 
 	user, err := s.userService.GetUser(ctx, req.Id)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			return nil, status.Errorf(codes.NotFound, "user not found with ID %d", req.Id)
-		}
 		return nil, err
 	}
+	setETag(ctx, user)
 
 	return &pb.UserResponse{
-		User: &pb.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			FullName:  user.FullName,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-		},
+		User: toPBUser(user),
 	}, nil
 }
 
@@ -93,38 +94,24 @@ func (s *server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	var username, email, password, fullName *string
-
-	if req.Username != nil && *req.Username != "" {
-		username = req.Username
-	}
-	if req.Email != nil && *req.Email != "" {
-		email = req.Email
-	}
-	if req.Password != nil && *req.Password != "" {
-		password = req.Password
+	username, email, password, fullName, err := req.UpdatedFields()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	if req.FullName != nil && *req.FullName != "" {
-		fullName = req.FullName
+
+	version, err := expectedVersion(ctx, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
 	}
 
-	user, err := s.userService.UpdateUser(ctx, req.Id, username, email, password, fullName)
+	user, err := s.userService.UpdateUser(ctx, req.Id, version, username, email, password, fullName)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			return nil, status.Errorf(codes.NotFound, "user not found with ID %d", req.Id)
-		}
 		return nil, err
 	}
+	setETag(ctx, user)
 
 	return &pb.UserResponse{
-		User: &pb.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			FullName:  user.FullName,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-		},
+		User: toPBUser(user),
 	}, nil
 }
 
@@ -135,11 +122,13 @@ func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	err := s.userService.DeleteUser(ctx, req.Id)
+	version, err := expectedVersion(ctx, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userService.DeleteUser(ctx, req.Id, version)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			return nil, status.Errorf(codes.NotFound, "user not found with ID %d", req.Id)
-		}
 		return nil, err
 	}
 
@@ -155,27 +144,118 @@ func (s *server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.L
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	users, total, err := s.userService.ListUsers(ctx, int(req.Page), int(req.PageSize))
+	filter, err := toUserFilter(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Requests with a page number keep using offset pagination
+	if req.Page > 0 {
+		if req.PageToken != "" {
+			return nil, status.Error(codes.InvalidArgument, "page and page_token cannot be used together")
+		}
+
+		users, total, err := s.userService.ListUsers(ctx, filter, int(req.Page), int(req.PageSize))
+		if err != nil {
+			return nil, err
+		}
+
+		totalCount := int32(total)
+		return &pb.ListUsersResponse{
+			Users:      toPBUsers(users),
+			TotalCount: &totalCount,
+		}, nil
+	}
+
+	page, err := s.userService.ListUsersPage(ctx, filter, req.PageToken, int(req.PageSize), req.IncludeTotalCount)
 	if err != nil {
 		return nil, err
 	}
 
+	resp := &pb.ListUsersResponse{
+		Users:         toPBUsers(page.Users),
+		NextPageToken: page.NextPageToken,
+	}
+	if page.TotalCount != nil {
+		totalCount := int32(*page.TotalCount)
+		resp.TotalCount = &totalCount
+	}
+
+	return resp, nil
+}
+
+// toUserFilter builds the repository filter from the ListUsers request
+func toUserFilter(req *pb.ListUsersRequest) (repository.UserFilter, error) {
+	filter := repository.UserFilter{
+		UsernamePrefix: req.UsernamePrefix,
+		EmailPrefix:    req.EmailPrefix,
+		Query:          req.Query,
+		SortBy:         repository.UserSortField(req.SortBy),
+		SortDesc:       req.SortOrder == "desc",
+		Status:         fromPBUserStatus(req.Status),
+	}
+
+	if req.CreatedAfter != "" {
+		createdAfter, err := time.Parse(time.RFC3339, req.CreatedAfter)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_after: %v", err)
+		}
+		filter.CreatedAfter = &createdAfter
+	}
+
+	if req.CreatedBefore != "" {
+		createdBefore, err := time.Parse(time.RFC3339, req.CreatedBefore)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_before: %v", err)
+		}
+		filter.CreatedBefore = &createdBefore
+	}
+
+	return filter, nil
+}
+
+// toPBUser converts a domain user into its protobuf representation
+func toPBUser(u *user.User) *pb.User {
+	pbUser := &pb.User{
+		Id:             u.ID,
+		Username:       u.Username,
+		Email:          u.Email,
+		FullName:       u.FullName,
+		CreatedAt:      u.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      u.UpdatedAt.Format(time.RFC3339),
+		Version:        u.Version,
+		OrganizationId: u.OrganizationID,
+		Status:         pbUserStatuses[u.Status],
+		StatusReason:   u.StatusReason,
+	}
+	if u.DeletedAt != nil {
+		pbUser.DeletedAt = u.DeletedAt.Format(time.RFC3339)
+	}
+	if u.EmailVerifiedAt != nil {
+		pbUser.EmailVerifiedAt = u.EmailVerifiedAt.Format(time.RFC3339)
+	}
+	if u.PasswordChangedAt != nil {
+		pbUser.PasswordChangedAt = u.PasswordChangedAt.Format(time.RFC3339)
+	}
+	if u.TOTPEnabledAt != nil {
+		pbUser.TotpEnabledAt = u.TOTPEnabledAt.Format(time.RFC3339)
+	}
+	if u.StatusChangedAt != nil {
+		pbUser.StatusChangedAt = u.StatusChangedAt.Format(time.RFC3339)
+	}
+	if u.StatusChangedBy != nil {
+		pbUser.StatusChangedBy = *u.StatusChangedBy
+	}
+	return pbUser
+}
+
+// toPBUsers converts a list of domain users into their protobuf representation
+func toPBUsers(users []*user.User) []*pb.User {
 	var pbUsers []*pb.User
-	for _, user := range users {
-		pbUsers = append(pbUsers, &pb.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			FullName:  user.FullName,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-		})
-	}
-
-	return &pb.ListUsersResponse{
-		Users:      pbUsers,
-		TotalCount: int32(total),
-	}, nil
+	for _, u := range users {
+		pbUsers = append(pbUsers, toPBUser(u))
+	}
+	return pbUsers
 }
 
 // Custom logger middleware for HTTP requests
@@ -198,7 +278,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, If-Match, X-Api-Key, X-Organization-Id")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -232,15 +313,23 @@ func runMigrations(db *sql.DB, cfg *config.Config) error {
 	return nil
 }
 
-func startGRPCServer(cfg *config.Config, userService *service.UserService) (*grpc.Server, net.Listener, error) {
+func startGRPCServer(cfg *config.Config, srv *server, tokenManager *auth.TokenManager) (*grpc.Server, net.Listener, error) {
 	// Start gRPC server
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.GRPCPort))
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterUserServiceServer(grpcServer, &server{userService: userService})
+	// Errors returned by the handlers are translated into gRPC statuses by
+	// the innermost interceptor
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			auth.ClientUnaryServerInterceptor(),
+			auth.UnaryServerInterceptor(tokenManager, srv.sessionService, srv.apiKeyService, srv.organizationService, pb.PublicMethods, pb.OptionalMethods),
+			grpcerr.UnaryServerInterceptor(),
+		),
+	)
+	pb.RegisterUserServiceServer(grpcServer, srv)
 
 	// Register reflection service on gRPC server for easier testing with grpcurl
 	reflection.Register(grpcServer)
@@ -255,8 +344,16 @@ func startGRPCServer(cfg *config.Config, userService *service.UserService) (*grp
 	return grpcServer, lis, nil
 }
 
-func startHTTPServer(ctx context.Context, cfg *config.Config) (*http.Server, error) {
-	mux := runtime.NewServeMux()
+func startHTTPServer(ctx context.Context, cfg *config.Config, oidcHandler, socialLoginHandler http.Handler) (*http.Server, error) {
+	// The gateway forwards the HTTP Authorization and X-Api-Key headers to
+	// gRPC as the "authorization" and "x-api-key" metadata keys, which the auth
+	// interceptor reads, and If-Match as "grpcgateway-if-match" for
+	// conditional writes
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithErrorHandler(httpErrorHandler),
+	)
 
 	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
 	err := pb.RegisterUserServiceHandlerFromEndpoint(
@@ -275,6 +372,13 @@ func startHTTPServer(ctx context.Context, cfg *config.Config) (*http.Server, err
 	// Add the gRPC-Gateway mux to handle API requests
 	httpMux.Handle("/api/", loggingMiddleware(corsMiddleware(mux)))
 
+	// Serve the OpenID Connect provider endpoints
+	httpMux.Handle("/.well-known/", loggingMiddleware(corsMiddleware(oidcHandler)))
+	httpMux.Handle("/oauth2/", loggingMiddleware(corsMiddleware(oidcHandler)))
+
+	// Sign users in with external OpenID Connect providers
+	httpMux.Handle("/auth/", loggingMiddleware(socialLoginHandler))
+
 	// Create a new HTTP server
 	httpServer := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.HTTPPort),
@@ -295,6 +399,9 @@ func startHTTPServer(ctx context.Context, cfg *config.Config) (*http.Server, err
 func main() {
 	// Load configuration
 	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Set up database connection
 	db, err := sql.Open("postgres", cfg.Database.DSN())
@@ -311,23 +418,89 @@ func main() {
 	// Create sqlx DB
 	dbx := sqlx.NewDb(db, "postgres")
 
-	// Set up repository and service
+	// Set up repositories and services
 	userRepo := repository.NewPostgresUserRepository(dbx)
-	userService := service.NewUserService(userRepo)
+	roleRepo := repository.NewPostgresRoleRepository(dbx)
+	refreshTokenRepo := repository.NewPostgresRefreshTokenRepository(dbx)
+	loginThrottleRepo := repository.NewPostgresLoginThrottleRepository(dbx)
+	sessionRepo := repository.NewPostgresSessionRepository(dbx)
+	apiKeyRepo := repository.NewPostgresAPIKeyRepository(dbx)
+	oauthClientRepo := repository.NewPostgresOAuthClientRepository(dbx)
+	authorizationCodeRepo := repository.NewPostgresAuthorizationCodeRepository(dbx)
+	linkedIdentityRepo := repository.NewPostgresLinkedIdentityRepository(dbx)
+	externalLoginStateRepo := repository.NewPostgresExternalLoginStateRepository(dbx)
+	organizationRepo := repository.NewPostgresOrganizationRepository(dbx)
+	groupRepo := repository.NewPostgresGroupRepository(dbx)
+	invitationRepo := repository.NewPostgresInvitationRepository(dbx)
+	unitOfWork := repository.NewPostgresUnitOfWork(dbx)
+	tokenManager := auth.NewTokenManager(cfg.Auth.JWTSecret, cfg.Auth.Issuer, cfg.Auth.AccessTokenTTL)
+
+	// Set up email delivery
+	mail, err := newMailer(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to set up mailer: %v", err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to set up password policy: %v", err)
+	}
+	passwordHasher := newPasswordHasher(cfg.PasswordHashing)
+
+	verificationService := service.NewEmailVerificationService(userRepo, roleRepo, unitOfWork, mail, cfg.Auth.EmailVerificationTTL, cfg.Auth.EmailVerificationURL)
+	lockoutService := service.NewLockoutService(userRepo, roleRepo, loginThrottleRepo, newLockoutPolicy(cfg.Lockout))
+	userService := service.NewUserService(userRepo, roleRepo, organizationRepo, unitOfWork, verificationService, passwordPolicy, passwordHasher, lockoutService)
+	passwordResetService := service.NewPasswordResetService(userRepo, unitOfWork, mail, cfg.Auth.PasswordResetTTL, cfg.Auth.PasswordResetURL, passwordPolicy, passwordHasher)
+	secretBox, err := auth.NewSecretBox(cfg.Auth.MFAEncryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to set up MFA secret encryption: %v", err)
+	}
+	mfaService := service.NewMFAService(userRepo, roleRepo, unitOfWork, secretBox, cfg.Auth.MFAIssuer, lockoutService, cfg.Auth.RequireAdminMFA)
+	sessionService := service.NewSessionService(userRepo, roleRepo, sessionRepo, unitOfWork)
+	apiKeyService := service.NewAPIKeyService(userRepo, roleRepo, apiKeyRepo)
+	signingKey, err := newSigningKey(cfg.OIDC)
+	if err != nil {
+		log.Fatalf("Failed to load ID token signing key: %v", err)
+	}
+	oauthService := service.NewOAuthService(userRepo, roleRepo, oauthClientRepo, authorizationCodeRepo, sessionService, tokenManager, signingKey, cfg.OIDC.IssuerURL, cfg.OIDC.AuthorizationCodeTTL, cfg.OIDC.IDTokenTTL)
+	authService := service.NewAuthService(userRepo, organizationRepo, refreshTokenRepo, sessionService, tokenManager, passwordHasher, lockoutService, mfaService, cfg.Auth.RefreshTokenTTL, cfg.Auth.MFATokenTTL)
+	identityService := service.NewIdentityService(userRepo, roleRepo, linkedIdentityRepo, externalLoginStateRepo, unitOfWork, authService, cfg.SocialLogin.StateTTL)
+	organizationService := service.NewOrganizationService(userRepo, roleRepo, organizationRepo, unitOfWork)
+	groupService := service.NewGroupService(userRepo, roleRepo, groupRepo, unitOfWork)
+	invitationService := service.NewInvitationService(userRepo, roleRepo, invitationRepo, unitOfWork, userService, mail, cfg.Auth.InvitationTTL, cfg.Auth.InvitationURL)
 
 	// Create a context that can be canceled
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Start gRPC server
-	grpcServer, _, err := startGRPCServer(cfg, userService)
+	grpcServer, _, err := startGRPCServer(cfg, &server{
+		userService:          userService,
+		authService:          authService,
+		verificationService:  verificationService,
+		passwordResetService: passwordResetService,
+		lockoutService:       lockoutService,
+		mfaService:           mfaService,
+		sessionService:       sessionService,
+		apiKeyService:        apiKeyService,
+		oauthService:         oauthService,
+		identityService:      identityService,
+		organizationService:  organizationService,
+		groupService:         groupService,
+		invitationService:    invitationService,
+	}, tokenManager)
 	if err != nil {
 		log.Fatalf("Failed to start gRPC server: %v", err)
 	}
 	defer grpcServer.Stop()
 
+	// Periodically purge users deleted longer ago than the retention window
+	startPurgeWorker(ctx, userService, cfg.Retention.DeletedUserRetention, cfg.Retention.PurgeInterval)
+
 	// Start HTTP server with gRPC-Gateway
-	httpServer, err := startHTTPServer(ctx, cfg)
+	oidcHandler := oidc.NewHandler(oauthService, tokenManager, sessionService, cfg.OIDC.LoginURL)
+	socialLoginHandler := oidc.NewSocialLoginHandler(identityService, newExternalProviders(cfg.SocialLogin), cfg.SocialLogin.BaseURL, cfg.SocialLogin.SuccessURL)
+	httpServer, err := startHTTPServer(ctx, cfg, oidcHandler, socialLoginHandler)
 	if err != nil {
 		log.Fatalf("Failed to start HTTP server: %v", err)
 	}
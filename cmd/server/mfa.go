@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VerifyMFA implements the VerifyMFA RPC method
+func (s *server) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.TokenResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	pair, err := s.authService.VerifyMFA(ctx, req.MfaToken, req.Code, req.Device)
+	if err != nil {
+		return nil, err
+	}
+
+	return toTokenResponse(pair), nil
+}
+
+// EnrollTOTP implements the EnrollTOTP RPC method
+func (s *server) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	enrollment, err := s.mfaService.EnrollTOTP(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.EnrollTOTPResponse{
+		Secret:     enrollment.Secret,
+		OtpauthUri: enrollment.URI,
+	}, nil
+}
+
+// ConfirmTOTP implements the ConfirmTOTP RPC method
+func (s *server) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (*pb.ConfirmTOTPResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, recoveryCodes, err := s.mfaService.ConfirmTOTP(ctx, req.Id, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.ConfirmTOTPResponse{
+		User:          toPBUser(user),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// DisableTOTP implements the DisableTOTP RPC method
+func (s *server) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.mfaService.DisableTOTP(ctx, req.Id, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/truongtu268/project_maker/config"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateOAuthClient implements the CreateOAuthClient RPC method
+func (s *server) CreateOAuthClient(ctx context.Context, req *pb.CreateOAuthClientRequest) (*pb.CreateOAuthClientResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	client, secret, err := s.oauthService.CreateClient(ctx, req.Name, req.RedirectUris, req.Confidential)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CreateOAuthClientResponse{
+		Client:       toPBOAuthClient(client),
+		ClientSecret: secret,
+	}, nil
+}
+
+// ListOAuthClients implements the ListOAuthClients RPC method
+func (s *server) ListOAuthClients(ctx context.Context, req *pb.ListOAuthClientsRequest) (*pb.ListOAuthClientsResponse, error) {
+	clients, err := s.oauthService.ListClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pbClients := make([]*pb.OAuthClient, len(clients))
+	for i, client := range clients {
+		pbClients[i] = toPBOAuthClient(client)
+	}
+
+	return &pb.ListOAuthClientsResponse{
+		Clients: pbClients,
+	}, nil
+}
+
+// DeleteOAuthClient implements the DeleteOAuthClient RPC method
+func (s *server) DeleteOAuthClient(ctx context.Context, req *pb.DeleteOAuthClientRequest) (*pb.DeleteOAuthClientResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.oauthService.DeleteClient(ctx, req.ClientId); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteOAuthClientResponse{
+		Success: true,
+	}, nil
+}
+
+// toPBOAuthClient converts a domain OAuth client into its protobuf representation
+func toPBOAuthClient(c *user.OAuthClient) *pb.OAuthClient {
+	return &pb.OAuthClient{
+		ClientId:       c.ClientID,
+		Name:           c.Name,
+		RedirectUris:   c.RedirectURIs,
+		Confidential:   c.IsConfidential(),
+		CreatedAt:      c.CreatedAt.Format(time.RFC3339),
+		OrganizationId: c.OrganizationID,
+	}
+}
+
+// newSigningKey loads the key signing ID tokens from its PEM file, or
+// generates one when no file is configured. Generated keys change on every
+// restart, invalidating the ID tokens clients hold.
+func newSigningKey(cfg config.OIDCConfig) (*auth.SigningKey, error) {
+	if cfg.SigningKeyFile == "" {
+		log.Println("Warning: OIDC_SIGNING_KEY_FILE is not set, generating a temporary ID token signing key")
+		return auth.GenerateSigningKey()
+	}
+
+	data, err := os.ReadFile(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ParseSigningKey(data)
+}
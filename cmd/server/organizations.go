@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateOrganization implements the CreateOrganization RPC method
+func (s *server) CreateOrganization(ctx context.Context, req *pb.CreateOrganizationRequest) (*pb.OrganizationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	org, err := s.organizationService.CreateOrganization(ctx, req.Slug, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.OrganizationResponse{
+		Organization: toPBOrganization(org),
+	}, nil
+}
+
+// GetOrganization implements the GetOrganization RPC method
+func (s *server) GetOrganization(ctx context.Context, req *pb.GetOrganizationRequest) (*pb.OrganizationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	org, err := s.organizationService.GetOrganization(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.OrganizationResponse{
+		Organization: toPBOrganization(org),
+	}, nil
+}
+
+// ListOrganizations implements the ListOrganizations RPC method
+func (s *server) ListOrganizations(ctx context.Context, req *pb.ListOrganizationsRequest) (*pb.ListOrganizationsResponse, error) {
+	orgs, err := s.organizationService.ListOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pbOrgs := make([]*pb.Organization, len(orgs))
+	for i, org := range orgs {
+		pbOrgs[i] = toPBOrganization(org)
+	}
+
+	return &pb.ListOrganizationsResponse{
+		Organizations: pbOrgs,
+	}, nil
+}
+
+// UpdateOrganization implements the UpdateOrganization RPC method
+func (s *server) UpdateOrganization(ctx context.Context, req *pb.UpdateOrganizationRequest) (*pb.OrganizationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	org, err := s.organizationService.UpdateOrganization(ctx, req.Id, req.Slug, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.OrganizationResponse{
+		Organization: toPBOrganization(org),
+	}, nil
+}
+
+// DeleteOrganization implements the DeleteOrganization RPC method
+func (s *server) DeleteOrganization(ctx context.Context, req *pb.DeleteOrganizationRequest) (*pb.DeleteOrganizationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.organizationService.DeleteOrganization(ctx, req.Id); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteOrganizationResponse{
+		Success: true,
+	}, nil
+}
+
+// AddOrganizationMember implements the AddOrganizationMember RPC method
+func (s *server) AddOrganizationMember(ctx context.Context, req *pb.AddOrganizationMemberRequest) (*pb.OrganizationMemberResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	membership, err := s.organizationService.AddMember(ctx, req.OrganizationId, req.Email, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.OrganizationMemberResponse{
+		Member: toPBOrganizationMember(membership),
+	}, nil
+}
+
+// RemoveOrganizationMember implements the RemoveOrganizationMember RPC method
+func (s *server) RemoveOrganizationMember(ctx context.Context, req *pb.RemoveOrganizationMemberRequest) (*pb.RemoveOrganizationMemberResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.organizationService.RemoveMember(ctx, req.OrganizationId, req.UserId); err != nil {
+		return nil, err
+	}
+
+	return &pb.RemoveOrganizationMemberResponse{
+		Success: true,
+	}, nil
+}
+
+// ListOrganizationMembers implements the ListOrganizationMembers RPC method
+func (s *server) ListOrganizationMembers(ctx context.Context, req *pb.ListOrganizationMembersRequest) (*pb.ListOrganizationMembersResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	memberships, err := s.organizationService.ListMembers(ctx, req.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	pbMembers := make([]*pb.OrganizationMember, len(memberships))
+	for i, membership := range memberships {
+		pbMembers[i] = toPBOrganizationMember(membership)
+	}
+
+	return &pb.ListOrganizationMembersResponse{
+		Members: pbMembers,
+	}, nil
+}
+
+// toPBOrganization converts a domain organization into its protobuf representation
+func toPBOrganization(org *user.Organization) *pb.Organization {
+	return &pb.Organization{
+		Id:        org.ID,
+		Slug:      org.Slug,
+		Name:      org.Name,
+		CreatedAt: org.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: org.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// toPBOrganizationMember converts a domain membership into its protobuf representation
+func toPBOrganizationMember(membership *user.Membership) *pb.OrganizationMember {
+	return &pb.OrganizationMember{
+		OrganizationId: membership.OrganizationID,
+		UserId:         membership.UserID,
+		Role:           string(membership.Role),
+		CreatedAt:      membership.CreatedAt.Format(time.RFC3339),
+	}
+}
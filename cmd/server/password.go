@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ChangePassword implements the ChangePassword RPC method
+func (s *server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.userService.ChangePassword(ctx, req.Id, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// RequestPasswordReset implements the RequestPasswordReset RPC method
+func (s *server) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*pb.RequestPasswordResetResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.passwordResetService.RequestPasswordReset(ctx, req.Email); err != nil {
+		return nil, err
+	}
+
+	return &pb.RequestPasswordResetResponse{
+		Success: true,
+	}, nil
+}
+
+// ConfirmPasswordReset implements the ConfirmPasswordReset RPC method
+func (s *server) ConfirmPasswordReset(ctx context.Context, req *pb.ConfirmPasswordResetRequest) (*pb.ConfirmPasswordResetResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.passwordResetService.ConfirmPasswordReset(ctx, req.Token, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	return &pb.ConfirmPasswordResetResponse{
+		Success: true,
+	}, nil
+}
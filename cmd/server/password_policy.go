@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+
+	"github.com/truongtu268/project_maker/config"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newPasswordPolicy creates the password policy described by the configuration
+func newPasswordPolicy(cfg config.PasswordPolicyConfig) (*user.PasswordPolicy, error) {
+	policy := user.DefaultPasswordPolicy()
+	policy.MinLength = cfg.MinLength
+	policy.MaxLength = cfg.MaxLength
+	policy.RequireUpper = cfg.RequireUpper
+	policy.RequireLower = cfg.RequireLower
+	policy.RequireDigit = cfg.RequireDigit
+	policy.RequireSymbol = cfg.RequireSymbol
+	policy.HistorySize = cfg.HistorySize
+
+	if cfg.BlocklistFile != "" {
+		f, err := os.Open(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if err := policy.LoadBlocklist(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+// newPasswordHasher creates a hasher producing argon2id hashes with the
+// configured parameters and still verifying legacy bcrypt hashes
+func newPasswordHasher(cfg config.PasswordHashingConfig) user.PasswordHasher {
+	params := user.DefaultArgon2idParams
+	params.Memory = uint32(cfg.Memory)
+	params.Iterations = uint32(cfg.Iterations)
+	params.Threads = uint8(cfg.Threads)
+
+	return user.NewMultiHasher(user.NewArgon2idHasher(params), user.NewBcryptHasher(bcrypt.DefaultCost))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GrantRole implements the GrantRole RPC method
+func (s *server) GrantRole(ctx context.Context, req *pb.GrantRoleRequest) (*pb.UserRolesResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	roles, err := s.userService.GrantRole(ctx, req.UserId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserRolesResponse(req.UserId, roles), nil
+}
+
+// RevokeRole implements the RevokeRole RPC method
+func (s *server) RevokeRole(ctx context.Context, req *pb.RevokeRoleRequest) (*pb.UserRolesResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	roles, err := s.userService.RevokeRole(ctx, req.UserId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserRolesResponse(req.UserId, roles), nil
+}
+
+// toUserRolesResponse converts a list of roles into its protobuf representation
+func toUserRolesResponse(userID int64, roles []user.Role) *pb.UserRolesResponse {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+
+	return &pb.UserRolesResponse{
+		UserId: userID,
+		Roles:  names,
+	}
+}
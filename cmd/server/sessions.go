@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListSessions implements the ListSessions RPC method
+func (s *server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sessions, err := s.sessionService.ListSessions(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var currentID int64
+	if principal, ok := auth.FromContext(ctx); ok {
+		currentID = principal.SessionID
+	}
+
+	pbSessions := make([]*pb.Session, len(sessions))
+	for i, session := range sessions {
+		pbSessions[i] = toPBSession(session, currentID)
+	}
+
+	return &pb.ListSessionsResponse{
+		Sessions: pbSessions,
+	}, nil
+}
+
+// RevokeSession implements the RevokeSession RPC method
+func (s *server) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.sessionService.RevokeSession(ctx, req.UserId, req.SessionId); err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeSessionResponse{
+		Success: true,
+	}, nil
+}
+
+// RevokeAllSessions implements the RevokeAllSessions RPC method
+func (s *server) RevokeAllSessions(ctx context.Context, req *pb.RevokeAllSessionsRequest) (*pb.RevokeAllSessionsResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.sessionService.RevokeAllSessions(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeAllSessionsResponse{
+		Success: true,
+	}, nil
+}
+
+// toPBSession converts a domain session into its protobuf representation,
+// flagging the session with ID currentID as the current one
+func toPBSession(session *user.Session, currentID int64) *pb.Session {
+	return &pb.Session{
+		Id:         session.ID,
+		Device:     session.Device,
+		Ip:         session.IP,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+		LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+		ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+		Current:    session.ID == currentID,
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pbUserStatuses maps each account status to its protobuf representation
+var pbUserStatuses = map[user.Status]pb.UserStatus{
+	user.StatusPending:     pb.UserStatus_USER_STATUS_PENDING,
+	user.StatusActive:      pb.UserStatus_USER_STATUS_ACTIVE,
+	user.StatusSuspended:   pb.UserStatus_USER_STATUS_SUSPENDED,
+	user.StatusDeactivated: pb.UserStatus_USER_STATUS_DEACTIVATED,
+}
+
+// SuspendUser implements the SuspendUser RPC method
+func (s *server) SuspendUser(ctx context.Context, req *pb.ChangeUserStatusRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.userService.SuspendUser(ctx, req.Id, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// ReactivateUser implements the ReactivateUser RPC method
+func (s *server) ReactivateUser(ctx context.Context, req *pb.ChangeUserStatusRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.userService.ReactivateUser(ctx, req.Id, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// DeactivateUser implements the DeactivateUser RPC method
+func (s *server) DeactivateUser(ctx context.Context, req *pb.ChangeUserStatusRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.userService.DeactivateUser(ctx, req.Id, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// fromPBUserStatus converts a protobuf status into an account status, the
+// empty status for USER_STATUS_UNSPECIFIED
+func fromPBUserStatus(pbStatus pb.UserStatus) user.Status {
+	for s, p := range pbUserStatuses {
+		if p == pbStatus {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/truongtu268/project_maker/config"
+	"github.com/truongtu268/project_maker/internal/mailer"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VerifyEmail implements the VerifyEmail RPC method
+func (s *server) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.UserResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.verificationService.VerifyEmail(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	setETag(ctx, user)
+
+	return &pb.UserResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// ResendVerification implements the ResendVerification RPC method
+func (s *server) ResendVerification(ctx context.Context, req *pb.ResendVerificationRequest) (*pb.ResendVerificationResponse, error) {
+	// Validate the request
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.verificationService.ResendVerification(ctx, req.Id); err != nil {
+		return nil, err
+	}
+
+	return &pb.ResendVerificationResponse{
+		Success: true,
+	}, nil
+}
+
+// newMailer creates the mailer selected by the configuration
+func newMailer(cfg config.MailConfig) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "log":
+		return mailer.NewLogMailer(os.Stdout), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.FilePath)
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
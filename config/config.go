@@ -4,12 +4,22 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server          ServerConfig
+	Database        DatabaseConfig
+	Auth            AuthConfig
+	Retention       RetentionConfig
+	Mail            MailConfig
+	Password        PasswordPolicyConfig
+	PasswordHashing PasswordHashingConfig
+	Lockout         LockoutConfig
+	OIDC            OIDCConfig
+	SocialLogin     SocialLoginConfig
 }
 
 // ServerConfig holds all the server-related configuration
@@ -29,6 +39,133 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// AuthConfig holds all the authentication-related configuration
+type AuthConfig struct {
+	JWTSecret            string
+	Issuer               string
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	EmailVerificationTTL time.Duration
+	// EmailVerificationURL is the page verification emails link to, with the
+	// token appended as a query parameter. Only the token is sent when empty.
+	EmailVerificationURL string
+	PasswordResetTTL     time.Duration
+	// PasswordResetURL is the page password reset emails link to, with the
+	// token appended as a query parameter. Only the token is sent when empty.
+	PasswordResetURL string
+	InvitationTTL    time.Duration
+	// InvitationURL is the page invitation emails link to, with the token
+	// appended as a query parameter. Only the token is sent when empty.
+	InvitationURL string
+	// MFAEncryptionKey encrypts the TOTP secrets stored in the database
+	MFAEncryptionKey string
+	// MFAIssuer names the service in authenticator apps
+	MFAIssuer string
+	// MFATokenTTL is how long users have to give their second factor after
+	// their password
+	MFATokenTTL time.Duration
+	// RequireAdminMFA refuses tokens to admins who have not enabled TOTP
+	RequireAdminMFA bool
+}
+
+// RetentionConfig holds the configuration for purging soft deleted data
+type RetentionConfig struct {
+	DeletedUserRetention time.Duration
+	PurgeInterval        time.Duration
+}
+
+// MailConfig holds all the email delivery configuration
+type MailConfig struct {
+	// Driver is "log" to write emails to stdout, "file" to append them to
+	// FilePath or "smtp" to deliver them through an SMTP server
+	Driver       string
+	From         string
+	FilePath     string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// PasswordPolicyConfig holds the rules passwords have to follow
+type PasswordPolicyConfig struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	HistorySize   int
+	// BlocklistFile lists passwords to reject on top of the built-in common
+	// passwords, one per line
+	BlocklistFile string
+}
+
+// PasswordHashingConfig holds the argon2id parameters used to hash passwords.
+// Hashes made with other parameters are replaced when their user logs in.
+type PasswordHashingConfig struct {
+	// Memory is in KiB
+	Memory     int
+	Iterations int
+	Threads    int
+}
+
+// LockoutConfig holds the limits on failed login attempts
+type LockoutConfig struct {
+	// MaxAttempts is the number of failed logins locking a user, 0 to disable
+	MaxAttempts int
+	// MaxIPAttempts is the number of failed logins locking a client IP, 0 to
+	// disable
+	MaxIPAttempts int
+	// Duration is the length of the first lock, doubled by each following
+	// lock up to MaxDuration
+	Duration    time.Duration
+	MaxDuration time.Duration
+	// ResetAfter is how long without failed logins resets the counters
+	ResetAfter time.Duration
+}
+
+// OIDCConfig holds the configuration of the built-in OpenID Connect provider
+type OIDCConfig struct {
+	// IssuerURL is the public URL of the HTTP server, which identifies the
+	// provider in ID tokens and prefixes the endpoints of its discovery document
+	IssuerURL string
+	// SigningKeyFile is a PEM file holding the RSA key signing ID tokens. A
+	// key is generated at startup when empty, so tokens do not survive restarts.
+	SigningKeyFile string
+	// LoginURL is the page the authorization endpoint sends users to when they
+	// are not logged in, with the authorization request in return_to
+	LoginURL             string
+	AuthorizationCodeTTL time.Duration
+	IDTokenTTL           time.Duration
+}
+
+// SocialLoginConfig holds the external OpenID Connect providers users can
+// sign in with
+type SocialLoginConfig struct {
+	// BaseURL is the public URL of the HTTP server, prefixing the callback
+	// URLs registered with the providers
+	BaseURL string
+	// SuccessURL is the page users are sent back to with their tokens in the
+	// URL fragment. Tokens are returned as JSON when empty.
+	SuccessURL string
+	// StateTTL is how long users have to log in at a provider
+	StateTTL  time.Duration
+	Providers []SocialProviderConfig
+}
+
+// SocialProviderConfig holds the registration of the service as a client of
+// an external OpenID Connect provider
+type SocialProviderConfig struct {
+	// Name identifies the provider in the login and callback URLs
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// Scopes is the space separated list of requested scopes
+	Scopes string
+}
+
 // DSN returns the database connection string
 func (dc *DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
@@ -37,6 +174,10 @@ func (dc *DatabaseConfig) DSN() string {
 	)
 }
 
+// placeholderSecret is the example secret of the documentation, which must
+// never sign or encrypt anything
+const placeholderSecret = "change-me-in-production"
+
 // New returns a new Config struct with values from environment variables
 func New() *Config {
 	return &Config{
@@ -53,7 +194,117 @@ func New() *Config {
 			DBName:   getEnv("DB_NAME", "user_management"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
+		Auth: AuthConfig{
+			JWTSecret:            getEnv("JWT_SECRET", ""),
+			Issuer:               getEnv("JWT_ISSUER", "project_maker"),
+			AccessTokenTTL:       getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL:      getEnvAsDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+			EmailVerificationTTL: getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			EmailVerificationURL: getEnv("EMAIL_VERIFICATION_URL", ""),
+			PasswordResetTTL:     getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+			PasswordResetURL:     getEnv("PASSWORD_RESET_URL", ""),
+			InvitationTTL:        getEnvAsDuration("INVITATION_TTL", 7*24*time.Hour),
+			InvitationURL:        getEnv("INVITATION_URL", ""),
+			MFAEncryptionKey:     getEnv("MFA_ENCRYPTION_KEY", ""),
+			MFAIssuer:            getEnv("MFA_ISSUER", "project_maker"),
+			MFATokenTTL:          getEnvAsDuration("MFA_TOKEN_TTL", 5*time.Minute),
+			RequireAdminMFA:      getEnvAsBool("REQUIRE_ADMIN_MFA", false),
+		},
+		Retention: RetentionConfig{
+			DeletedUserRetention: getEnvAsDuration("DELETED_USER_RETENTION", 30*24*time.Hour),
+			PurgeInterval:        getEnvAsDuration("PURGE_INTERVAL", time.Hour),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+			FilePath:     getEnv("MAIL_FILE_PATH", "mail.log"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 25),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		Password: PasswordPolicyConfig{
+			MinLength:     getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:     getEnvAsInt("PASSWORD_MAX_LENGTH", 64),
+			RequireUpper:  getEnvAsBool("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:  getEnvAsBool("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:  getEnvAsBool("PASSWORD_REQUIRE_DIGIT", false),
+			RequireSymbol: getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+			HistorySize:   getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+			BlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+		},
+		PasswordHashing: PasswordHashingConfig{
+			Memory:     getEnvAsInt("PASSWORD_HASH_MEMORY", 64*1024),
+			Iterations: getEnvAsInt("PASSWORD_HASH_ITERATIONS", 3),
+			Threads:    getEnvAsInt("PASSWORD_HASH_THREADS", 4),
+		},
+		Lockout: LockoutConfig{
+			MaxAttempts:   getEnvAsInt("LOCKOUT_MAX_ATTEMPTS", 5),
+			MaxIPAttempts: getEnvAsInt("LOCKOUT_MAX_IP_ATTEMPTS", 20),
+			Duration:      getEnvAsDuration("LOCKOUT_DURATION", time.Minute),
+			MaxDuration:   getEnvAsDuration("LOCKOUT_MAX_DURATION", time.Hour),
+			ResetAfter:    getEnvAsDuration("LOCKOUT_RESET_AFTER", 15*time.Minute),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:            getEnv("OIDC_ISSUER_URL", "http://localhost:8081"),
+			SigningKeyFile:       getEnv("OIDC_SIGNING_KEY_FILE", ""),
+			LoginURL:             getEnv("OIDC_LOGIN_URL", ""),
+			AuthorizationCodeTTL: getEnvAsDuration("OIDC_CODE_TTL", time.Minute),
+			IDTokenTTL:           getEnvAsDuration("OIDC_ID_TOKEN_TTL", time.Hour),
+		},
+		SocialLogin: SocialLoginConfig{
+			BaseURL:    getEnv("SOCIAL_LOGIN_BASE_URL", "http://localhost:8081"),
+			SuccessURL: getEnv("SOCIAL_LOGIN_SUCCESS_URL", ""),
+			StateTTL:   getEnvAsDuration("SOCIAL_LOGIN_STATE_TTL", 10*time.Minute),
+			Providers:  getSocialProviders(),
+		},
+	}
+}
+
+// Validate checks that the configuration is safe to run the server with
+func (c *Config) Validate() error {
+	if err := requireSecret("JWT_SECRET", c.Auth.JWTSecret); err != nil {
+		return err
+	}
+	if err := requireSecret("MFA_ENCRYPTION_KEY", c.Auth.MFAEncryptionKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// requireSecret checks that the secret read from an environment variable was
+// set to something else than the documented placeholder
+func requireSecret(key, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s must be set", key)
+	}
+	if value == placeholderSecret {
+		return fmt.Errorf("%s must be changed from the placeholder %q", key, placeholderSecret)
+	}
+	return nil
+}
+
+// getSocialProviders reads the providers named in the comma separated
+// SOCIAL_LOGIN_PROVIDERS, each configured by SOCIAL_LOGIN_<NAME>_ISSUER_URL,
+// _CLIENT_ID, _CLIENT_SECRET and _SCOPES
+func getSocialProviders() []SocialProviderConfig {
+	var providers []SocialProviderConfig
+	for _, name := range strings.Split(getEnv("SOCIAL_LOGIN_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "SOCIAL_LOGIN_" + strings.ToUpper(name) + "_"
+		providers = append(providers, SocialProviderConfig{
+			Name:         name,
+			IssuerURL:    getEnv(prefix+"ISSUER_URL", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getEnv(prefix+"SCOPES", "openid profile email"),
+		})
 	}
+	return providers
 }
 
 // Helper function to read an environment variable or return a default value
@@ -73,3 +324,23 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// Helper function to read an environment variable as a boolean or return a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// Helper function to read an environment variable as a duration or return a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
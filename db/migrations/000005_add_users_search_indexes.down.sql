@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP INDEX IF EXISTS idx_users_email_lower;
+DROP INDEX IF EXISTS idx_users_username_lower;
+DROP INDEX IF EXISTS idx_users_search_trgm;
+
+DROP EXTENSION IF EXISTS pg_trgm;
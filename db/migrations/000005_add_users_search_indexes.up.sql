@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Free-text search across username, email and full name
+CREATE INDEX idx_users_search_trgm ON users
+    USING GIN ((username || ' ' || email || ' ' || full_name) gin_trgm_ops)
+    WHERE deleted_at IS NULL;
+
+-- Case-insensitive prefix filters
+CREATE INDEX idx_users_username_lower ON users (lower(username) text_pattern_ops) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_email_lower ON users (lower(email) text_pattern_ops) WHERE deleted_at IS NULL;
+
+-- Sorting and range filters on creation time
+CREATE INDEX idx_users_created_at ON users (created_at, id) WHERE deleted_at IS NULL;
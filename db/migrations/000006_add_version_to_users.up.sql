@@ -0,0 +1,2 @@
+-- Incremented on every update so concurrent writers can detect lost updates
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
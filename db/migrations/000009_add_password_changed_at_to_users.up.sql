@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing passwords were last set when the user was created, as far as we know
+UPDATE users SET password_changed_at = created_at;
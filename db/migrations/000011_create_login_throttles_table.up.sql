@@ -0,0 +1,10 @@
+-- Failed login attempts, tracked per user ID and per client IP
+CREATE TABLE IF NOT EXISTS login_throttles (
+    subject_type VARCHAR(10) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (subject_type, subject)
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS organization_memberships;
+
+DROP INDEX IF EXISTS idx_users_username_active;
+CREATE UNIQUE INDEX idx_users_username_active ON users(username) WHERE deleted_at IS NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organizations;
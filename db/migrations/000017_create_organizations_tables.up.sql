@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Existing users, and users signing up on their own, belong to the default organization
+INSERT INTO organizations (slug, name) VALUES ('default', 'Default');
+
+ALTER TABLE users ADD COLUMN organization_id INTEGER REFERENCES organizations(id);
+UPDATE users SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE users ALTER COLUMN organization_id SET NOT NULL;
+
+-- Usernames are only unique within an organization, emails stay globally unique
+DROP INDEX IF EXISTS idx_users_username_active;
+CREATE UNIQUE INDEX idx_users_username_active ON users(organization_id, username) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS organization_memberships (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_memberships_user_id ON organization_memberships(user_id);
+
+INSERT INTO organization_memberships (organization_id, user_id, role)
+SELECT organization_id, id, 'member' FROM users;
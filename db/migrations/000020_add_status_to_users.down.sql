@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_users_status;
+
+ALTER TABLE users DROP COLUMN IF EXISTS status_changed_by;
+ALTER TABLE users DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS user_status;
@@ -0,0 +1,12 @@
+CREATE TYPE user_status AS ENUM ('pending', 'active', 'suspended', 'deactivated');
+
+ALTER TABLE users ADD COLUMN status user_status NOT NULL DEFAULT 'active';
+-- Why the status last changed, when and by whom
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_changed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN status_changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+-- Users stay pending until they verify their email address
+UPDATE users SET status = 'pending' WHERE email_verified_at IS NULL;
+
+CREATE INDEX idx_users_status ON users(status) WHERE deleted_at IS NULL;
@@ -0,0 +1,7 @@
+-- Only the roles granted in the organization of each user are kept
+DELETE FROM user_roles ur USING users u WHERE u.id = ur.user_id AND ur.organization_id <> u.organization_id;
+
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id);
+
+ALTER TABLE user_roles DROP COLUMN IF EXISTS organization_id;
@@ -0,0 +1,8 @@
+-- Roles are granted within an organization, so that being an admin of one
+-- organization grants nothing in the others the user is a member of
+ALTER TABLE user_roles ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE user_roles ur SET organization_id = u.organization_id FROM users u WHERE u.id = ur.user_id;
+ALTER TABLE user_roles ALTER COLUMN organization_id SET NOT NULL;
+
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, organization_id, role_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_oauth_clients_organization_id;
+
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS organization_id;
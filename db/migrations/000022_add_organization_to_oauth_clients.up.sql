@@ -0,0 +1,10 @@
+-- Clients belong to the organization of the admin who registered them, and
+-- only its users can authorize them
+ALTER TABLE oauth_clients ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE oauth_clients c SET organization_id = COALESCE(
+    (SELECT u.organization_id FROM users u WHERE u.id = c.created_by),
+    (SELECT id FROM organizations WHERE slug = 'default')
+);
+ALTER TABLE oauth_clients ALTER COLUMN organization_id SET NOT NULL;
+
+CREATE INDEX idx_oauth_clients_organization_id ON oauth_clients(organization_id);
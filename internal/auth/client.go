@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// forwardedForHeader is the metadata key under which the gateway forwards
+// the address of HTTP clients
+const forwardedForHeader = "x-forwarded-for"
+
+// Metadata keys carrying the user agent of gRPC clients, and of HTTP clients
+// as forwarded by the gateway
+const (
+	userAgentHeader        = "user-agent"
+	gatewayUserAgentHeader = "grpcgateway-user-agent"
+)
+
+// Client describes where a request comes from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// NewClientContext returns a copy of ctx carrying the given client
+func NewClientContext(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFromContext returns the client stored in ctx, if any
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	c, ok := ctx.Value(clientKey{}).(*Client)
+	return c, ok
+}
+
+// ClientUnaryServerInterceptor returns a gRPC interceptor that stores the
+// client of every call in the request context. The address of the peer is
+// used, except for calls made over loopback by the gateway, whose
+// X-Forwarded-For header ends with the address of the HTTP client.
+func ClientUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(NewClientContext(ctx, &Client{
+			IP:        clientIP(ctx),
+			UserAgent: clientUserAgent(ctx),
+		}), req)
+	}
+}
+
+// clientUserAgent returns the user agent of the client of a call, preferring
+// the one of the HTTP client over the one of the gateway
+func clientUserAgent(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{gatewayUserAgentHeader, userAgentHeader} {
+		if values := md.Get(key); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// clientIP returns the IP address of the client of a call
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	// Only the gateway runs on the same host, so other peers cannot spoof
+	// their address with the header
+	if parsed := net.ParseIP(ip); parsed == nil || !parsed.IsLoopback() {
+		return ip
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(forwardedForHeader); len(values) > 0 {
+		addrs := strings.Split(values[len(values)-1], ",")
+		if forwarded := strings.TrimSpace(addrs[len(addrs)-1]); forwarded != "" {
+			return forwarded
+		}
+	}
+
+	return ip
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/tenant"
+)
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	UserID   int64
+	Username string
+	// SessionID is the session of the access token the user authenticated with
+	SessionID int64
+	// APIKeyID is the API key the caller authenticated with, if any
+	APIKeyID int64
+	// OrganizationID is the organization the caller acts in, the only one
+	// whose users they can see: the organization of the user, or another one
+	// they are a member of
+	OrganizationID int64
+	// Scopes limits the permissions of API key callers to those listed.
+	// Nil means no limit beyond the roles of the user.
+	Scopes []user.Permission
+	// System marks trusted internal callers, such as background jobs, that
+	// are not acting on behalf of a user and bypass permission checks
+	System bool
+}
+
+// HasScope reports whether the scopes of the principal allow the permission
+func (p *Principal) HasScope(permission user.Permission) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, scope := range p.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// SystemContext returns a copy of ctx carrying the system principal
+func SystemContext(ctx context.Context) context.Context {
+	return NewContext(ctx, &Principal{System: true})
+}
+
+// NewContext returns a copy of ctx carrying the given principal, scoped to
+// the organization of the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	if p.OrganizationID != 0 {
+		ctx = tenant.NewContext(ctx, p.OrganizationID)
+	}
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
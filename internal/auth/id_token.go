@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKeyBits is the size of generated signing keys
+const signingKeyBits = 2048
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. The profile
+// and email claims are only set when their scope was granted.
+type IDTokenClaims struct {
+	Nonce             string           `json:"nonce,omitempty"`
+	AuthTime          *jwt.NumericDate `json:"auth_time,omitempty"`
+	PreferredUsername string           `json:"preferred_username,omitempty"`
+	Name              string           `json:"name,omitempty"`
+	Email             string           `json:"email,omitempty"`
+	EmailVerified     *bool            `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JSONWebKey is the public half of a signing key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// SigningKey signs ID tokens with RS256, so that clients can verify them with
+// the public key published as a JSON Web Key
+type SigningKey struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+// NewSigningKey creates a signing key from an RSA private key. Its key ID is
+// the JWK thumbprint of the public key (RFC 7638).
+func NewSigningKey(key *rsa.PrivateKey) *SigningKey {
+	k := &SigningKey{key: key}
+	jwk := k.JWK()
+	thumbprint := sha256.Sum256([]byte(fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.Exponent, jwk.Modulus)))
+	k.keyID = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	return k
+}
+
+// GenerateSigningKey creates a signing key from a new random RSA key
+func GenerateSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(key), nil
+}
+
+// ParseSigningKey creates a signing key from a PEM encoded RSA private key in
+// PKCS #1 or PKCS #8 form
+func ParseSigningKey(pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewSigningKey(key), nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	return NewSigningKey(key), nil
+}
+
+// KeyID returns the ID of the key, set in the header of signed tokens
+func (k *SigningKey) KeyID() string {
+	return k.keyID
+}
+
+// PublicKey returns the key verifying signed tokens
+func (k *SigningKey) PublicKey() *rsa.PublicKey {
+	return &k.key.PublicKey
+}
+
+// JWK returns the public key in JWK format
+func (k *SigningKey) JWK() JSONWebKey {
+	return JSONWebKey{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KeyID:     k.keyID,
+		Modulus:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+	}
+}
+
+// SignIDToken signs the claims of an ID token
+func (k *SigningKey) SignIDToken(claims *IDTokenClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.keyID
+	return token.SignedString(k.key)
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationHeader is the metadata key carrying bearer tokens. The gateway
+// forwards the HTTP Authorization header under the same key.
+const authorizationHeader = "authorization"
+
+// APIKeyHeader is the metadata key carrying API keys. The gateway forwards the
+// HTTP X-Api-Key header under the same key.
+const APIKeyHeader = "x-api-key"
+
+// OrganizationHeader is the metadata key selecting the organization a call
+// acts in, when it is not the organization of the caller. The gateway
+// forwards the HTTP X-Organization-Id header under the same key.
+const OrganizationHeader = "x-organization-id"
+
+// SessionValidator checks that the session an access token belongs to has not
+// been revoked
+type SessionValidator interface {
+	// IsSessionActive reports whether the session of the user is still active
+	IsSessionActive(ctx context.Context, userID, sessionID int64) (bool, error)
+}
+
+// APIKeyAuthenticator resolves API keys into the principal they act as
+type APIKeyAuthenticator interface {
+	// AuthenticateAPIKey returns the principal of an active API key, or
+	// ErrInvalidToken if the key is unknown, revoked or expired
+	AuthenticateAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// MembershipChecker checks the organizations users are members of
+type MembershipChecker interface {
+	// IsMember reports whether the user is a member of the organization
+	IsMember(ctx context.Context, organizationID, userID int64) (bool, error)
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that authenticates every
+// call with an API key or a bearer access token, except for the methods
+// listed in publicMethods. The methods listed in optionalMethods can also be
+// called anonymously, but authenticate the caller when credentials are sent.
+// Tokens of sessions that are no longer active are rejected. The
+// authenticated principal is stored in the request context, scoped to the
+// organization selected by OrganizationHeader if the caller is a member of it.
+func UnaryServerInterceptor(tokens *TokenManager, sessions SessionValidator, apiKeys APIKeyAuthenticator, memberships MembershipChecker, publicMethods, optionalMethods map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if optionalMethods[info.FullMethod] && !hasCredentials(ctx) {
+			return handler(ctx, req)
+		}
+
+		var (
+			principal *Principal
+			err       error
+		)
+		if key, ok := apiKeyFromContext(ctx); ok {
+			principal, err = authenticateAPIKey(ctx, apiKeys, key, info.FullMethod)
+		} else {
+			principal, err = authenticateBearer(ctx, tokens, sessions, info.FullMethod)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := selectOrganization(ctx, memberships, principal, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(NewContext(ctx, principal), req)
+	}
+}
+
+// selectOrganization scopes the principal to the organization given in the
+// OrganizationHeader of a call, if any. Callers may only act in the
+// organizations they are members of.
+func selectOrganization(ctx context.Context, memberships MembershipChecker, principal *Principal, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(OrganizationHeader)
+	if len(values) == 0 || values[0] == "" {
+		return nil
+	}
+
+	organizationID, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || organizationID <= 0 {
+		return status.Errorf(codes.InvalidArgument, "%s must be the ID of an organization", OrganizationHeader)
+	}
+
+	if organizationID == principal.OrganizationID {
+		return nil
+	}
+
+	member, err := memberships.IsMember(ctx, organizationID, principal.UserID)
+	if err != nil {
+		log.Printf("%s: failed to check membership of organization %d: %v", method, organizationID, err)
+		return status.Error(codes.Internal, "internal error")
+	}
+	if !member {
+		return status.Errorf(codes.PermissionDenied, "not a member of organization %d", organizationID)
+	}
+
+	principal.OrganizationID = organizationID
+	return nil
+}
+
+// hasCredentials reports whether a call carries an API key or an
+// authorization header
+func hasCredentials(ctx context.Context) bool {
+	if _, ok := apiKeyFromContext(ctx); ok {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return len(md.Get(authorizationHeader)) > 0
+}
+
+// authenticateAPIKey returns the principal of an API key
+func authenticateAPIKey(ctx context.Context, apiKeys APIKeyAuthenticator, key, method string) (*Principal, error) {
+	principal, err := apiKeys.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired API key")
+		}
+		log.Printf("%s: failed to check API key: %v", method, err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return principal, nil
+}
+
+// authenticateBearer returns the principal of the bearer access token of a call
+func authenticateBearer(ctx context.Context, tokens *TokenManager, sessions SessionValidator, method string) (*Principal, error) {
+	token, err := bearerTokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, err := AuthenticateAccessToken(ctx, tokens, sessions, token)
+	switch {
+	case errors.Is(err, ErrSessionEnded):
+		return nil, status.Error(codes.Unauthenticated, "session has been revoked")
+	case errors.Is(err, ErrInvalidToken):
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired access token")
+	case err != nil:
+		log.Printf("%s: failed to authenticate access token: %v", method, err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return principal, nil
+}
+
+// AuthenticateAccessToken returns the principal of a signed access token. It
+// returns ErrInvalidToken for invalid or expired tokens and ErrSessionEnded
+// for tokens of sessions that are no longer active.
+func AuthenticateAccessToken(ctx context.Context, tokens *TokenManager, sessions SessionValidator, token string) (*Principal, error) {
+	claims, err := tokens.ParseAccessToken(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return sessionPrincipal(ctx, sessions, claims)
+}
+
+// AuthenticateClientAccessToken returns the principal of an access token
+// issued to an OAuth client, along with the claims of the token. It returns
+// the same errors as AuthenticateAccessToken.
+func AuthenticateClientAccessToken(ctx context.Context, tokens *TokenManager, sessions SessionValidator, token string) (*Principal, *Claims, error) {
+	claims, err := tokens.ParseClientAccessToken(token)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	principal, err := sessionPrincipal(ctx, sessions, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	return principal, claims, nil
+}
+
+// sessionPrincipal returns the principal of the claims of a token, checking
+// that its session is still active
+func sessionPrincipal(ctx context.Context, sessions SessionValidator, claims *Claims) (*Principal, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	active, err := sessions.IsSessionActive(ctx, userID, claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("check session %d: %w", claims.SessionID, err)
+	}
+	if !active {
+		return nil, ErrSessionEnded
+	}
+
+	return &Principal{
+		UserID:         userID,
+		Username:       claims.Username,
+		SessionID:      claims.SessionID,
+		OrganizationID: claims.OrganizationID,
+	}, nil
+}
+
+// apiKeyFromContext extracts the API key from the incoming metadata, if any
+func apiKeyFromContext(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(APIKeyHeader)
+	if len(values) == 0 || values[0] == "" {
+		return "", false
+	}
+	return values[0], true
+}
+
+// bearerTokenFromContext extracts the bearer token from the incoming metadata
+func bearerTokenFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "missing authorization header")
+	}
+
+	token, ok := BearerToken(values[0])
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization header must use the Bearer scheme")
+	}
+
+	return token, nil
+}
+
+// BearerToken extracts the token from an authorization header using the
+// Bearer scheme
+func BearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// Common token errors
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrSessionEnded is returned for access tokens of revoked or expired
+	// sessions
+	ErrSessionEnded = fmt.Errorf("%w: session has ended", ErrInvalidToken)
+)
+
+// mfaAudience is the audience of MFA tokens, which only allow completing a
+// login with a second factor. Access tokens have no audience, while those
+// issued to OAuth clients have the client as audience.
+const mfaAudience = "mfa"
+
+// Claims are the JWT claims carried by an access token
+type Claims struct {
+	Username string `json:"username"`
+	// SessionID is the session an access token belongs to
+	SessionID int64 `json:"sid,omitempty"`
+	// OrganizationID is the organization of the user, which scopes the
+	// requests made with the token that do not select another one
+	OrganizationID int64 `json:"org,omitempty"`
+	// ClientID is the OAuth client a client access token was issued to
+	ClientID string `json:"client_id,omitempty"`
+	// Scope is the space separated list of scopes granted to the client
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the ID of the user the token was issued to
+func (c *Claims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// HasScope reports whether the scope was granted to the client of the token
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenManager issues and validates signed access, client access and MFA
+// tokens
+type TokenManager struct {
+	secret    []byte
+	issuer    string
+	accessTTL time.Duration
+}
+
+// NewTokenManager creates a new token manager
+func NewTokenManager(secret, issuer string, accessTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:    []byte(secret),
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}
+}
+
+// AccessTokenTTL returns the lifetime of issued access tokens
+func (m *TokenManager) AccessTokenTTL() time.Duration {
+	return m.accessTTL
+}
+
+// IssueAccessToken creates a signed access token for a session of the given user
+func (m *TokenManager) IssueAccessToken(u *user.User, sessionID int64) (string, error) {
+	now := time.Now().UTC()
+	claims := &Claims{
+		Username:       u.Username,
+		SessionID:      sessionID,
+		OrganizationID: u.OrganizationID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(u.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseAccessToken validates a signed access token and returns its claims
+func (m *TokenManager) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// MFA and client access tokens are signed with the same key but must not
+	// grant access to the API
+	if len(claims.Audience) > 0 || claims.ClientID != "" {
+		return nil, fmt.Errorf("%w: unexpected audience %v", ErrInvalidToken, claims.Audience)
+	}
+
+	// Tokens issued before organizations existed would not be scoped to one
+	if claims.OrganizationID == 0 {
+		return nil, fmt.Errorf("%w: missing organization", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// IssueClientAccessToken creates a signed access token for a session of the
+// given user, issued to an OAuth client with the granted scope. It only
+// grants access to the claims of the scope at the userinfo endpoint.
+func (m *TokenManager) IssueClientAccessToken(u *user.User, sessionID int64, clientID, scope string) (string, error) {
+	now := time.Now().UTC()
+	claims := &Claims{
+		Username:       u.Username,
+		SessionID:      sessionID,
+		OrganizationID: u.OrganizationID,
+		ClientID:       clientID,
+		Scope:          scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(u.ID, 10),
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseClientAccessToken validates a signed client access token and returns
+// its claims
+func (m *TokenManager) ParseClientAccessToken(tokenString string) (*Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ClientID == "" || len(claims.Audience) != 1 || claims.Audience[0] != claims.ClientID {
+		return nil, fmt.Errorf("%w: not issued to a client", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// IssueMFAToken creates a signed token proving that the given user passed the
+// first login step. It can only be exchanged for a token pair along with a
+// second factor.
+func (m *TokenManager) IssueMFAToken(u *user.User, ttl time.Duration) (string, error) {
+	now := time.Now().UTC()
+	claims := &Claims{
+		Username: u.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(u.ID, 10),
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseMFAToken validates a signed MFA token and returns its claims
+func (m *TokenManager) ParseMFAToken(tokenString string) (*Claims, error) {
+	return m.parse(tokenString, jwt.WithAudience(mfaAudience))
+}
+
+// parse validates a signed token issued by the manager and returns its claims
+func (m *TokenManager) parse(tokenString string, opts ...jwt.ParserOption) (*Claims, error) {
+	claims := &Claims{}
+	opts = append([]jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	}, opts...)
+
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return m.secret, nil
+		},
+		opts...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return claims, nil
+}
+
+// GenerateOpaqueToken returns a random URL-safe token together with its hash
+func GenerateOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the SHA-256 hex digest of an opaque token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
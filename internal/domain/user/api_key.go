@@ -0,0 +1,53 @@
+package user
+
+import "time"
+
+// APIKeyPrefix starts every API key, so that leaked keys are easy to spot
+const APIKeyPrefix = "pmk_"
+
+// apiKeyDisplayLength is how many leading characters of a key are kept in
+// clear to tell keys apart
+const apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+// APIKey lets services call the API on behalf of a user without their
+// password. A key only grants the permissions in its scopes that the user
+// holds.
+type APIKey struct {
+	ID     int64  `db:"id"`
+	UserID int64  `db:"user_id"`
+	Name   string `db:"name"`
+	// Prefix is the start of the key, shown to identify it
+	Prefix     string       `db:"prefix"`
+	KeyHash    string       `db:"key_hash"`
+	Scopes     []Permission `db:"-"`
+	ExpiresAt  *time.Time   `db:"expires_at"`
+	LastUsedAt *time.Time   `db:"last_used_at"`
+	CreatedBy  *int64       `db:"created_by"`
+	CreatedAt  time.Time    `db:"created_at"`
+	RevokedAt  *time.Time   `db:"revoked_at"`
+}
+
+// NewAPIKey creates a new API key of a user from the key and its hash. A nil
+// expiresAt makes the key valid until revoked.
+func NewAPIKey(userID int64, name, key, keyHash string, scopes []Permission, expiresAt *time.Time, createdBy *int64) *APIKey {
+	prefix := key
+	if len(prefix) > apiKeyDisplayLength {
+		prefix = prefix[:apiKeyDisplayLength]
+	}
+
+	return &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// IsActive reports whether the key is neither revoked nor expired
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || time.Now().UTC().Before(*k.ExpiresAt))
+}
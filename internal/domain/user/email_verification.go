@@ -0,0 +1,37 @@
+package user
+
+import "time"
+
+// EmailVerificationToken is a single-use token proving that a user controls
+// the email address it was sent to
+type EmailVerificationToken struct {
+	ID        int64      `db:"id"`
+	UserID    int64      `db:"user_id"`
+	Email     string     `db:"email"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// NewEmailVerificationToken creates a new verification token for the given user and email
+func NewEmailVerificationToken(userID int64, email, tokenHash string, ttl time.Duration) *EmailVerificationToken {
+	now := time.Now().UTC()
+	return &EmailVerificationToken{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// IsUsed reports whether the token has already been used
+func (t *EmailVerificationToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *EmailVerificationToken) IsExpired() bool {
+	return time.Now().UTC().After(t.ExpiresAt)
+}
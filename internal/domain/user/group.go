@@ -0,0 +1,25 @@
+package user
+
+import "time"
+
+// Group is a named set of users of an organization. Groups can contain other
+// groups, whose members are then members of the containing group too.
+type Group struct {
+	ID             int64     `db:"id"`
+	OrganizationID int64     `db:"organization_id"`
+	Name           string    `db:"name"`
+	Description    string    `db:"description"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// NewGroup creates a new group with the given name and description
+func NewGroup(name, description string) *Group {
+	now := time.Now().UTC()
+	return &Group{
+		Name:        name,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
@@ -0,0 +1,52 @@
+package user
+
+import "time"
+
+// LinkedIdentity links the account of a user at an external OpenID Connect
+// provider to their user, so they can sign in with it
+type LinkedIdentity struct {
+	ID     int64 `db:"id"`
+	UserID int64 `db:"user_id"`
+	// Provider is the name of the configured provider
+	Provider string `db:"provider"`
+	// Issuer and Subject identify the account at the provider
+	Issuer  string `db:"issuer"`
+	Subject string `db:"subject"`
+	// Email is the address the provider last reported for the account
+	Email       string     `db:"email"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastLoginAt *time.Time `db:"last_login_at"`
+}
+
+// NewLinkedIdentity creates a new identity of the user, as just used to sign in
+func NewLinkedIdentity(userID int64, provider, issuer, subject, email string) *LinkedIdentity {
+	now := time.Now().UTC()
+	return &LinkedIdentity{
+		UserID:      userID,
+		Provider:    provider,
+		Issuer:      issuer,
+		Subject:     subject,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+}
+
+// ExternalLoginState is a login at an external provider in progress. It is
+// looked up by the hash of the state parameter when the provider redirects
+// back, and holds what is needed to check its response.
+type ExternalLoginState struct {
+	ID           int64     `db:"id"`
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	Device       string    `db:"device"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// IsExpired reports whether the login took longer than allowed
+func (s *ExternalLoginState) IsExpired() bool {
+	return time.Now().UTC().After(s.ExpiresAt)
+}
@@ -0,0 +1,45 @@
+package user
+
+import "time"
+
+// Invitation lets someone create their account in an organization by
+// choosing their own password. The roles of the invitation are granted to the
+// account on top of RoleSelf.
+type Invitation struct {
+	ID             int64      `db:"id"`
+	OrganizationID int64      `db:"organization_id"`
+	Email          string     `db:"email"`
+	Roles          []Role     `db:"-"`
+	TokenHash      string     `db:"token_hash"`
+	InvitedBy      *int64     `db:"invited_by"`
+	ExpiresAt      time.Time  `db:"expires_at"`
+	AcceptedAt     *time.Time `db:"accepted_at"`
+	AcceptedUserID *int64     `db:"accepted_user_id"`
+	RevokedAt      *time.Time `db:"revoked_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+// NewInvitation creates a new invitation of the given email to the
+// organization, identified by the hash of its token
+func NewInvitation(organizationID int64, email string, roles []Role, tokenHash string, ttl time.Duration, invitedBy *int64) *Invitation {
+	now := time.Now().UTC()
+	return &Invitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Roles:          roles,
+		TokenHash:      tokenHash,
+		InvitedBy:      invitedBy,
+		ExpiresAt:      now.Add(ttl),
+		CreatedAt:      now,
+	}
+}
+
+// IsExpired reports whether the invitation is past its expiry time
+func (i *Invitation) IsExpired() bool {
+	return time.Now().UTC().After(i.ExpiresAt)
+}
+
+// IsPending reports whether the invitation can still be accepted
+func (i *Invitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && !i.IsExpired()
+}
@@ -0,0 +1,70 @@
+package user
+
+import "time"
+
+// Subjects whose failed login attempts are tracked
+const (
+	ThrottleSubjectUser = "user"
+	ThrottleSubjectIP   = "ip"
+)
+
+// LoginThrottle counts the failed login attempts of a user or a client IP
+type LoginThrottle struct {
+	SubjectType    string `db:"subject_type"`
+	Subject        string `db:"subject"`
+	FailedAttempts int    `db:"failed_attempts"`
+	// Lockouts is how many times the subject was locked since its attempts
+	// were last reset
+	Lockouts     int        `db:"lockouts"`
+	LockedUntil  *time.Time `db:"locked_until"`
+	LastFailedAt time.Time  `db:"last_failed_at"`
+}
+
+// IsLocked reports whether the subject is currently locked
+func (t *LoginThrottle) IsLocked() bool {
+	return t.RetryAfter() > 0
+}
+
+// RetryAfter returns how long the subject stays locked
+func (t *LoginThrottle) RetryAfter() time.Duration {
+	if t.LockedUntil == nil {
+		return 0
+	}
+	return time.Until(*t.LockedUntil)
+}
+
+// LockoutPolicy decides when and for how long repeated failed logins lock a
+// user or a client IP
+type LockoutPolicy struct {
+	// MaxAttempts is the number of failed attempts locking a user
+	MaxAttempts int
+	// MaxIPAttempts is the number of failed attempts locking a client IP
+	MaxIPAttempts int
+	// BaseDuration is the length of the first lock. Each following lock lasts
+	// twice as long as the previous one, up to MaxDuration.
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	// ResetAfter is how long without a failed attempt resets the counters
+	ResetAfter time.Duration
+}
+
+// Threshold returns the number of failed attempts locking the subject type
+func (p *LockoutPolicy) Threshold(subjectType string) int {
+	if subjectType == ThrottleSubjectIP {
+		return p.MaxIPAttempts
+	}
+	return p.MaxAttempts
+}
+
+// LockDuration returns how long to lock a subject that was already locked
+// the given number of times
+func (p *LockoutPolicy) LockDuration(lockouts int) time.Duration {
+	d := p.BaseDuration
+	for i := 0; i < lockouts && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	if d > p.MaxDuration {
+		d = p.MaxDuration
+	}
+	return d
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// OpenID Connect scopes understood by the provider
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// CodeChallengeMethodS256 is the only PKCE method accepted, as the plain
+// method does not protect against intercepted codes
+const CodeChallengeMethodS256 = "S256"
+
+// OAuthClient is an application using the service as its OpenID Connect
+// provider
+type OAuthClient struct {
+	ID int64 `db:"id"`
+	// OrganizationID is the organization whose users can authorize the client
+	OrganizationID int64  `db:"organization_id"`
+	ClientID       string `db:"client_id"`
+	Name           string `db:"name"`
+	// SecretHash is nil for public clients, which cannot keep a secret
+	SecretHash   *string   `db:"secret_hash"`
+	RedirectURIs []string  `db:"-"`
+	CreatedBy    *int64    `db:"created_by"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// IsConfidential reports whether the client authenticates with a secret
+func (c *OAuthClient) IsConfidential() bool {
+	return c.SecretHash != nil
+}
+
+// AllowsRedirectURI reports whether the URI is registered for the client.
+// URIs must match exactly.
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationCode is a single-use code a client exchanges for the tokens
+// of the user who authorized it
+type AuthorizationCode struct {
+	ID          int64  `db:"id"`
+	CodeHash    string `db:"code_hash"`
+	ClientID    string `db:"client_id"`
+	UserID      int64  `db:"user_id"`
+	RedirectURI string `db:"redirect_uri"`
+	// Scope is the space separated list of granted scopes
+	Scope         string `db:"scope"`
+	Nonce         string `db:"nonce"`
+	CodeChallenge string `db:"code_challenge"`
+	// AuthTime is when the user logged in
+	AuthTime  time.Time  `db:"auth_time"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// IsUsed reports whether the code has already been exchanged
+func (c *AuthorizationCode) IsUsed() bool {
+	return c.UsedAt != nil
+}
+
+// IsExpired reports whether the code is past its expiry time
+func (c *AuthorizationCode) IsExpired() bool {
+	return time.Now().UTC().After(c.ExpiresAt)
+}
+
+// HasScope reports whether the scope was granted with the code
+func (c *AuthorizationCode) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyCodeVerifier reports whether the PKCE verifier given when exchanging
+// the code matches the S256 challenge given when requesting it
+func (c *AuthorizationCode) VerifyCodeVerifier(verifier string) bool {
+	expected := CodeChallengeS256(verifier)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(c.CodeChallenge)) == 1
+}
+
+// CodeChallengeS256 returns the S256 PKCE challenge of a verifier
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
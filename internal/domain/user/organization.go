@@ -0,0 +1,84 @@
+package user
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultOrganizationSlug is the slug of the organization of users who sign
+// up on their own, and of the users that existed before organizations
+const DefaultOrganizationSlug = "default"
+
+// Organization is a tenant: a customer whose users are kept apart from the
+// users of every other organization
+type Organization struct {
+	ID int64 `db:"id"`
+	// Slug identifies the organization in URLs and at login
+	Slug      string    `db:"slug"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// NewOrganization creates a new organization with the given slug and name
+func NewOrganization(slug, name string) *Organization {
+	now := time.Now().UTC()
+	return &Organization{
+		Slug:      slug,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IsDefault reports whether the organization is the default organization
+func (o *Organization) IsDefault() bool {
+	return o.Slug == DefaultOrganizationSlug
+}
+
+// OrganizationRole is the role of a member within an organization
+type OrganizationRole string
+
+// Organization roles
+const (
+	// OrganizationRoleOwner can delete the organization
+	OrganizationRoleOwner OrganizationRole = "owner"
+	// OrganizationRoleAdmin can update the organization
+	OrganizationRoleAdmin OrganizationRole = "admin"
+	// OrganizationRoleMember can see the organization
+	OrganizationRoleMember OrganizationRole = "member"
+)
+
+// ParseOrganizationRole converts a role name into an OrganizationRole,
+// rejecting unknown roles
+func ParseOrganizationRole(name string) (OrganizationRole, error) {
+	role := OrganizationRole(name)
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return role, nil
+	}
+	return "", fmt.Errorf("unknown organization role %q", name)
+}
+
+// CanManage reports whether the role allows updating the organization
+func (r OrganizationRole) CanManage() bool {
+	return r == OrganizationRoleOwner || r == OrganizationRoleAdmin
+}
+
+// Membership grants a user a role within an organization
+type Membership struct {
+	OrganizationID int64            `db:"organization_id"`
+	UserID         int64            `db:"user_id"`
+	Role           OrganizationRole `db:"role"`
+	CreatedAt      time.Time        `db:"created_at"`
+}
+
+// NewMembership creates a new membership of the user in the organization
+func NewMembership(organizationID, userID int64, role OrganizationRole) *Membership {
+	return &Membership{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+		CreatedAt:      time.Now().UTC(),
+	}
+}
@@ -0,0 +1,238 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash is returned when verifying a hash of an unknown algorithm
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// PasswordHasher hashes passwords into self-describing strings that encode
+// the algorithm and its parameters
+type PasswordHasher interface {
+	// Hash returns the hash of a password
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash
+	Verify(hash, password string) (bool, error)
+	// Supports reports whether the hash uses the algorithm of the hasher
+	Supports(hash string) bool
+	// NeedsRehash reports whether the hash should be replaced by a new one
+	// from this hasher, because of its algorithm or parameters
+	NeedsRehash(hash string) bool
+}
+
+// DefaultPasswordHasher returns a hasher producing argon2id hashes with the
+// default parameters and still verifying legacy bcrypt hashes
+func DefaultPasswordHasher() *MultiHasher {
+	return NewMultiHasher(NewArgon2idHasher(DefaultArgon2idParams), NewBcryptHasher(bcrypt.DefaultCost))
+}
+
+// MultiHasher hashes new passwords with a preferred hasher and verifies hashes
+// of any of its hashers. Hashes of a legacy hasher need a rehash.
+type MultiHasher struct {
+	preferred PasswordHasher
+	legacy    []PasswordHasher
+}
+
+// NewMultiHasher creates a hasher preferring the first hasher
+func NewMultiHasher(preferred PasswordHasher, legacy ...PasswordHasher) *MultiHasher {
+	return &MultiHasher{preferred: preferred, legacy: legacy}
+}
+
+// Hash returns the hash of a password from the preferred hasher
+func (h *MultiHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify reports whether the password matches the hash, using the hasher
+// that produced it
+func (h *MultiHasher) Verify(hash, password string) (bool, error) {
+	for _, hasher := range append([]PasswordHasher{h.preferred}, h.legacy...) {
+		if hasher.Supports(hash) {
+			return hasher.Verify(hash, password)
+		}
+	}
+	return false, ErrUnsupportedHash
+}
+
+// Supports reports whether any of the hashers supports the hash
+func (h *MultiHasher) Supports(hash string) bool {
+	if h.preferred.Supports(hash) {
+		return true
+	}
+	for _, hasher := range h.legacy {
+		if hasher.Supports(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash reports whether the hash was not produced by the preferred
+// hasher with its current parameters
+func (h *MultiHasher) NeedsRehash(hash string) bool {
+	return !h.preferred.Supports(hash) || h.preferred.NeedsRehash(hash)
+}
+
+// Argon2idParams are the cost parameters of argon2id
+type Argon2idParams struct {
+	// Memory is in KiB
+	Memory     uint32
+	Iterations uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2idParams follow the second recommended option of RFC 9106
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:     64 * 1024,
+	Iterations: 3,
+	Threads:    4,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+// argon2idPrefix starts every argon2id hash in PHC string format
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idHasher hashes passwords with argon2id, encoding them in PHC string
+// format: $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher creates a new argon2id hasher
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+// Hash returns the argon2id hash of a password with a random salt
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Threads, h.params.KeyLength)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether the password matches an argon2id hash, using the
+// parameters stored in the hash
+func (h *Argon2idHasher) Verify(hash, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+// Supports reports whether the hash is an argon2id hash
+func (h *Argon2idHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// NeedsRehash reports whether the hash uses other parameters than the hasher
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Threads != h.params.Threads ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+// decodeArgon2id parses an argon2id hash in PHC string format
+func decodeArgon2id(hash string) (params Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: argon2 version %q", ErrUnsupportedHash, parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: argon2 parameters %q", ErrUnsupportedHash, parts[3])
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: argon2 salt: %v", ErrUnsupportedHash, err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: argon2 key: %v", ErrUnsupportedHash, err)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt, whose modular crypt format
+// ($2a$10$...) encodes the cost. Passwords longer than 72 bytes cannot be
+// hashed.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a new bcrypt hasher
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+// Hash returns the bcrypt hash of a password
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedBytes), nil
+}
+
+// Verify reports whether the password matches a bcrypt hash
+func (h *BcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Supports reports whether the hash is a bcrypt hash
+func (h *BcryptHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// NeedsRehash reports whether the hash uses another cost than the hasher
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
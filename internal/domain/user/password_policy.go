@@ -0,0 +1,170 @@
+package user
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// commonPasswords is a list of frequently used and breached passwords, one per line
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// Password policy rules reported in PasswordViolation.Rule
+const (
+	PasswordRuleTooShort         = "PASSWORD_TOO_SHORT"
+	PasswordRuleTooLong          = "PASSWORD_TOO_LONG"
+	PasswordRuleMissingUppercase = "PASSWORD_MISSING_UPPERCASE"
+	PasswordRuleMissingLowercase = "PASSWORD_MISSING_LOWERCASE"
+	PasswordRuleMissingDigit     = "PASSWORD_MISSING_DIGIT"
+	PasswordRuleMissingSymbol    = "PASSWORD_MISSING_SYMBOL"
+	PasswordRuleTooCommon        = "PASSWORD_TOO_COMMON"
+	PasswordRuleReused           = "PASSWORD_REUSED"
+)
+
+// PasswordViolation is a rule of the password policy that a password breaks
+type PasswordViolation struct {
+	Rule    string
+	Message string
+}
+
+// PasswordPolicyError is returned for passwords breaking the password policy
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// PasswordPolicy holds the rules passwords have to follow. Lengths are
+// counted in characters, so passphrases with spaces or non-ASCII letters
+// are accepted.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// HistorySize is how many of their previous passwords users cannot reuse
+	HistorySize int
+
+	blocklist map[string]struct{}
+}
+
+// DefaultPasswordPolicy returns a policy requiring 8 to 64 characters that
+// are not a common password, and rejecting the last 5 passwords of a user
+func DefaultPasswordPolicy() *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength:   8,
+		MaxLength:   64,
+		HistorySize: 5,
+		blocklist:   make(map[string]struct{}),
+	}
+	// The embedded list is well formed, so loading it cannot fail
+	_ = p.LoadBlocklist(strings.NewReader(commonPasswords))
+	return p
+}
+
+// LoadBlocklist adds the passwords read from r, one per line, to the list of
+// rejected passwords. Empty lines and lines starting with # are skipped.
+func (p *PasswordPolicy) LoadBlocklist(r io.Reader) error {
+	if p.blocklist == nil {
+		p.blocklist = make(map[string]struct{})
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate checks a password against the rules of the policy, returning a
+// *PasswordPolicyError listing every rule it breaks
+func (p *PasswordPolicy) Validate(password string) error {
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if length > p.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleTooLong,
+			Message: fmt.Sprintf("password must be at most %d characters long", p.MaxLength),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleMissingUppercase, Message: "password must contain an uppercase letter"})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleMissingLowercase, Message: "password must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleMissingDigit, Message: "password must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleMissingSymbol, Message: "password must contain a symbol"})
+	}
+
+	if _, blocked := p.blocklist[strings.ToLower(password)]; blocked {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleTooCommon, Message: "password is too common"})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// CheckReuse rejects a password matching one of the previous password
+// hashes of a user, most recent first. Only the HistorySize most recent
+// hashes are checked.
+func (p *PasswordPolicy) CheckReuse(hasher PasswordHasher, password string, previousHashes []string) error {
+	for i, hash := range previousHashes {
+		if i >= p.HistorySize {
+			break
+		}
+		if ok, err := hasher.Verify(hash, password); err == nil && ok {
+			return &PasswordPolicyError{Violations: []PasswordViolation{{
+				Rule:    PasswordRuleReused,
+				Message: fmt.Sprintf("password must differ from your last %d passwords", p.HistorySize),
+			}}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package user
+
+import "time"
+
+// PasswordResetToken is a single-use token allowing a user to set a new
+// password without knowing the current one
+type PasswordResetToken struct {
+	ID        int64      `db:"id"`
+	UserID    int64      `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// NewPasswordResetToken creates a new password reset token for the given user
+func NewPasswordResetToken(userID int64, tokenHash string, ttl time.Duration) *PasswordResetToken {
+	now := time.Now().UTC()
+	return &PasswordResetToken{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// IsUsed reports whether the token has already been used
+func (t *PasswordResetToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *PasswordResetToken) IsExpired() bool {
+	return time.Now().UTC().After(t.ExpiresAt)
+}
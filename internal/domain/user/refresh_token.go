@@ -0,0 +1,37 @@
+package user
+
+import "time"
+
+// RefreshToken represents a long-lived token that can be exchanged for a new access token
+type RefreshToken struct {
+	ID           int64      `db:"id"`
+	UserID       int64      `db:"user_id"`
+	SessionID    *int64     `db:"session_id"`
+	TokenHash    string     `db:"token_hash"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	RevokedAt    *time.Time `db:"revoked_at"`
+	ReplacedByID *int64     `db:"replaced_by_id"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// NewRefreshToken creates a new refresh token for a session of the given user
+func NewRefreshToken(userID, sessionID int64, tokenHash string, ttl time.Duration) *RefreshToken {
+	now := time.Now().UTC()
+	return &RefreshToken{
+		UserID:    userID,
+		SessionID: &sessionID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// IsRevoked reports whether the token has been revoked
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().UTC().After(t.ExpiresAt)
+}
@@ -0,0 +1,116 @@
+package user
+
+import "fmt"
+
+// Role is a named set of permissions that can be granted to a user
+type Role string
+
+// Built-in roles
+const (
+	RoleAdmin   Role = "admin"
+	RoleSupport Role = "support"
+	RoleSelf    Role = "self"
+)
+
+// Permission is an operation that can be granted through a role
+type Permission string
+
+// Built-in permissions
+const (
+	PermissionCreateUsers  Permission = "users.create"
+	PermissionReadUsers    Permission = "users.read"
+	PermissionListUsers    Permission = "users.list"
+	PermissionUpdateUsers  Permission = "users.update"
+	PermissionSetPasswords Permission = "users.set_password"
+	PermissionDeleteUsers  Permission = "users.delete"
+	PermissionPurgeUsers   Permission = "users.purge"
+	PermissionUnlockUsers  Permission = "users.unlock"
+	PermissionInviteUsers  Permission = "users.invite"
+	// PermissionSuspendUsers allows every change of the status of users:
+	// suspending, deactivating and reactivating them
+	PermissionSuspendUsers Permission = "users.suspend"
+	PermissionManageRoles  Permission = "roles.manage"
+	// PermissionManageAPIKeys allows creating and revoking the API keys of any user
+	PermissionManageAPIKeys Permission = "api_keys.manage"
+	// PermissionManageOAuthClients allows registering the applications using
+	// the service as their OpenID Connect provider
+	PermissionManageOAuthClients Permission = "oauth_clients.manage"
+	// PermissionCreateOrganizations allows creating organizations, which
+	// their creator owns
+	PermissionCreateOrganizations Permission = "organizations.create"
+	// PermissionManageOrganizations lets API keys update and delete
+	// organizations. What their user may do is decided by their memberships.
+	PermissionManageOrganizations Permission = "organizations.manage"
+	PermissionReadGroups          Permission = "groups.read"
+	PermissionManageGroups        Permission = "groups.manage"
+)
+
+// rolePermissions maps each role to the permissions it grants. The permissions
+// of RoleSelf only apply to the user's own record. PermissionSetPasswords lets
+// a password be set without knowing the current one.
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionCreateUsers,
+		PermissionReadUsers,
+		PermissionListUsers,
+		PermissionUpdateUsers,
+		PermissionSetPasswords,
+		PermissionDeleteUsers,
+		PermissionPurgeUsers,
+		PermissionUnlockUsers,
+		PermissionInviteUsers,
+		PermissionSuspendUsers,
+		PermissionManageRoles,
+		PermissionManageAPIKeys,
+		PermissionManageOAuthClients,
+		PermissionCreateOrganizations,
+		PermissionManageOrganizations,
+		PermissionReadGroups,
+		PermissionManageGroups,
+	},
+	RoleSupport: {
+		PermissionReadUsers,
+		PermissionListUsers,
+		PermissionReadGroups,
+	},
+	RoleSelf: {
+		PermissionReadUsers,
+		PermissionUpdateUsers,
+	},
+}
+
+// ParseRole converts a role name into a Role, rejecting unknown roles
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", name)
+	}
+	return role, nil
+}
+
+// ParsePermission converts a permission name into a Permission, rejecting
+// permissions that no role grants
+func ParsePermission(name string) (Permission, error) {
+	permission := Permission(name)
+	for role := range rolePermissions {
+		if role.HasPermission(permission) {
+			return permission, nil
+		}
+	}
+	return "", fmt.Errorf("unknown permission %q", name)
+}
+
+// HasPermission reports whether the role grants the given permission
+func (r Role) HasPermission(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnRecordsOnly reports whether the role's permissions are limited to the user's own record
+func (r Role) OwnRecordsOnly() bool {
+	return r == RoleSelf
+}
@@ -0,0 +1,37 @@
+package user
+
+import "time"
+
+// Session is a login of a user on a device. It lasts as long as its refresh
+// tokens and ends when revoked, which also invalidates its access tokens.
+type Session struct {
+	ID     int64 `db:"id"`
+	UserID int64 `db:"user_id"`
+	// Device is the name given by the client when logging in
+	Device     string     `db:"device"`
+	IP         string     `db:"ip"`
+	UserAgent  string     `db:"user_agent"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastSeenAt time.Time  `db:"last_seen_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// NewSession creates a new session for the given user
+func NewSession(userID int64, device, ip, userAgent string, ttl time.Duration) *Session {
+	now := time.Now().UTC()
+	return &Session{
+		UserID:     userID,
+		Device:     device,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+}
+
+// IsActive reports whether the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().UTC().Before(s.ExpiresAt)
+}
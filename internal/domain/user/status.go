@@ -0,0 +1,53 @@
+package user
+
+import "fmt"
+
+// Status is the lifecycle state of a user account
+type Status string
+
+// Account statuses
+const (
+	// StatusPending users have not verified their email address yet
+	StatusPending Status = "pending"
+	StatusActive  Status = "active"
+	// StatusSuspended users were blocked by an admin and cannot authenticate
+	StatusSuspended Status = "suspended"
+	// StatusDeactivated users had their account closed and cannot
+	// authenticate until it is reactivated
+	StatusDeactivated Status = "deactivated"
+)
+
+// statusTransitions maps each status to the statuses a user can be moved to
+// from it. Verifying their email address moves pending users to active.
+var statusTransitions = map[Status][]Status{
+	StatusPending:     {StatusActive, StatusSuspended, StatusDeactivated},
+	StatusActive:      {StatusSuspended, StatusDeactivated},
+	StatusSuspended:   {StatusPending, StatusActive, StatusDeactivated},
+	StatusDeactivated: {StatusPending, StatusActive},
+}
+
+// ParseStatus converts a status name into a Status, rejecting unknown statuses
+func ParseStatus(name string) (Status, error) {
+	status := Status(name)
+	if _, ok := statusTransitions[status]; !ok {
+		return "", fmt.Errorf("unknown status %q", name)
+	}
+	return status, nil
+}
+
+// CanTransitionTo reports whether a user can be moved from the status to
+// another one
+func (s Status) CanTransitionTo(other Status) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == other {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAuthenticate reports whether users with the status can log in and use
+// their tokens and API keys
+func (s Status) CanAuthenticate() bool {
+	return s != StatusSuspended && s != StatusDeactivated
+}
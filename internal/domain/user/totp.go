@@ -0,0 +1,150 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters, the defaults of RFC 6238 understood by every
+// authenticator app
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// totpSecretSize is the length of secrets in bytes, as recommended by RFC 4226
+	totpSecretSize = 20
+	// totpSkew is how many time steps before and after the current one are
+	// accepted, to allow for clock drift
+	totpSkew = 1
+)
+
+// RecoveryCodeCount is the number of recovery codes issued when TOTP is enabled
+const RecoveryCodeCount = 10
+
+// totpEncoding is the base32 encoding of secrets used by authenticator apps
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// recoveryCodeEncoding writes recovery codes in lowercase letters and digits
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// IsTOTPEnabled reports whether the user has to give a TOTP code to log in
+func (u *User) IsTOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// GenerateTOTPSecret returns a new random TOTP secret
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret returns the base32 form of a secret users type into
+// their authenticator app
+func EncodeTOTPSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPURI returns the otpauth:// URI of a secret, usually shown as a QR code
+func TOTPURI(issuer, account string, secret []byte) string {
+	params := url.Values{}
+	params.Set("secret", EncodeTOTPSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	// Authenticator apps do not all decode + as a space
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// TOTPCode returns the code of a secret at the given time
+func TOTPCode(secret []byte, t time.Time) string {
+	return totpCodeAt(secret, totpStep(t))
+}
+
+// MatchTOTP checks a code against a secret, allowing for clock drift, and
+// returns the time step it belongs to. Callers must reject steps that were
+// already used, so a code cannot be replayed.
+func MatchTOTP(secret []byte, code string, t time.Time) (step int64, ok bool) {
+	if !IsTOTPCode(code) {
+		return 0, false
+	}
+
+	current := totpStep(t)
+	for s := current - totpSkew; s <= current+totpSkew; s++ {
+		if subtle.ConstantTimeCompare([]byte(totpCodeAt(secret, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode reports whether the code has the form of a TOTP code rather
+// than a recovery code
+func IsTOTPCode(code string) bool {
+	if len(code) != TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes returns RecoveryCodeCount new random recovery codes
+// of the form xxxxx-xxxxx
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode returns a recovery code as it was issued, so that
+// codes typed in uppercase or without the dash are accepted
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+// totpStep returns the RFC 6238 time step of t
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// totpCodeAt computes the RFC 4226 HOTP code of a secret for a counter
+func totpCodeAt(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000)
+}
@@ -2,49 +2,114 @@ package user
 
 import (
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // User represents a user in the system
 type User struct {
-	ID           int64     `db:"id"`
-	Username     string    `db:"username"`
-	Email        string    `db:"email"`
-	PasswordHash string    `db:"password_hash"`
-	FullName     string    `db:"full_name"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
-}
-
-// NewUser creates a new user with the given details
-func NewUser(username, email, password, fullName string) (*User, error) {
-	hashedPassword, err := HashPassword(password)
+	ID int64 `db:"id"`
+	// OrganizationID is the organization the user belongs to. Usernames are
+	// only unique within it.
+	OrganizationID    int64      `db:"organization_id"`
+	Username          string     `db:"username"`
+	Email             string     `db:"email"`
+	PasswordHash      string     `db:"password_hash"`
+	FullName          string     `db:"full_name"`
+	EmailVerifiedAt   *time.Time `db:"email_verified_at"`
+	PasswordChangedAt *time.Time `db:"password_changed_at"`
+	// TOTPSecret is the encrypted TOTP secret, set once TOTP is enrolled
+	TOTPSecret    *string    `db:"totp_secret"`
+	TOTPEnabledAt *time.Time `db:"totp_enabled_at"`
+	Status        Status     `db:"status"`
+	// StatusReason, StatusChangedAt and StatusChangedBy describe the last
+	// suspension, deactivation or reactivation
+	StatusReason    string     `db:"status_reason"`
+	StatusChangedAt *time.Time `db:"status_changed_at"`
+	StatusChangedBy *int64     `db:"status_changed_by"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+	DeletedAt       *time.Time `db:"deleted_at"`
+	Version         int64      `db:"version"`
+}
+
+// NewUser creates a new user with the given details. The password must
+// follow the policy and is hashed by the hasher.
+func NewUser(username, email, password, fullName string, policy *PasswordPolicy, hasher PasswordHasher) (*User, error) {
+	if err := policy.Validate(password); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now().UTC()
 	return &User{
-		Username:     username,
-		Email:        email,
-		PasswordHash: hashedPassword,
-		FullName:     fullName,
-		CreatedAt:    time.Now().UTC(),
-		UpdatedAt:    time.Now().UTC(),
+		Username:          username,
+		Email:             email,
+		PasswordHash:      hashedPassword,
+		FullName:          fullName,
+		PasswordChangedAt: &now,
+		Status:            StatusPending,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		Version:           1,
 	}, nil
 }
 
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+// NewExternalUser creates a new user signing in through an external identity
+// provider. They have no password until they reset it.
+func NewExternalUser(username, email, fullName string) *User {
+	now := time.Now().UTC()
+	return &User{
+		Username:  username,
+		Email:     email,
+		FullName:  fullName,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
 	}
-	return string(hashedBytes), nil
+}
+
+// SetPasswordHash replaces the password of the user with an already hashed one
+func (u *User) SetPasswordHash(hash string) {
+	now := time.Now().UTC()
+	u.PasswordHash = hash
+	u.PasswordChangedAt = &now
+}
+
+// IsDeleted reports whether the user has been soft deleted
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// IsEmailVerified reports whether the user has verified their current email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// ActiveStatus returns the status the user has when not blocked: active once
+// their email address is verified, pending until then
+func (u *User) ActiveStatus() Status {
+	if u.IsEmailVerified() {
+		return StatusActive
+	}
+	return StatusPending
+}
+
+// CanAuthenticate reports whether the status of the user lets them log in
+func (u *User) CanAuthenticate() bool {
+	return u.Status.CanAuthenticate()
+}
+
+// HasPassword reports whether the user can log in with a password
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
 }
 
 // CheckPassword checks if the provided password matches the stored hash
-func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
-	return err == nil
+func (u *User) CheckPassword(hasher PasswordHasher, password string) bool {
+	ok, err := hasher.Verify(u.PasswordHash, password)
+	return err == nil && ok
 }
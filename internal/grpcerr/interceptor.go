@@ -0,0 +1,120 @@
+// Package grpcerr translates errors returned by the services into gRPC statuses.
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain identifies this service in google.rpc.ErrorInfo details
+const errorDomain = "project_maker"
+
+// kindCodes maps each service error kind to its gRPC status code
+var kindCodes = map[service.ErrorKind]codes.Code{
+	service.KindInternal:           codes.Internal,
+	service.KindInvalidArgument:    codes.InvalidArgument,
+	service.KindNotFound:           codes.NotFound,
+	service.KindAlreadyExists:      codes.AlreadyExists,
+	service.KindPermissionDenied:   codes.PermissionDenied,
+	service.KindUnauthenticated:    codes.Unauthenticated,
+	service.KindFailedPrecondition: codes.FailedPrecondition,
+	service.KindAborted:            codes.Aborted,
+	service.KindResourceExhausted:  codes.ResourceExhausted,
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that converts the errors
+// returned by handlers into gRPC statuses
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, ToStatus(info.FullMethod, err).Err()
+		}
+		return resp, nil
+	}
+}
+
+// ToStatus converts an error into a gRPC status. Statuses are returned as is,
+// service errors get their code along with ErrorInfo, RetryInfo when clients
+// should wait before retrying and, for errors about request fields,
+// BadRequest details with one entry per violation. Anything else is logged and
+// reported as an internal error without leaking its message.
+func ToStatus(method string, err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	var serviceErr *service.Error
+	switch {
+	case errors.As(err, &serviceErr):
+		return serviceErrorStatus(serviceErr)
+	case errors.Is(err, repository.ErrNotFound):
+		return status.New(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, err.Error())
+	}
+
+	log.Printf("%s: internal error: %v", method, err)
+	return status.New(codes.Internal, "internal error")
+}
+
+// serviceErrorStatus builds the status for a service error with its details
+func serviceErrorStatus(err *service.Error) *status.Status {
+	code, ok := kindCodes[err.Kind]
+	if !ok {
+		code = codes.Internal
+	}
+
+	st := status.New(code, err.Message)
+
+	info := &errdetails.ErrorInfo{
+		Reason: err.Reason,
+		Domain: errorDomain,
+	}
+	if err.Field != "" {
+		info.Metadata = map[string]string{"field": err.Field}
+	}
+	details := []protoadapt.MessageV1{info}
+
+	if err.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(err.RetryAfter)})
+	}
+
+	switch {
+	case len(err.Violations) > 0:
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(err.Violations))
+		for i, v := range err.Violations {
+			violations[i] = &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+				Reason:      v.Reason,
+			}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	case err.Field != "":
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+				Field:       err.Field,
+				Description: err.Message,
+			}},
+		})
+	}
+
+	withDetails, detailsErr := st.WithDetails(details...)
+	if detailsErr != nil {
+		return st
+	}
+	return withDetails
+}
@@ -0,0 +1,311 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+// ErrProvider is returned when an external provider cannot be reached or
+// responds with an error or an invalid ID token
+var ErrProvider = errors.New("identity provider error")
+
+// jwksRefreshInterval is how long keys are trusted before an ID token signed
+// with an unknown key triggers a new fetch of the provider's keys
+const jwksRefreshInterval = time.Minute
+
+// ExternalProvider signs users in with an external OpenID Connect provider,
+// acting as a relying party using the authorization code flow with PKCE.
+// Its metadata is discovered on first use.
+type ExternalProvider struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	httpClient   *http.Client
+
+	mu            sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+// providerMetadata holds the parts of a discovery document the relying party uses
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewExternalProvider creates a provider named name, identified by its issuer
+// URL, with which the service is registered as a client. Clients without a
+// secret are public clients.
+func NewExternalProvider(name, issuer, clientID, clientSecret string, scopes []string, httpClient *http.Client) *ExternalProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &ExternalProvider{
+		name:         name,
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		httpClient:   httpClient,
+	}
+}
+
+// Name returns the name of the provider
+func (p *ExternalProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL returns the URL of the provider's authorization endpoint users
+// are sent to for logging in
+func (p *ExternalProvider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return withQuery(metadata.AuthorizationEndpoint, url.Values{
+		"client_id":             {p.clientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {user.CodeChallengeS256(codeVerifier)},
+		"code_challenge_method": {user.CodeChallengeMethodS256},
+	}), nil
+}
+
+// externalTokenResponse holds the parts of a token response the relying party uses
+type externalTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code at the provider and returns the
+// identity of its verified ID token, which must carry the nonce of the login
+func (p *ExternalProvider) Exchange(ctx context.Context, redirectURI, code, codeVerifier, nonce string) (*service.ExternalIdentity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		// Basic credentials are form-encoded first (RFC 6749, section 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var tokens externalTokenResponse
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: token request: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: token response: %v", ErrProvider, err)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("%w: token request failed with status %d: %s %s", ErrProvider, resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+
+	claims, err := p.verifyIDToken(ctx, metadata, tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrProvider)
+	}
+
+	return &service.ExternalIdentity{
+		Provider:          p.name,
+		Issuer:            claims.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified != nil && *claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+		Name:              claims.Name,
+	}, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience and expiry of an ID
+// token and returns its claims
+func (p *ExternalProvider) verifyIDToken(ctx context.Context, metadata *providerMetadata, idToken string) (*auth.IDTokenClaims, error) {
+	claims := &auth.IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, metadata, keyID)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ID token: %v", ErrProvider, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrProvider)
+	}
+
+	return claims, nil
+}
+
+// discover returns the metadata of the provider, fetching its discovery
+// document the first time. Failed fetches are retried on the next call.
+func (p *ExternalProvider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &providerMetadata{}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.issuer, "/")+DiscoveryPath, metadata); err != nil {
+		return nil, err
+	}
+
+	// The issuer must be the one the provider was configured with (OpenID
+	// Connect Discovery 1.0, section 4.3)
+	if metadata.Issuer != p.issuer {
+		return nil, fmt.Errorf("%w: discovery document of %q is for issuer %q", ErrProvider, p.issuer, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document of %q lacks endpoints", ErrProvider, p.issuer)
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// publicKey returns the RSA key of the provider with the key ID. The keys
+// are fetched again when the ID is unknown, as providers rotate their keys.
+func (p *ExternalProvider) publicKey(ctx context.Context, metadata *providerMetadata, keyID string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[keyID]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+
+	var set struct {
+		Keys []auth.JSONWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrProvider, jwk.KeyID, err)
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	// Providers with a single key may leave out the kid header
+	if keyID == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+	return key, nil
+}
+
+// getJSON fetches a JSON document from the provider
+func (p *ExternalProvider) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s returned status %d", ErrProvider, rawURL, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("%w: GET %s: %v", ErrProvider, rawURL, err)
+	}
+	return nil
+}
+
+// parseRSAKey converts a JSON Web Key into an RSA public key
+func parseRSAKey(jwk auth.JSONWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent too large")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
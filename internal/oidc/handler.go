@@ -0,0 +1,376 @@
+// Package oidc implements OpenID Connect over HTTP: the endpoints of the
+// built-in provider (discovery, JWKS, authorization, token and userinfo) and
+// the login of users with external providers.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+// Paths of the provider endpoints
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	JWKSPath      = "/.well-known/jwks.json"
+	AuthorizePath = "/oauth2/authorize"
+	TokenPath     = "/oauth2/token"
+	UserInfoPath  = "/oauth2/userinfo"
+)
+
+// Handler serves the OpenID Connect provider endpoints
+type Handler struct {
+	oauth    *service.OAuthService
+	tokens   *auth.TokenManager
+	sessions auth.SessionValidator
+	loginURL string
+	mux      *http.ServeMux
+}
+
+// NewHandler creates the provider endpoints. Users reaching the authorization
+// endpoint without an access token are sent to loginURL, with the URL to
+// come back to in the return_to query parameter.
+func NewHandler(oauth *service.OAuthService, tokens *auth.TokenManager, sessions auth.SessionValidator, loginURL string) *Handler {
+	h := &Handler{
+		oauth:    oauth,
+		tokens:   tokens,
+		sessions: sessions,
+		loginURL: loginURL,
+		mux:      http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET "+DiscoveryPath, h.discovery)
+	h.mux.HandleFunc("GET "+JWKSPath, h.jwks)
+	h.mux.HandleFunc("GET "+AuthorizePath, h.authorize)
+	h.mux.HandleFunc("POST "+AuthorizePath, h.authorize)
+	h.mux.HandleFunc("POST "+TokenPath, h.token)
+	h.mux.HandleFunc("GET "+UserInfoPath, h.userInfo)
+	h.mux.HandleFunc("POST "+UserInfoPath, h.userInfo)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// discoveryDocument is the provider metadata of OpenID Connect Discovery 1.0
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// discovery serves the provider metadata
+func (h *Handler) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := strings.TrimSuffix(h.oauth.Issuer(), "/")
+	writeJSON(w, http.StatusOK, discoveryDocument{
+		Issuer:                            h.oauth.Issuer(),
+		AuthorizationEndpoint:             issuer + AuthorizePath,
+		TokenEndpoint:                     issuer + TokenPath,
+		UserInfoEndpoint:                  issuer + UserInfoPath,
+		JWKSURI:                           issuer + JWKSPath,
+		ScopesSupported:                   h.oauth.SupportedScopes(),
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{h.oauth.SigningKey().JWK().Algorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{user.CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "name", "email", "email_verified"},
+	})
+}
+
+// jwks serves the public key verifying ID tokens
+func (h *Handler) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]auth.JSONWebKey{
+		"keys": {h.oauth.SigningKey().JWK()},
+	})
+}
+
+// authorize handles authorization requests of logged in users, sent with
+// their access token in the Authorization header. GET requests are
+// redirected to the client, while POST requests, made by login pages on
+// behalf of the user, get the URL to redirect to as JSON.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	req := service.AuthorizationRequest{
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		ResponseType:        r.Form.Get("response_type"),
+		Scope:               r.Form.Get("scope"),
+		Nonce:               r.Form.Get("nonce"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+	}
+	state := r.Form.Get("state")
+	ctx := clientContext(r)
+
+	// Errors cannot be sent to a redirect URI that is not registered
+	client, err := h.oauth.ValidateRedirect(ctx, req.ClientID, req.RedirectURI)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	params := url.Values{}
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	principal, err := h.authenticate(ctx, r)
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken) && r.Method == http.MethodGet && h.loginURL != "":
+		http.Redirect(w, r, h.loginRedirect(r), http.StatusFound)
+		return
+	case errors.Is(err, auth.ErrInvalidToken):
+		params.Set("error", "login_required")
+	case err != nil:
+		log.Printf("%s: failed to authenticate access token: %v", AuthorizePath, err)
+		params.Set("error", "server_error")
+	default:
+		code, err := h.oauth.Authorize(auth.NewContext(ctx, principal), client, req)
+		if err != nil {
+			errorCode, description := oauthError(err)
+			params.Set("error", errorCode)
+			params.Set("error_description", description)
+		} else {
+			params.Set("code", code)
+		}
+	}
+
+	redirectTo := withQuery(req.RedirectURI, params)
+	if r.Method == http.MethodPost {
+		writeJSON(w, http.StatusOK, map[string]string{"redirect_to": redirectTo})
+		return
+	}
+	http.Redirect(w, r, redirectTo, http.StatusFound)
+}
+
+// tokenResponse is the successful response of the token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope"`
+}
+
+// token exchanges authorization codes for tokens. Clients authenticate with
+// HTTP Basic authentication, their credentials in the form, or only their
+// client_id for public clients.
+func (h *Handler) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if grantType := r.PostForm.Get("grant_type"); grantType != "authorization_code" {
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type", "only the authorization_code grant type is supported")
+		return
+	}
+
+	req := service.TokenRequest{
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+	}
+	if id, secret, ok := r.BasicAuth(); ok {
+		// Basic credentials are form-encoded first (RFC 6749, section 2.3.1)
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	tokens, err := h.oauth.ExchangeCode(clientContext(r), req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(tokens.ExpiresIn / time.Second),
+		IDToken:     tokens.IDToken,
+		Scope:       tokens.Scope,
+	})
+}
+
+// userInfoResponse holds the standard claims about a user, those of scopes
+// not granted to the client left out
+type userInfoResponse struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// userInfo returns the claims about the user of a bearer access token issued
+// to a client, limited to the scopes granted to the client
+func (h *Handler) userInfo(w http.ResponseWriter, r *http.Request) {
+	ctx := clientContext(r)
+
+	principal, claims, err := h.authenticateClient(ctx, r)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidToken) {
+			log.Printf("%s: failed to authenticate access token: %v", UserInfoPath, err)
+			writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, "invalid_token", "invalid or expired access token")
+		return
+	}
+
+	u, err := h.oauth.UserInfo(auth.NewContext(ctx, principal))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	resp := userInfoResponse{Subject: strconv.FormatInt(u.ID, 10)}
+	if claims.HasScope(user.ScopeProfile) {
+		resp.PreferredUsername = u.Username
+		resp.Name = u.FullName
+	}
+	if claims.HasScope(user.ScopeEmail) {
+		verified := u.EmailVerifiedAt != nil
+		resp.Email = u.Email
+		resp.EmailVerified = &verified
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// authenticate returns the principal of the bearer access token of the
+// request, or auth.ErrInvalidToken if it has none or it is not valid
+func (h *Handler) authenticate(ctx context.Context, r *http.Request) (*auth.Principal, error) {
+	token, ok := auth.BearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return auth.AuthenticateAccessToken(ctx, h.tokens, h.sessions, token)
+}
+
+// authenticateClient returns the principal and claims of the bearer access
+// token a client sent with the request, or auth.ErrInvalidToken if it has none
+// or it is not valid. Access tokens of users are not accepted.
+func (h *Handler) authenticateClient(ctx context.Context, r *http.Request) (*auth.Principal, *auth.Claims, error) {
+	token, ok := auth.BearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return nil, nil, auth.ErrInvalidToken
+	}
+	return auth.AuthenticateClientAccessToken(ctx, h.tokens, h.sessions, token)
+}
+
+// loginRedirect returns the URL of the login page, which sends the user back
+// to the authorization request once logged in
+func (h *Handler) loginRedirect(r *http.Request) string {
+	return withQuery(h.loginURL, url.Values{"return_to": {r.URL.RequestURI()}})
+}
+
+// writeServiceError writes an error returned by the OAuth service
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error) {
+	errorCode, description := oauthError(err)
+	switch errorCode {
+	case "server_error":
+		log.Printf("oidc: internal error: %v", err)
+		writeError(w, http.StatusInternalServerError, errorCode, description)
+	case "invalid_client":
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		writeError(w, http.StatusUnauthorized, errorCode, description)
+	default:
+		writeError(w, http.StatusBadRequest, errorCode, description)
+	}
+}
+
+// oauthError returns the OAuth error code and description of an error
+func oauthError(err error) (string, string) {
+	var serviceErr *service.Error
+	if !errors.As(err, &serviceErr) {
+		return "server_error", "internal error"
+	}
+
+	switch serviceErr.Kind {
+	case service.KindUnauthenticated:
+		if serviceErr.Reason == service.ErrOAuthInvalidClient.Reason {
+			return "invalid_client", serviceErr.Message
+		}
+		return "login_required", serviceErr.Message
+	case service.KindPermissionDenied:
+		return "access_denied", serviceErr.Message
+	case service.KindInvalidArgument:
+		return strings.ToLower(serviceErr.Reason), serviceErr.Message
+	}
+	return "server_error", "internal error"
+}
+
+// clientContext returns the context of a request carrying its client
+func clientContext(r *http.Request) context.Context {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return auth.NewClientContext(r.Context(), &auth.Client{IP: ip, UserAgent: r.UserAgent()})
+}
+
+// withQuery adds query parameters to a URL, keeping those it already has
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("oidc: failed to write response: %v", err)
+	}
+}
+
+// writeError writes an OAuth error response (RFC 6749, section 5.2)
+func writeError(w http.ResponseWriter, code int, errorCode, description string) {
+	writeJSON(w, code, map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,253 @@
+package oidc
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+// Paths of the social login endpoints, under the name of the provider
+const (
+	SocialLoginPath    = "/auth/{provider}/login"
+	SocialCallbackPath = "/auth/{provider}/callback"
+)
+
+// loginStateCookie binds a login at a provider to the browser that started
+// it, so that a callback URL cannot log someone else in
+const loginStateCookie = "pm_login_state"
+
+// maxDeviceLength is the longest device name a session can record
+const maxDeviceLength = 100
+
+// SocialLoginHandler signs users in with external OpenID Connect providers
+type SocialLoginHandler struct {
+	identities *service.IdentityService
+	providers  map[string]*ExternalProvider
+	baseURL    string
+	successURL string
+	mux        *http.ServeMux
+}
+
+// NewSocialLoginHandler creates the social login endpoints of the providers.
+// Their callback URLs, to register with each provider, are
+// <baseURL>/auth/<name>/callback. Users who signed in are redirected to
+// successURL with their tokens in the fragment, or get them as JSON when it
+// is empty.
+func NewSocialLoginHandler(identities *service.IdentityService, providers []*ExternalProvider, baseURL, successURL string) *SocialLoginHandler {
+	h := &SocialLoginHandler{
+		identities: identities,
+		providers:  make(map[string]*ExternalProvider, len(providers)),
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		successURL: successURL,
+		mux:        http.NewServeMux(),
+	}
+	for _, provider := range providers {
+		h.providers[provider.Name()] = provider
+	}
+
+	h.mux.HandleFunc("GET "+SocialLoginPath, h.login)
+	h.mux.HandleFunc("GET "+SocialCallbackPath, h.callback)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *SocialLoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// login sends the user to the provider to log in. The optional device query
+// parameter names the session started once they are back.
+func (h *SocialLoginHandler) login(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown_provider", "unknown identity provider")
+		return
+	}
+
+	device := r.URL.Query().Get("device")
+	if len(device) > maxDeviceLength {
+		writeError(w, http.StatusBadRequest, "invalid_request", "device must be at most 100 characters")
+		return
+	}
+
+	ctx := clientContext(r)
+	loginState, state, err := h.identities.BeginLogin(ctx, provider.Name(), device)
+	if err != nil {
+		log.Printf("%s: failed to start login: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, h.callbackURL(provider), state, loginState.Nonce, loginState.CodeVerifier)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   int(h.identities.StateTTL() / time.Second),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.baseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback completes a login when the provider redirects back with a code
+func (h *SocialLoginHandler) callback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown_provider", "unknown identity provider")
+		return
+	}
+
+	// The state is single use whatever the outcome
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Path: "/auth/", MaxAge: -1})
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	cookie, err := r.Cookie(loginStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		h.fail(w, r, service.ErrInvalidLoginState)
+		return
+	}
+
+	ctx := clientContext(r)
+	loginState, err := h.identities.CompleteLogin(ctx, provider.Name(), state)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	// The user declined or the provider failed
+	if errorCode := query.Get("error"); errorCode != "" {
+		h.deliver(w, r, http.StatusUnauthorized, &loginResult{
+			Error:            errorCode,
+			ErrorDescription: query.Get("error_description"),
+		})
+		return
+	}
+
+	identity, err := provider.Exchange(ctx, h.callbackURL(provider), query.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	pair, err := h.identities.SignIn(ctx, identity, loginState.Device)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	if pair.MFAToken != "" {
+		h.deliver(w, r, http.StatusOK, &loginResult{MFARequired: true, MFAToken: pair.MFAToken})
+		return
+	}
+	h.deliver(w, r, http.StatusOK, &loginResult{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(pair.ExpiresIn / time.Second),
+	})
+}
+
+// callbackURL returns the URL the provider redirects back to
+func (h *SocialLoginHandler) callbackURL(provider *ExternalProvider) string {
+	return h.baseURL + strings.Replace(SocialCallbackPath, "{provider}", url.PathEscape(provider.Name()), 1)
+}
+
+// fail reports an error of a login
+func (h *SocialLoginHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	result := &loginResult{Error: "server_error", ErrorDescription: "internal error"}
+
+	var serviceErr *service.Error
+	switch {
+	case errors.As(err, &serviceErr):
+		code = socialErrorStatus(serviceErr.Kind)
+		result.Error = strings.ToLower(serviceErr.Reason)
+		result.ErrorDescription = serviceErr.Message
+	case errors.Is(err, ErrProvider):
+		log.Printf("%s: %v", r.URL.Path, err)
+		code = http.StatusBadGateway
+		result.Error = "provider_error"
+		result.ErrorDescription = "the identity provider could not complete the login"
+	default:
+		log.Printf("%s: internal error: %v", r.URL.Path, err)
+	}
+
+	h.deliver(w, r, code, result)
+}
+
+// loginResult is the outcome of a login, either tokens or an error
+type loginResult struct {
+	AccessToken      string `json:"access_token,omitempty"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	TokenType        string `json:"token_type,omitempty"`
+	ExpiresIn        int64  `json:"expires_in,omitempty"`
+	MFARequired      bool   `json:"mfa_required,omitempty"`
+	MFAToken         string `json:"mfa_token,omitempty"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// values returns the set fields of the result as URL parameters
+func (res *loginResult) values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("access_token", res.AccessToken)
+	set("refresh_token", res.RefreshToken)
+	set("token_type", res.TokenType)
+	if res.ExpiresIn > 0 {
+		values.Set("expires_in", strconv.FormatInt(res.ExpiresIn, 10))
+	}
+	if res.MFARequired {
+		values.Set("mfa_required", "true")
+	}
+	set("mfa_token", res.MFAToken)
+	set("error", res.Error)
+	set("error_description", res.ErrorDescription)
+	return values
+}
+
+// deliver sends the result of a login to the success URL in its fragment,
+// which is never sent to servers, or as JSON when there is none
+func (h *SocialLoginHandler) deliver(w http.ResponseWriter, r *http.Request, code int, result *loginResult) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if h.successURL != "" {
+		http.Redirect(w, r, h.successURL+"#"+result.values().Encode(), http.StatusFound)
+		return
+	}
+	writeJSON(w, code, result)
+}
+
+// socialErrorStatus returns the HTTP status of a service error kind
+func socialErrorStatus(kind service.ErrorKind) int {
+	switch kind {
+	case service.KindInvalidArgument:
+		return http.StatusBadRequest
+	case service.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case service.KindPermissionDenied:
+		return http.StatusForbidden
+	case service.KindAlreadyExists, service.KindFailedPrecondition, service.KindAborted:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// APIKeyRepository defines the interface for API key persistence operations
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *user.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*user.APIKey, error)
+	ListByUser(ctx context.Context, userID int64) ([]*user.APIKey, error)
+	Touch(ctx context.Context, id int64, usedAt time.Time) error
+	Revoke(ctx context.Context, id, userID int64) error
+}
+
+// PostgresAPIKeyRepository is a PostgreSQL implementation of APIKeyRepository
+type PostgresAPIKeyRepository struct {
+	db DBTX
+}
+
+// NewPostgresAPIKeyRepository creates a new PostgreSQL API key repository
+func NewPostgresAPIKeyRepository(db *sqlx.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+// apiKeyRow is an API key as stored, with its scopes in a text array
+type apiKeyRow struct {
+	user.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+// toAPIKey converts a stored row into an API key
+func (r *apiKeyRow) toAPIKey() *user.APIKey {
+	key := r.APIKey
+	key.Scopes = make([]user.Permission, len(r.Scopes))
+	for i, scope := range r.Scopes {
+		key.Scopes[i] = user.Permission(scope)
+	}
+	return &key
+}
+
+// Create inserts a new API key into the database
+func (r *PostgresAPIKeyRepository) Create(ctx context.Context, key *user.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		pq.Array(scopes),
+		key.ExpiresAt,
+		key.CreatedBy,
+		key.CreatedAt,
+	)
+
+	return row.Scan(&key.ID)
+}
+
+// GetByHash retrieves an API key by the hash of its value
+func (r *PostgresAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*user.APIKey, error) {
+	row := &apiKeyRow{}
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_by, created_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	err := r.db.GetContext(ctx, row, query, keyHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return row.toAPIKey(), nil
+}
+
+// ListByUser returns the API keys of a user that were not revoked, newest first
+func (r *PostgresAPIKeyRepository) ListByUser(ctx context.Context, userID int64) ([]*user.APIKey, error) {
+	var rows []*apiKeyRow
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_by, created_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC
+	`
+
+	if err := r.db.SelectContext(ctx, &rows, query, userID); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*user.APIKey, len(rows))
+	for i, row := range rows {
+		keys[i] = row.toAPIKey()
+	}
+
+	return keys, nil
+}
+
+// Touch records that an API key was used
+func (r *PostgresAPIKeyRepository) Touch(ctx context.Context, id int64, usedAt time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, usedAt, id)
+	return err
+}
+
+// Revoke revokes an active API key of a user
+func (r *PostgresAPIKeyRepository) Revoke(ctx context.Context, id, userID int64) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
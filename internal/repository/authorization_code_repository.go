@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// AuthorizationCodeRepository defines the interface for OAuth authorization code persistence operations
+type AuthorizationCodeRepository interface {
+	Create(ctx context.Context, code *user.AuthorizationCode) error
+	GetByHash(ctx context.Context, codeHash string) (*user.AuthorizationCode, error)
+	MarkUsed(ctx context.Context, id int64) error
+}
+
+// PostgresAuthorizationCodeRepository is a PostgreSQL implementation of AuthorizationCodeRepository
+type PostgresAuthorizationCodeRepository struct {
+	db DBTX
+}
+
+// NewPostgresAuthorizationCodeRepository creates a new PostgreSQL authorization code repository
+func NewPostgresAuthorizationCodeRepository(db *sqlx.DB) *PostgresAuthorizationCodeRepository {
+	return &PostgresAuthorizationCodeRepository{db: db}
+}
+
+// Create inserts a new authorization code into the database
+func (r *PostgresAuthorizationCodeRepository) Create(ctx context.Context, code *user.AuthorizationCode) error {
+	query := `
+		INSERT INTO oauth_authorization_codes (
+			code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scope,
+		code.Nonce,
+		code.CodeChallenge,
+		code.AuthTime,
+		code.ExpiresAt,
+		code.CreatedAt,
+	)
+
+	return row.Scan(&code.ID)
+}
+
+// GetByHash retrieves an authorization code by the hash of its value
+func (r *PostgresAuthorizationCodeRepository) GetByHash(ctx context.Context, codeHash string) (*user.AuthorizationCode, error) {
+	code := &user.AuthorizationCode{}
+	query := `
+		SELECT id, code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at, used_at, created_at
+		FROM oauth_authorization_codes
+		WHERE code_hash = $1
+	`
+
+	err := r.db.GetContext(ctx, code, query, codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// MarkUsed marks an unused authorization code as exchanged
+func (r *PostgresAuthorizationCodeRepository) MarkUsed(ctx context.Context, id int64) error {
+	query := `
+		UPDATE oauth_authorization_codes
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
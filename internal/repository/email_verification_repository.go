@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// EmailVerificationRepository defines the interface for email verification token persistence operations
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, token *user.EmailVerificationToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*user.EmailVerificationToken, error)
+	MarkUsed(ctx context.Context, id int64) error
+	InvalidateAllForUser(ctx context.Context, userID int64) error
+}
+
+// PostgresEmailVerificationRepository is a PostgreSQL implementation of EmailVerificationRepository
+type PostgresEmailVerificationRepository struct {
+	db DBTX
+}
+
+// NewPostgresEmailVerificationRepository creates a new PostgreSQL email verification token repository
+func NewPostgresEmailVerificationRepository(db *sqlx.DB) *PostgresEmailVerificationRepository {
+	return &PostgresEmailVerificationRepository{db: db}
+}
+
+// Create inserts a new email verification token into the database
+func (r *PostgresEmailVerificationRepository) Create(ctx context.Context, token *user.EmailVerificationToken) error {
+	query := `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Email,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	return row.Scan(&token.ID)
+}
+
+// GetByHash retrieves an email verification token by the hash of its value
+func (r *PostgresEmailVerificationRepository) GetByHash(ctx context.Context, tokenHash string) (*user.EmailVerificationToken, error) {
+	token := &user.EmailVerificationToken{}
+	query := `
+		SELECT id, user_id, email, token_hash, expires_at, used_at, created_at
+		FROM email_verification_tokens
+		WHERE token_hash = $1
+	`
+
+	err := r.db.GetContext(ctx, token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed marks an email verification token as used. It returns ErrNotFound
+// if the token does not exist or was already used.
+func (r *PostgresEmailVerificationRepository) MarkUsed(ctx context.Context, id int64) error {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// InvalidateAllForUser marks every unused email verification token of a user as used
+func (r *PostgresEmailVerificationRepository) InvalidateAllForUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = $1
+		WHERE user_id = $2 AND used_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID)
+	return err
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// ExternalLoginStateRepository defines the interface for persisting logins in progress at external providers
+type ExternalLoginStateRepository interface {
+	Create(ctx context.Context, state *user.ExternalLoginState) error
+	Consume(ctx context.Context, stateHash string) (*user.ExternalLoginState, error)
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// PostgresExternalLoginStateRepository is a PostgreSQL implementation of ExternalLoginStateRepository
+type PostgresExternalLoginStateRepository struct {
+	db DBTX
+}
+
+// NewPostgresExternalLoginStateRepository creates a new PostgreSQL external login state repository
+func NewPostgresExternalLoginStateRepository(db *sqlx.DB) *PostgresExternalLoginStateRepository {
+	return &PostgresExternalLoginStateRepository{db: db}
+}
+
+// Create inserts a new login state into the database
+func (r *PostgresExternalLoginStateRepository) Create(ctx context.Context, state *user.ExternalLoginState) error {
+	query := `
+		INSERT INTO external_login_states (state_hash, provider, nonce, code_verifier, device, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		state.StateHash,
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		state.Device,
+		state.ExpiresAt,
+		state.CreatedAt,
+	)
+
+	return row.Scan(&state.ID)
+}
+
+// Consume deletes the login state with the hash and returns it, so that each
+// state is only used once
+func (r *PostgresExternalLoginStateRepository) Consume(ctx context.Context, stateHash string) (*user.ExternalLoginState, error) {
+	state := &user.ExternalLoginState{}
+	query := `
+		DELETE FROM external_login_states
+		WHERE state_hash = $1
+		RETURNING id, state_hash, provider, nonce, code_verifier, device, expires_at, created_at
+	`
+
+	err := r.db.GetContext(ctx, state, query, stateHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// DeleteExpired removes the states of logins abandoned before completing
+func (r *PostgresExternalLoginStateRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	query := `
+		DELETE FROM external_login_states
+		WHERE expires_at < $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, before)
+	return err
+}
@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// ErrDuplicateGroupName is returned when a group name is already used in the organization
+var ErrDuplicateGroupName = errors.New("group name already exists")
+
+// GroupRepository defines the interface for group persistence operations. Like
+// UserRepository, every method only sees the groups of the organization ctx
+// is scoped to, if any, and unscoped calls create groups in the default
+// organization.
+type GroupRepository interface {
+	Create(ctx context.Context, group *user.Group) error
+	GetByID(ctx context.Context, id int64) (*user.Group, error)
+	List(ctx context.Context) ([]*user.Group, error)
+	Update(ctx context.Context, group *user.Group) error
+	Delete(ctx context.Context, id int64) error
+	AddUser(ctx context.Context, groupID, userID int64, addedBy *int64) error
+	RemoveUser(ctx context.Context, groupID, userID int64) error
+	AddSubgroup(ctx context.Context, parentID, childID int64, addedBy *int64) error
+	RemoveSubgroup(ctx context.Context, parentID, childID int64) error
+	ListUsers(ctx context.Context, groupID int64) ([]*user.User, error)
+	ListSubgroups(ctx context.Context, groupID int64) ([]*user.Group, error)
+	ListByUser(ctx context.Context, userID int64) ([]*user.Group, error)
+	ListEffectiveByUser(ctx context.Context, userID int64) ([]*user.Group, error)
+	Contains(ctx context.Context, groupID, otherID int64) (bool, error)
+	LockNesting(ctx context.Context, organizationID int64) error
+}
+
+// PostgresGroupRepository is a PostgreSQL implementation of GroupRepository
+type PostgresGroupRepository struct {
+	db DBTX
+}
+
+// NewPostgresGroupRepository creates a new PostgreSQL group repository
+func NewPostgresGroupRepository(db *sqlx.DB) *PostgresGroupRepository {
+	return &PostgresGroupRepository{db: db}
+}
+
+// translateGroupWriteError converts unique violations on the group name into
+// ErrDuplicateGroupName
+func translateGroupWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicateGroupName
+	}
+	return err
+}
+
+// Create inserts a new group into the database
+func (r *PostgresGroupRepository) Create(ctx context.Context, group *user.Group) error {
+	query := `
+		INSERT INTO groups (organization_id, name, description, created_at, updated_at)
+		VALUES (COALESCE(NULLIF($1::BIGINT, 0), ` + defaultOrganization + `), $2, $3, $4, $5)
+		RETURNING id, organization_id
+	`
+
+	row := r.db.QueryRowContext(ctx, query, tenantID(ctx), group.Name, group.Description, group.CreatedAt, group.UpdatedAt)
+	return translateGroupWriteError(row.Scan(&group.ID, &group.OrganizationID))
+}
+
+// GetByID retrieves a group by ID
+func (r *PostgresGroupRepository) GetByID(ctx context.Context, id int64) (*user.Group, error) {
+	group := &user.Group{}
+	query := `
+		SELECT id, organization_id, name, description, created_at, updated_at
+		FROM groups
+		WHERE id = $1 AND ($2::BIGINT = 0 OR organization_id = $2)
+	`
+
+	err := r.db.GetContext(ctx, group, query, id, tenantID(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// List returns every group, sorted by name
+func (r *PostgresGroupRepository) List(ctx context.Context) ([]*user.Group, error) {
+	groups := []*user.Group{}
+	query := `
+		SELECT id, organization_id, name, description, created_at, updated_at
+		FROM groups
+		WHERE ($1::BIGINT = 0 OR organization_id = $1)
+		ORDER BY name, id
+	`
+
+	if err := r.db.SelectContext(ctx, &groups, query, tenantID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// Update updates the name and description of an existing group
+func (r *PostgresGroupRepository) Update(ctx context.Context, group *user.Group) error {
+	updatedAt := time.Now().UTC()
+
+	query := `
+		UPDATE groups
+		SET name = $1, description = $2, updated_at = $3
+		WHERE id = $4 AND ($5::BIGINT = 0 OR organization_id = $5)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, group.Name, group.Description, updatedAt, group.ID, tenantID(ctx))
+	if err != nil {
+		return translateGroupWriteError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	group.UpdatedAt = updatedAt
+	return nil
+}
+
+// Delete removes a group along with its memberships, in it and in other groups
+func (r *PostgresGroupRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM groups WHERE id = $1 AND ($2::BIGINT = 0 OR organization_id = $2)`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddUser adds a user to a group. Adding a user already in the group is a no-op.
+func (r *PostgresGroupRepository) AddUser(ctx context.Context, groupID, userID int64, addedBy *int64) error {
+	query := `
+		INSERT INTO group_user_members (group_id, user_id, added_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (group_id, user_id) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, groupID, userID, addedBy, time.Now().UTC())
+	return err
+}
+
+// RemoveUser removes a user from a group. It returns ErrNotFound if the user
+// is not directly in the group.
+func (r *PostgresGroupRepository) RemoveUser(ctx context.Context, groupID, userID int64) error {
+	query := `DELETE FROM group_user_members WHERE group_id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddSubgroup nests a group in another. Nesting a group already in the parent
+// is a no-op. Callers must make sure it does not create a cycle.
+func (r *PostgresGroupRepository) AddSubgroup(ctx context.Context, parentID, childID int64, addedBy *int64) error {
+	query := `
+		INSERT INTO group_subgroups (parent_id, child_id, added_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (parent_id, child_id) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, parentID, childID, addedBy, time.Now().UTC())
+	return err
+}
+
+// RemoveSubgroup removes a group nested in another. It returns ErrNotFound if
+// the group is not directly nested in the parent.
+func (r *PostgresGroupRepository) RemoveSubgroup(ctx context.Context, parentID, childID int64) error {
+	query := `DELETE FROM group_subgroups WHERE parent_id = $1 AND child_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, parentID, childID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListUsers returns the users directly in a group, sorted by username.
+// Deleted users are left out.
+func (r *PostgresGroupRepository) ListUsers(ctx context.Context, groupID int64) ([]*user.User, error) {
+	users := []*user.User{}
+	query := `
+		SELECT u.id, u.organization_id, u.username, u.email, u.password_hash, u.full_name, u.email_verified_at, u.password_changed_at, u.totp_secret, u.totp_enabled_at, u.status, u.status_reason, u.status_changed_at, u.status_changed_by, u.created_at, u.updated_at, u.deleted_at, u.version
+		FROM users u
+		JOIN group_user_members m ON m.user_id = u.id
+		WHERE m.group_id = $1 AND u.deleted_at IS NULL AND ($2::BIGINT = 0 OR u.organization_id = $2)
+		ORDER BY u.username, u.id
+	`
+
+	if err := r.db.SelectContext(ctx, &users, query, groupID, tenantID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// ListSubgroups returns the groups directly nested in a group, sorted by name
+func (r *PostgresGroupRepository) ListSubgroups(ctx context.Context, groupID int64) ([]*user.Group, error) {
+	groups := []*user.Group{}
+	query := `
+		SELECT g.id, g.organization_id, g.name, g.description, g.created_at, g.updated_at
+		FROM groups g
+		JOIN group_subgroups s ON s.child_id = g.id
+		WHERE s.parent_id = $1 AND ($2::BIGINT = 0 OR g.organization_id = $2)
+		ORDER BY g.name, g.id
+	`
+
+	if err := r.db.SelectContext(ctx, &groups, query, groupID, tenantID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// ListByUser returns the groups a user was directly added to, sorted by name
+func (r *PostgresGroupRepository) ListByUser(ctx context.Context, userID int64) ([]*user.Group, error) {
+	groups := []*user.Group{}
+	query := `
+		SELECT g.id, g.organization_id, g.name, g.description, g.created_at, g.updated_at
+		FROM groups g
+		JOIN group_user_members m ON m.group_id = g.id
+		WHERE m.user_id = $1 AND ($2::BIGINT = 0 OR g.organization_id = $2)
+		ORDER BY g.name, g.id
+	`
+
+	if err := r.db.SelectContext(ctx, &groups, query, userID, tenantID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// ListEffectiveByUser returns the groups a user is a member of, directly or
+// through the groups nested in them at any depth, sorted by name
+func (r *PostgresGroupRepository) ListEffectiveByUser(ctx context.Context, userID int64) ([]*user.Group, error) {
+	groups := []*user.Group{}
+
+	// Walks up from the groups of the user to the groups containing them. UNION
+	// drops the groups already reached, so the walk ends even on a cycle.
+	query := `
+		WITH RECURSIVE effective (id) AS (
+			SELECT group_id FROM group_user_members WHERE user_id = $1
+			UNION
+			SELECT s.parent_id
+			FROM group_subgroups s
+			JOIN effective e ON s.child_id = e.id
+		)
+		SELECT g.id, g.organization_id, g.name, g.description, g.created_at, g.updated_at
+		FROM groups g
+		JOIN effective e ON e.id = g.id
+		WHERE ($2::BIGINT = 0 OR g.organization_id = $2)
+		ORDER BY g.name, g.id
+	`
+
+	if err := r.db.SelectContext(ctx, &groups, query, userID, tenantID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// Contains reports whether otherID is groupID itself or a group nested in it
+// at any depth
+func (r *PostgresGroupRepository) Contains(ctx context.Context, groupID, otherID int64) (bool, error) {
+	query := `
+		WITH RECURSIVE nested (id) AS (
+			SELECT $1::INTEGER
+			UNION
+			SELECT s.child_id
+			FROM group_subgroups s
+			JOIN nested n ON s.parent_id = n.id
+		)
+		SELECT EXISTS (SELECT 1 FROM nested WHERE id = $2)
+	`
+
+	var contains bool
+	if err := r.db.GetContext(ctx, &contains, query, groupID, otherID); err != nil {
+		return false, err
+	}
+
+	return contains, nil
+}
+
+// LockNesting serializes changes to the nesting of the groups of an
+// organization by taking a transaction-scoped advisory lock. It must be called
+// inside a unit of work, before checking for cycles.
+func (r *PostgresGroupRepository) LockNesting(ctx context.Context, organizationID int64) error {
+	key := fmt.Sprintf("group_nesting:%d", organizationID)
+
+	_, err := r.db.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key)
+	return err
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// InvitationRepository defines the interface for invitation persistence
+// operations. Except for GetByHash, whose token is enough to find an
+// invitation, methods only see the invitations of the organization ctx is
+// scoped to, if any.
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *user.Invitation) error
+	GetByID(ctx context.Context, id int64) (*user.Invitation, error)
+	GetByHash(ctx context.Context, tokenHash string) (*user.Invitation, error)
+	List(ctx context.Context, pendingOnly bool) ([]*user.Invitation, error)
+	Revoke(ctx context.Context, id int64) error
+	RevokeOthers(ctx context.Context, invitation *user.Invitation) error
+	MarkAccepted(ctx context.Context, id int64) error
+	SetAcceptedUser(ctx context.Context, id, userID int64) error
+}
+
+// PostgresInvitationRepository is a PostgreSQL implementation of InvitationRepository
+type PostgresInvitationRepository struct {
+	db DBTX
+}
+
+// NewPostgresInvitationRepository creates a new PostgreSQL invitation repository
+func NewPostgresInvitationRepository(db *sqlx.DB) *PostgresInvitationRepository {
+	return &PostgresInvitationRepository{db: db}
+}
+
+// invitationRow is an invitation as stored, with its roles in a text array
+type invitationRow struct {
+	user.Invitation
+	Roles pq.StringArray `db:"roles"`
+}
+
+// toInvitation converts a stored row into an invitation
+func (r *invitationRow) toInvitation() *user.Invitation {
+	invitation := r.Invitation
+	invitation.Roles = make([]user.Role, len(r.Roles))
+	for i, role := range r.Roles {
+		invitation.Roles[i] = user.Role(role)
+	}
+	return &invitation
+}
+
+// Create inserts a new invitation into the database. Invitations without an
+// organization are created in the default organization.
+func (r *PostgresInvitationRepository) Create(ctx context.Context, invitation *user.Invitation) error {
+	query := `
+		INSERT INTO invitations (organization_id, email, roles, token_hash, invited_by, expires_at, created_at)
+		VALUES (COALESCE(NULLIF($1::BIGINT, 0), ` + defaultOrganization + `), $2, $3, $4, $5, $6, $7)
+		RETURNING id, organization_id
+	`
+
+	roles := make([]string, len(invitation.Roles))
+	for i, role := range invitation.Roles {
+		roles[i] = string(role)
+	}
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		invitation.OrganizationID,
+		invitation.Email,
+		pq.Array(roles),
+		invitation.TokenHash,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+		invitation.CreatedAt,
+	)
+
+	return row.Scan(&invitation.ID, &invitation.OrganizationID)
+}
+
+// GetByID retrieves an invitation by ID
+func (r *PostgresInvitationRepository) GetByID(ctx context.Context, id int64) (*user.Invitation, error) {
+	row := &invitationRow{}
+	query := `
+		SELECT id, organization_id, email, roles, token_hash, invited_by, expires_at, accepted_at, accepted_user_id, revoked_at, created_at
+		FROM invitations
+		WHERE id = $1 AND ($2::BIGINT = 0 OR organization_id = $2)
+	`
+
+	err := r.db.GetContext(ctx, row, query, id, tenantID(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return row.toInvitation(), nil
+}
+
+// GetByHash retrieves an invitation by the hash of its token, whatever the
+// organization ctx is scoped to
+func (r *PostgresInvitationRepository) GetByHash(ctx context.Context, tokenHash string) (*user.Invitation, error) {
+	row := &invitationRow{}
+	query := `
+		SELECT id, organization_id, email, roles, token_hash, invited_by, expires_at, accepted_at, accepted_user_id, revoked_at, created_at
+		FROM invitations
+		WHERE token_hash = $1
+	`
+
+	err := r.db.GetContext(ctx, row, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return row.toInvitation(), nil
+}
+
+// List returns the invitations, newest first. With pendingOnly, accepted,
+// revoked and expired invitations are left out.
+func (r *PostgresInvitationRepository) List(ctx context.Context, pendingOnly bool) ([]*user.Invitation, error) {
+	var rows []*invitationRow
+	query := `
+		SELECT id, organization_id, email, roles, token_hash, invited_by, expires_at, accepted_at, accepted_user_id, revoked_at, created_at
+		FROM invitations
+		WHERE ($1::BIGINT = 0 OR organization_id = $1)
+			AND (NOT $2 OR (accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $3))
+		ORDER BY created_at DESC, id DESC
+	`
+
+	if err := r.db.SelectContext(ctx, &rows, query, tenantID(ctx), pendingOnly, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	invitations := make([]*user.Invitation, len(rows))
+	for i, row := range rows {
+		invitations[i] = row.toInvitation()
+	}
+
+	return invitations, nil
+}
+
+// Revoke revokes an invitation that was neither accepted nor revoked yet. It
+// returns ErrNotFound otherwise.
+func (r *PostgresInvitationRepository) Revoke(ctx context.Context, id int64) error {
+	query := `
+		UPDATE invitations
+		SET revoked_at = $1
+		WHERE id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+			AND ($3::BIGINT = 0 OR organization_id = $3)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeOthers revokes the other invitations of the email of an invitation
+// to its organization that were neither accepted nor revoked yet
+func (r *PostgresInvitationRepository) RevokeOthers(ctx context.Context, invitation *user.Invitation) error {
+	query := `
+		UPDATE invitations
+		SET revoked_at = $1
+		WHERE organization_id = $2 AND email = $3 AND id <> $4 AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), invitation.OrganizationID, invitation.Email, invitation.ID)
+	return err
+}
+
+// MarkAccepted marks an invitation as accepted. It returns ErrNotFound if the
+// invitation does not exist or is no longer pending.
+func (r *PostgresInvitationRepository) MarkAccepted(ctx context.Context, id int64) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE invitations
+		SET accepted_at = $1
+		WHERE id = $2 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, now, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetAcceptedUser records the user created by accepting an invitation
+func (r *PostgresInvitationRepository) SetAcceptedUser(ctx context.Context, id, userID int64) error {
+	query := `
+		UPDATE invitations
+		SET accepted_user_id = $1
+		WHERE id = $2 AND accepted_at IS NOT NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// LinkedIdentityRepository defines the interface for linked identity persistence operations
+type LinkedIdentityRepository interface {
+	Create(ctx context.Context, identity *user.LinkedIdentity) error
+	GetByIssuerSubject(ctx context.Context, issuer, subject string) (*user.LinkedIdentity, error)
+	ListByUser(ctx context.Context, userID int64) ([]*user.LinkedIdentity, error)
+	Touch(ctx context.Context, id int64, email string, loginAt time.Time) error
+	Delete(ctx context.Context, id, userID int64) error
+}
+
+// PostgresLinkedIdentityRepository is a PostgreSQL implementation of LinkedIdentityRepository
+type PostgresLinkedIdentityRepository struct {
+	db DBTX
+}
+
+// NewPostgresLinkedIdentityRepository creates a new PostgreSQL linked identity repository
+func NewPostgresLinkedIdentityRepository(db *sqlx.DB) *PostgresLinkedIdentityRepository {
+	return &PostgresLinkedIdentityRepository{db: db}
+}
+
+// Create inserts a new linked identity into the database
+func (r *PostgresLinkedIdentityRepository) Create(ctx context.Context, identity *user.LinkedIdentity) error {
+	query := `
+		INSERT INTO linked_identities (user_id, provider, issuer, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastLoginAt,
+	)
+
+	return row.Scan(&identity.ID)
+}
+
+// GetByIssuerSubject retrieves the identity of an account at a provider
+func (r *PostgresLinkedIdentityRepository) GetByIssuerSubject(ctx context.Context, issuer, subject string) (*user.LinkedIdentity, error) {
+	identity := &user.LinkedIdentity{}
+	query := `
+		SELECT id, user_id, provider, issuer, subject, email, created_at, last_login_at
+		FROM linked_identities
+		WHERE issuer = $1 AND subject = $2
+	`
+
+	err := r.db.GetContext(ctx, identity, query, issuer, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// ListByUser returns the identities linked to a user, oldest first
+func (r *PostgresLinkedIdentityRepository) ListByUser(ctx context.Context, userID int64) ([]*user.LinkedIdentity, error) {
+	identities := []*user.LinkedIdentity{}
+	query := `
+		SELECT id, user_id, provider, issuer, subject, email, created_at, last_login_at
+		FROM linked_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	if err := r.db.SelectContext(ctx, &identities, query, userID); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// Touch records a login with an identity and the email address the provider
+// reported for it
+func (r *PostgresLinkedIdentityRepository) Touch(ctx context.Context, id int64, email string, loginAt time.Time) error {
+	query := `
+		UPDATE linked_identities
+		SET last_login_at = $1, email = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, loginAt, email, id)
+	return err
+}
+
+// Delete unlinks an identity from a user
+func (r *PostgresLinkedIdentityRepository) Delete(ctx context.Context, id, userID int64) error {
+	query := `
+		DELETE FROM linked_identities
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// LoginThrottleRepository defines the interface for persisting failed login attempts
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, subjectType, subject string) (*user.LoginThrottle, error)
+	RecordFailure(ctx context.Context, subjectType, subject string, at, resetBefore time.Time) (*user.LoginThrottle, error)
+	Lock(ctx context.Context, subjectType, subject string, until time.Time) error
+	Reset(ctx context.Context, subjectType, subject string) error
+}
+
+// PostgresLoginThrottleRepository is a PostgreSQL implementation of LoginThrottleRepository
+type PostgresLoginThrottleRepository struct {
+	db DBTX
+}
+
+// NewPostgresLoginThrottleRepository creates a new PostgreSQL login throttle repository
+func NewPostgresLoginThrottleRepository(db *sqlx.DB) *PostgresLoginThrottleRepository {
+	return &PostgresLoginThrottleRepository{db: db}
+}
+
+// Get retrieves the failed login attempts of a subject
+func (r *PostgresLoginThrottleRepository) Get(ctx context.Context, subjectType, subject string) (*user.LoginThrottle, error) {
+	var t user.LoginThrottle
+	query := `
+		SELECT subject_type, subject, failed_attempts, lockouts, locked_until, last_failed_at
+		FROM login_throttles
+		WHERE subject_type = $1 AND subject = $2
+	`
+
+	if err := r.db.GetContext(ctx, &t, query, subjectType, subject); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// RecordFailure atomically counts a failed login attempt of a subject and
+// returns its updated attempts. Attempts and lockouts of a subject whose last
+// failure and lock both ended before resetBefore start over.
+func (r *PostgresLoginThrottleRepository) RecordFailure(ctx context.Context, subjectType, subject string, at, resetBefore time.Time) (*user.LoginThrottle, error) {
+	var t user.LoginThrottle
+	query := `
+		INSERT INTO login_throttles (subject_type, subject, failed_attempts, last_failed_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (subject_type, subject) DO UPDATE SET
+			failed_attempts = CASE WHEN GREATEST(login_throttles.last_failed_at, login_throttles.locked_until) < $4
+				THEN 1 ELSE login_throttles.failed_attempts + 1 END,
+			lockouts = CASE WHEN GREATEST(login_throttles.last_failed_at, login_throttles.locked_until) < $4
+				THEN 0 ELSE login_throttles.lockouts END,
+			last_failed_at = $3
+		RETURNING subject_type, subject, failed_attempts, lockouts, locked_until, last_failed_at
+	`
+
+	if err := r.db.GetContext(ctx, &t, query, subjectType, subject, at, resetBefore); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Lock locks a subject until the given time, counting the lockout and
+// starting its failed attempts over
+func (r *PostgresLoginThrottleRepository) Lock(ctx context.Context, subjectType, subject string, until time.Time) error {
+	query := `
+		UPDATE login_throttles
+		SET locked_until = $1, lockouts = lockouts + 1, failed_attempts = 0
+		WHERE subject_type = $2 AND subject = $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, until, subjectType, subject)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Reset forgets the failed login attempts of a subject and unlocks it
+func (r *PostgresLoginThrottleRepository) Reset(ctx context.Context, subjectType, subject string) error {
+	query := `DELETE FROM login_throttles WHERE subject_type = $1 AND subject = $2`
+
+	_, err := r.db.ExecContext(ctx, query, subjectType, subject)
+	return err
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// OAuthClientRepository defines the interface for OAuth client persistence
+// operations. Except for GetByClientID, whose client ID is enough to find a
+// client, methods only see the clients of the organization ctx is scoped to,
+// if any.
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client *user.OAuthClient) error
+	GetByClientID(ctx context.Context, clientID string) (*user.OAuthClient, error)
+	List(ctx context.Context) ([]*user.OAuthClient, error)
+	Delete(ctx context.Context, clientID string) error
+}
+
+// PostgresOAuthClientRepository is a PostgreSQL implementation of OAuthClientRepository
+type PostgresOAuthClientRepository struct {
+	db DBTX
+}
+
+// NewPostgresOAuthClientRepository creates a new PostgreSQL OAuth client repository
+func NewPostgresOAuthClientRepository(db *sqlx.DB) *PostgresOAuthClientRepository {
+	return &PostgresOAuthClientRepository{db: db}
+}
+
+// oauthClientRow is an OAuth client as stored, with its redirect URIs in a
+// text array
+type oauthClientRow struct {
+	user.OAuthClient
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+}
+
+// toOAuthClient converts a stored row into an OAuth client
+func (r *oauthClientRow) toOAuthClient() *user.OAuthClient {
+	client := r.OAuthClient
+	client.RedirectURIs = []string(r.RedirectURIs)
+	return &client
+}
+
+// Create inserts a new OAuth client into the database. Clients without an
+// organization are created in the default organization.
+func (r *PostgresOAuthClientRepository) Create(ctx context.Context, client *user.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (organization_id, client_id, name, secret_hash, redirect_uris, created_by, created_at)
+		VALUES (COALESCE(NULLIF($1::BIGINT, 0), ` + defaultOrganization + `), $2, $3, $4, $5, $6, $7)
+		RETURNING id, organization_id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		client.OrganizationID,
+		client.ClientID,
+		client.Name,
+		client.SecretHash,
+		pq.Array(client.RedirectURIs),
+		client.CreatedBy,
+		client.CreatedAt,
+	)
+
+	return row.Scan(&client.ID, &client.OrganizationID)
+}
+
+// GetByClientID retrieves an OAuth client by its public identifier, whatever
+// the organization ctx is scoped to
+func (r *PostgresOAuthClientRepository) GetByClientID(ctx context.Context, clientID string) (*user.OAuthClient, error) {
+	row := &oauthClientRow{}
+	query := `
+		SELECT id, organization_id, client_id, name, secret_hash, redirect_uris, created_by, created_at
+		FROM oauth_clients
+		WHERE client_id = $1
+	`
+
+	err := r.db.GetContext(ctx, row, query, clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return row.toOAuthClient(), nil
+}
+
+// List returns the OAuth clients, oldest first
+func (r *PostgresOAuthClientRepository) List(ctx context.Context) ([]*user.OAuthClient, error) {
+	var rows []*oauthClientRow
+	query := `
+		SELECT id, organization_id, client_id, name, secret_hash, redirect_uris, created_by, created_at
+		FROM oauth_clients
+		WHERE ($1::BIGINT = 0 OR organization_id = $1)
+		ORDER BY id
+	`
+
+	if err := r.db.SelectContext(ctx, &rows, query, tenantID(ctx)); err != nil {
+		return nil, err
+	}
+
+	clients := make([]*user.OAuthClient, len(rows))
+	for i, row := range rows {
+		clients[i] = row.toOAuthClient()
+	}
+
+	return clients, nil
+}
+
+// Delete removes an OAuth client along with its pending authorization codes
+func (r *PostgresOAuthClientRepository) Delete(ctx context.Context, clientID string) error {
+	query := `DELETE FROM oauth_clients WHERE client_id = $1 AND ($2::BIGINT = 0 OR organization_id = $2)`
+
+	result, err := r.db.ExecContext(ctx, query, clientID, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
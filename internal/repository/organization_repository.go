@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// Organization repository errors
+var (
+	ErrDuplicateSlug = errors.New("organization slug already exists")
+	// ErrOrganizationNotEmpty is returned when deleting an organization that
+	// still has users, including deleted users not purged yet
+	ErrOrganizationNotEmpty = errors.New("organization still has users")
+)
+
+// foreignKeyViolation is the Postgres error code for foreign key violations
+const foreignKeyViolation = "23503"
+
+// OrganizationRepository defines the interface for organization and
+// membership persistence operations
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *user.Organization) error
+	GetByID(ctx context.Context, id int64) (*user.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*user.Organization, error)
+	List(ctx context.Context) ([]*user.Organization, error)
+	ListByMember(ctx context.Context, userID int64) ([]*user.Organization, error)
+	Update(ctx context.Context, org *user.Organization) error
+	Delete(ctx context.Context, id int64) error
+	AddMember(ctx context.Context, membership *user.Membership) error
+	GetMembership(ctx context.Context, organizationID, userID int64) (*user.Membership, error)
+	ListMembers(ctx context.Context, organizationID int64) ([]*user.Membership, error)
+	RemoveMember(ctx context.Context, organizationID, userID int64) error
+}
+
+// PostgresOrganizationRepository is a PostgreSQL implementation of OrganizationRepository
+type PostgresOrganizationRepository struct {
+	db DBTX
+}
+
+// NewPostgresOrganizationRepository creates a new PostgreSQL organization repository
+func NewPostgresOrganizationRepository(db *sqlx.DB) *PostgresOrganizationRepository {
+	return &PostgresOrganizationRepository{db: db}
+}
+
+// translateOrganizationWriteError converts unique violations on the slug
+// into ErrDuplicateSlug
+func translateOrganizationWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicateSlug
+	}
+	return err
+}
+
+// Create inserts a new organization into the database
+func (r *PostgresOrganizationRepository) Create(ctx context.Context, org *user.Organization) error {
+	query := `
+		INSERT INTO organizations (slug, name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(ctx, query, org.Slug, org.Name, org.CreatedAt, org.UpdatedAt)
+	return translateOrganizationWriteError(row.Scan(&org.ID))
+}
+
+// GetByID retrieves an organization by ID
+func (r *PostgresOrganizationRepository) GetByID(ctx context.Context, id int64) (*user.Organization, error) {
+	return r.get(ctx, `SELECT id, slug, name, created_at, updated_at FROM organizations WHERE id = $1`, id)
+}
+
+// GetBySlug retrieves an organization by slug
+func (r *PostgresOrganizationRepository) GetBySlug(ctx context.Context, slug string) (*user.Organization, error) {
+	return r.get(ctx, `SELECT id, slug, name, created_at, updated_at FROM organizations WHERE slug = $1`, slug)
+}
+
+// get retrieves the organization selected by the query
+func (r *PostgresOrganizationRepository) get(ctx context.Context, query string, args ...interface{}) (*user.Organization, error) {
+	org := &user.Organization{}
+
+	err := r.db.GetContext(ctx, org, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// List returns every organization, oldest first
+func (r *PostgresOrganizationRepository) List(ctx context.Context) ([]*user.Organization, error) {
+	orgs := []*user.Organization{}
+	query := `
+		SELECT id, slug, name, created_at, updated_at
+		FROM organizations
+		ORDER BY id
+	`
+
+	if err := r.db.SelectContext(ctx, &orgs, query); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// ListByMember returns the organizations a user is a member of, oldest first
+func (r *PostgresOrganizationRepository) ListByMember(ctx context.Context, userID int64) ([]*user.Organization, error) {
+	orgs := []*user.Organization{}
+	query := `
+		SELECT o.id, o.slug, o.name, o.created_at, o.updated_at
+		FROM organizations o
+		JOIN organization_memberships m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.id
+	`
+
+	if err := r.db.SelectContext(ctx, &orgs, query, userID); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// Update updates the name and slug of an existing organization
+func (r *PostgresOrganizationRepository) Update(ctx context.Context, org *user.Organization) error {
+	updatedAt := time.Now().UTC()
+
+	query := `
+		UPDATE organizations
+		SET slug = $1, name = $2, updated_at = $3
+		WHERE id = $4
+	`
+
+	result, err := r.db.ExecContext(ctx, query, org.Slug, org.Name, updatedAt, org.ID)
+	if err != nil {
+		return translateOrganizationWriteError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	org.UpdatedAt = updatedAt
+	return nil
+}
+
+// Delete removes an organization along with its memberships. It returns
+// ErrOrganizationNotEmpty while users still belong to it.
+func (r *PostgresOrganizationRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return ErrOrganizationNotEmpty
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddMember makes a user a member of an organization, replacing the role of
+// an existing member
+func (r *PostgresOrganizationRepository) AddMember(ctx context.Context, membership *user.Membership) error {
+	query := `
+		INSERT INTO organization_memberships (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+
+	_, err := r.db.ExecContext(ctx, query, membership.OrganizationID, membership.UserID, membership.Role, membership.CreatedAt)
+	return err
+}
+
+// GetMembership retrieves the membership of a user in an organization
+func (r *PostgresOrganizationRepository) GetMembership(ctx context.Context, organizationID, userID int64) (*user.Membership, error) {
+	membership := &user.Membership{}
+	query := `
+		SELECT organization_id, user_id, role, created_at
+		FROM organization_memberships
+		WHERE organization_id = $1 AND user_id = $2
+	`
+
+	err := r.db.GetContext(ctx, membership, query, organizationID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// ListMembers returns the memberships of an organization, oldest first
+func (r *PostgresOrganizationRepository) ListMembers(ctx context.Context, organizationID int64) ([]*user.Membership, error) {
+	memberships := []*user.Membership{}
+	query := `
+		SELECT organization_id, user_id, role, created_at
+		FROM organization_memberships
+		WHERE organization_id = $1
+		ORDER BY created_at, user_id
+	`
+
+	if err := r.db.SelectContext(ctx, &memberships, query, organizationID); err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// RemoveMember removes a user from an organization, along with the roles
+// they were granted in it
+func (r *PostgresOrganizationRepository) RemoveMember(ctx context.Context, organizationID, userID int64) error {
+	query := `
+		WITH revoked AS (
+			DELETE FROM user_roles WHERE organization_id = $1 AND user_id = $2
+		)
+		DELETE FROM organization_memberships
+		WHERE organization_id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, organizationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PasswordHistoryRepository defines the interface for persisting the previous passwords of users
+type PasswordHistoryRepository interface {
+	Add(ctx context.Context, userID int64, passwordHash string) error
+	ListRecent(ctx context.Context, userID int64, limit int) ([]string, error)
+	Prune(ctx context.Context, userID int64, keep int) error
+}
+
+// PostgresPasswordHistoryRepository is a PostgreSQL implementation of PasswordHistoryRepository
+type PostgresPasswordHistoryRepository struct {
+	db DBTX
+}
+
+// NewPostgresPasswordHistoryRepository creates a new PostgreSQL password history repository
+func NewPostgresPasswordHistoryRepository(db *sqlx.DB) *PostgresPasswordHistoryRepository {
+	return &PostgresPasswordHistoryRepository{db: db}
+}
+
+// Add records a password hash of a user
+func (r *PostgresPasswordHistoryRepository) Add(ctx context.Context, userID int64, passwordHash string) error {
+	query := `INSERT INTO password_history (user_id, password_hash, created_at) VALUES ($1, $2, $3)`
+
+	_, err := r.db.ExecContext(ctx, query, userID, passwordHash, time.Now().UTC())
+	return err
+}
+
+// ListRecent returns up to limit password hashes of a user, most recent first
+func (r *PostgresPasswordHistoryRepository) ListRecent(ctx context.Context, userID int64, limit int) ([]string, error) {
+	hashes := []string{}
+	query := `
+		SELECT password_hash
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	if err := r.db.SelectContext(ctx, &hashes, query, userID, limit); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// Prune removes all but the keep most recent password hashes of a user
+func (r *PostgresPasswordHistoryRepository) Prune(ctx context.Context, userID int64, keep int) error {
+	query := `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, keep)
+	return err
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// PasswordResetRepository defines the interface for password reset token persistence operations
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *user.PasswordResetToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*user.PasswordResetToken, error)
+	MarkUsed(ctx context.Context, id int64) error
+	InvalidateAllForUser(ctx context.Context, userID int64) error
+}
+
+// PostgresPasswordResetRepository is a PostgreSQL implementation of PasswordResetRepository
+type PostgresPasswordResetRepository struct {
+	db DBTX
+}
+
+// NewPostgresPasswordResetRepository creates a new PostgreSQL password reset token repository
+func NewPostgresPasswordResetRepository(db *sqlx.DB) *PostgresPasswordResetRepository {
+	return &PostgresPasswordResetRepository{db: db}
+}
+
+// Create inserts a new password reset token into the database
+func (r *PostgresPasswordResetRepository) Create(ctx context.Context, token *user.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	return row.Scan(&token.ID)
+}
+
+// GetByHash retrieves a password reset token by the hash of its value
+func (r *PostgresPasswordResetRepository) GetByHash(ctx context.Context, tokenHash string) (*user.PasswordResetToken, error) {
+	token := &user.PasswordResetToken{}
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+	`
+
+	err := r.db.GetContext(ctx, token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed marks a password reset token as used. It returns ErrNotFound
+// if the token does not exist or was already used.
+func (r *PostgresPasswordResetRepository) MarkUsed(ctx context.Context, id int64) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// InvalidateAllForUser marks every unused password reset token of a user as used
+func (r *PostgresPasswordResetRepository) InvalidateAllForUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = $1
+		WHERE user_id = $2 AND used_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID)
+	return err
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// RefreshTokenRepository defines the interface for refresh token persistence operations
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *user.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*user.RefreshToken, error)
+	Revoke(ctx context.Context, id int64, replacedByID *int64) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+	RevokeAllForSession(ctx context.Context, sessionID int64) error
+}
+
+// PostgresRefreshTokenRepository is a PostgreSQL implementation of RefreshTokenRepository
+type PostgresRefreshTokenRepository struct {
+	db DBTX
+}
+
+// NewPostgresRefreshTokenRepository creates a new PostgreSQL refresh token repository
+func NewPostgresRefreshTokenRepository(db *sqlx.DB) *PostgresRefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{db: db}
+}
+
+// Create inserts a new refresh token into the database
+func (r *PostgresRefreshTokenRepository) Create(ctx context.Context, token *user.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, session_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.SessionID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	return row.Scan(&token.ID)
+}
+
+// GetByHash retrieves a refresh token by the hash of its value
+func (r *PostgresRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*user.RefreshToken, error) {
+	token := &user.RefreshToken{}
+	query := `
+		SELECT id, user_id, session_id, token_hash, expires_at, revoked_at, replaced_by_id, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	err := r.db.GetContext(ctx, token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Revoke marks a refresh token as revoked, optionally recording the token that replaced it
+func (r *PostgresRefreshTokenRepository) Revoke(ctx context.Context, id int64, replacedByID *int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1, replaced_by_id = $2
+		WHERE id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), replacedByID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every active refresh token belonging to a user
+func (r *PostgresRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID)
+	return err
+}
+
+// RevokeAllForSession revokes every active refresh token of a session
+func (r *PostgresRefreshTokenRepository) RevokeAllForSession(ctx context.Context, sessionID int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE session_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), sessionID)
+	return err
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// RoleRepository defines the interface for role assignment persistence
+// operations. Roles are granted to users within an organization: except for
+// HasRoleAnywhere, methods act on the roles of the organization ctx is scoped
+// to, or on those of the organization of the user for unscoped calls. Roles
+// granted to a group apply within the organization of the group.
+type RoleRepository interface {
+	ListByUser(ctx context.Context, userID int64) ([]user.Role, error)
+	ListEffectiveByUser(ctx context.Context, userID int64) ([]user.Role, error)
+	HasRoleAnywhere(ctx context.Context, userID int64, role user.Role) (bool, error)
+	Grant(ctx context.Context, userID int64, role user.Role, grantedBy *int64) error
+	Revoke(ctx context.Context, userID int64, role user.Role) error
+	ListByGroup(ctx context.Context, groupID int64) ([]user.Role, error)
+	GrantToGroup(ctx context.Context, groupID int64, role user.Role, grantedBy *int64) error
+	RevokeFromGroup(ctx context.Context, groupID int64, role user.Role) error
+}
+
+// PostgresRoleRepository is a PostgreSQL implementation of RoleRepository
+type PostgresRoleRepository struct {
+	db DBTX
+}
+
+// NewPostgresRoleRepository creates a new PostgreSQL role repository
+func NewPostgresRoleRepository(db *sqlx.DB) *PostgresRoleRepository {
+	return &PostgresRoleRepository{db: db}
+}
+
+// roleOrganization selects the organization roles are granted in: the one
+// given as $2, or the organization of the user given as $1 when it is 0
+const roleOrganization = "COALESCE(NULLIF($2::BIGINT, 0), (SELECT organization_id FROM users WHERE id = $1))"
+
+// ListByUser retrieves the roles granted to a user
+func (r *PostgresRoleRepository) ListByUser(ctx context.Context, userID int64) ([]user.Role, error) {
+	roles := []user.Role{}
+	query := `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 AND ur.organization_id = ` + roleOrganization + `
+		ORDER BY r.name
+	`
+
+	err := r.db.SelectContext(ctx, &roles, query, userID, tenantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// effectiveGroups is a recursive CTE selecting the groups the user given as $1
+// is in, directly or through nested groups, like in
+// GroupRepository.ListEffectiveByUser
+const effectiveGroups = `
+	WITH RECURSIVE effective (id) AS (
+		SELECT group_id FROM group_user_members WHERE user_id = $1
+		UNION
+		SELECT s.parent_id
+		FROM group_subgroups s
+		JOIN effective e ON s.child_id = e.id
+	)
+`
+
+// ListEffectiveByUser retrieves the roles granted to a user, either directly
+// or through the groups they are in
+func (r *PostgresRoleRepository) ListEffectiveByUser(ctx context.Context, userID int64) ([]user.Role, error) {
+	roles := []user.Role{}
+	query := effectiveGroups + `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 AND ur.organization_id = ` + roleOrganization + `
+		UNION
+		SELECT r.name
+		FROM group_roles gr
+		JOIN roles r ON r.id = gr.role_id
+		JOIN groups g ON g.id = gr.group_id
+		JOIN effective e ON e.id = g.id
+		WHERE g.organization_id = ` + roleOrganization + `
+		ORDER BY name
+	`
+
+	err := r.db.SelectContext(ctx, &roles, query, userID, tenantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// HasRoleAnywhere reports whether a user was granted a role in any
+// organization, either directly or through the groups they are in
+func (r *PostgresRoleRepository) HasRoleAnywhere(ctx context.Context, userID int64, role user.Role) (bool, error) {
+	var exists bool
+	query := effectiveGroups + `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.name = $2
+		) OR EXISTS (
+			SELECT 1
+			FROM group_roles gr
+			JOIN roles r ON r.id = gr.role_id
+			JOIN effective e ON e.id = gr.group_id
+			WHERE r.name = $2
+		)
+	`
+
+	if err := r.db.GetContext(ctx, &exists, query, userID, role); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Grant assigns a role to a user. Granting a role the user already has is a no-op.
+func (r *PostgresRoleRepository) Grant(ctx context.Context, userID int64, role user.Role, grantedBy *int64) error {
+	query := `
+		INSERT INTO user_roles (user_id, organization_id, role_id, granted_by)
+		SELECT $1::BIGINT, ` + roleOrganization + `, id, $4::BIGINT FROM roles WHERE name = $3
+		ON CONFLICT (user_id, organization_id, role_id) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, tenantID(ctx), role, grantedBy)
+	return err
+}
+
+// Revoke removes a role from a user
+func (r *PostgresRoleRepository) Revoke(ctx context.Context, userID int64, role user.Role) error {
+	query := `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND organization_id = ` + roleOrganization + `
+			AND role_id = (SELECT id FROM roles WHERE name = $3)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, tenantID(ctx), role)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListByGroup retrieves the roles granted to a group
+func (r *PostgresRoleRepository) ListByGroup(ctx context.Context, groupID int64) ([]user.Role, error) {
+	roles := []user.Role{}
+	query := `
+		SELECT r.name
+		FROM group_roles gr
+		JOIN roles r ON r.id = gr.role_id
+		WHERE gr.group_id = $1
+		ORDER BY r.name
+	`
+
+	if err := r.db.SelectContext(ctx, &roles, query, groupID); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GrantToGroup assigns a role to a group. Granting a role the group already
+// has is a no-op.
+func (r *PostgresRoleRepository) GrantToGroup(ctx context.Context, groupID int64, role user.Role, grantedBy *int64) error {
+	query := `
+		INSERT INTO group_roles (group_id, role_id, granted_by)
+		SELECT $1::BIGINT, id, $3::BIGINT FROM roles WHERE name = $2
+		ON CONFLICT (group_id, role_id) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, groupID, role, grantedBy)
+	return err
+}
+
+// RevokeFromGroup removes a role from a group
+func (r *PostgresRoleRepository) RevokeFromGroup(ctx context.Context, groupID int64, role user.Role) error {
+	query := `
+		DELETE FROM group_roles
+		WHERE group_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, role)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
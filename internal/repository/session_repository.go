@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// SessionRepository defines the interface for session persistence operations
+type SessionRepository interface {
+	Create(ctx context.Context, session *user.Session) error
+	GetByID(ctx context.Context, id int64) (*user.Session, error)
+	ListActiveByUser(ctx context.Context, userID int64) ([]*user.Session, error)
+	Touch(ctx context.Context, id int64, ip string, seenAt time.Time) error
+	Extend(ctx context.Context, id int64, expiresAt time.Time) error
+	Revoke(ctx context.Context, id, userID int64) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+}
+
+// PostgresSessionRepository is a PostgreSQL implementation of SessionRepository
+type PostgresSessionRepository struct {
+	db DBTX
+}
+
+// NewPostgresSessionRepository creates a new PostgreSQL session repository
+func NewPostgresSessionRepository(db *sqlx.DB) *PostgresSessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+// Create inserts a new session into the database
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *user.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, device, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		session.UserID,
+		session.Device,
+		session.IP,
+		session.UserAgent,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+	)
+
+	return row.Scan(&session.ID)
+}
+
+// GetByID retrieves a session by its ID
+func (r *PostgresSessionRepository) GetByID(ctx context.Context, id int64) (*user.Session, error) {
+	session := &user.Session{}
+	query := `
+		SELECT id, user_id, device, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	err := r.db.GetContext(ctx, session, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// ListActiveByUser returns the sessions of a user that are neither revoked
+// nor expired, most recently seen first
+func (r *PostgresSessionRepository) ListActiveByUser(ctx context.Context, userID int64) ([]*user.Session, error) {
+	sessions := []*user.Session{}
+	query := `
+		SELECT id, user_id, device, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC, id DESC
+	`
+
+	if err := r.db.SelectContext(ctx, &sessions, query, userID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Touch records that an active session was used from the given IP address
+func (r *PostgresSessionRepository) Touch(ctx context.Context, id int64, ip string, seenAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = $1, ip = $2
+		WHERE id = $3 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, seenAt, ip, id)
+	return err
+}
+
+// Extend moves the expiry of an active session, as its refresh token is rotated
+func (r *PostgresSessionRepository) Extend(ctx context.Context, id int64, expiresAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET expires_at = $1, last_seen_at = $2
+		WHERE id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, expiresAt, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Revoke ends an active session of a user
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, id, userID int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllForUser ends every active session of a user
+func (r *PostgresSessionRepository) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID)
+	return err
+}
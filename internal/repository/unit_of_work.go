@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DBTX is the subset of *sqlx.DB and *sqlx.Tx used by the PostgreSQL
+// repositories, so they can run both on their own and inside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// Repositories groups the repositories taking part in a unit of work
+type Repositories struct {
+	Users              UserRepository
+	Roles              RoleRepository
+	RefreshTokens      RefreshTokenRepository
+	EmailVerifications EmailVerificationRepository
+	PasswordResets     PasswordResetRepository
+	PasswordHistory    PasswordHistoryRepository
+	Sessions           SessionRepository
+	LinkedIdentities   LinkedIdentityRepository
+	Organizations      OrganizationRepository
+	Groups             GroupRepository
+	Invitations        InvitationRepository
+}
+
+// UnitOfWork runs multi-step operations atomically
+type UnitOfWork interface {
+	// Do calls fn with repositories bound to a single transaction. The
+	// transaction is committed if fn returns nil and rolled back otherwise.
+	Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
+
+// PostgresUnitOfWork is a PostgreSQL implementation of UnitOfWork
+type PostgresUnitOfWork struct {
+	db *sqlx.DB
+}
+
+// NewPostgresUnitOfWork creates a new PostgreSQL unit of work
+func NewPostgresUnitOfWork(db *sqlx.DB) *PostgresUnitOfWork {
+	return &PostgresUnitOfWork{db: db}
+}
+
+// Do runs fn inside a database transaction
+func (u *PostgresUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	repos := Repositories{
+		Users:              &PostgresUserRepository{db: tx},
+		Roles:              &PostgresRoleRepository{db: tx},
+		RefreshTokens:      &PostgresRefreshTokenRepository{db: tx},
+		EmailVerifications: &PostgresEmailVerificationRepository{db: tx},
+		PasswordResets:     &PostgresPasswordResetRepository{db: tx},
+		PasswordHistory:    &PostgresPasswordHistoryRepository{db: tx},
+		Sessions:           &PostgresSessionRepository{db: tx},
+		LinkedIdentities:   &PostgresLinkedIdentityRepository{db: tx},
+		Organizations:      &PostgresOrganizationRepository{db: tx},
+		Groups:             &PostgresGroupRepository{db: tx},
+		Invitations:        &PostgresInvitationRepository{db: tx},
+	}
+
+	if err := fn(ctx, repos); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
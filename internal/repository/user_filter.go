@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+)
+
+// UserSortField is a field users can be sorted by
+type UserSortField string
+
+// Supported sort fields
+const (
+	UserSortByID        UserSortField = "id"
+	UserSortByUsername  UserSortField = "username"
+	UserSortByEmail     UserSortField = "email"
+	UserSortByFullName  UserSortField = "full_name"
+	UserSortByCreatedAt UserSortField = "created_at"
+)
+
+// ErrUnsupportedSortField is returned when users are sorted by a field that
+// is not one of the supported sort fields
+var ErrUnsupportedSortField = errors.New("unsupported sort field")
+
+// userSortColumns maps each sort field to the column it orders by. The empty
+// field sorts by id to keep the historical ordering.
+var userSortColumns = map[UserSortField]string{
+	"":                  "id",
+	UserSortByID:        "id",
+	UserSortByUsername:  "username",
+	UserSortByEmail:     "email",
+	UserSortByFullName:  "full_name",
+	UserSortByCreatedAt: "created_at",
+}
+
+// UserFilter narrows down and orders the users returned by UserRepository.List.
+// Zero values disable the corresponding filter.
+type UserFilter struct {
+	// UsernamePrefix and EmailPrefix match case-insensitively
+	UsernamePrefix string
+	EmailPrefix    string
+	// CreatedAfter and CreatedBefore bound created_at, inclusive and exclusive respectively
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Query matches any part of the username, email or full name
+	Query    string
+	SortBy   UserSortField
+	SortDesc bool
+	// OrganizationID limits the users to those of one organization
+	OrganizationID int64
+	Status         user.Status
+}
+
+// scoped returns the filter limited to the organization ctx is scoped to, if any
+func (f UserFilter) scoped(ctx context.Context) UserFilter {
+	if id := tenantID(ctx); id != 0 {
+		f.OrganizationID = id
+	}
+	return f
+}
+
+// whereClause builds the SQL conditions for the filter and their positional
+// arguments, starting at $1
+func (f UserFilter) whereClause() (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.OrganizationID != 0 {
+		add("organization_id = $%d", f.OrganizationID)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.UsernamePrefix != "" {
+		add("lower(username) LIKE $%d", strings.ToLower(escapeLike(f.UsernamePrefix))+"%")
+	}
+	if f.EmailPrefix != "" {
+		add("lower(email) LIKE $%d", strings.ToLower(escapeLike(f.EmailPrefix))+"%")
+	}
+	if f.CreatedAfter != nil {
+		add("created_at >= $%d", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		add("created_at < $%d", *f.CreatedBefore)
+	}
+	if f.Query != "" {
+		// Backed by the trigram index on the same expression
+		add("(username || ' ' || email || ' ' || full_name) ILIKE $%d", "%"+escapeLike(f.Query)+"%")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// orderBy returns the ORDER BY clause for the filter. Ties are broken by id so
+// pages stay stable for non-unique sort columns.
+func (f UserFilter) orderBy() (string, error) {
+	column, ok := userSortColumns[f.SortBy]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnsupportedSortField, f.SortBy)
+	}
+
+	direction := "ASC"
+	if f.SortDesc {
+		direction = "DESC"
+	}
+
+	if column == "id" {
+		return "id " + direction, nil
+	}
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction), nil
+}
+
+// keysetCondition returns the SQL condition selecting the rows that sort after
+// the cursor, with positional arguments numbered after the first argOffset
+func (f UserFilter) keysetCondition(after *UserCursor, argOffset int) (string, []interface{}, error) {
+	column, ok := userSortColumns[f.SortBy]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %q", ErrUnsupportedSortField, f.SortBy)
+	}
+
+	operator := ">"
+	if f.SortDesc {
+		operator = "<"
+	}
+
+	if column == "id" {
+		return fmt.Sprintf("id %s $%d", operator, argOffset+1), []interface{}{after.ID}, nil
+	}
+
+	var value interface{} = after.Value
+	if column == "created_at" {
+		createdAt, err := time.Parse(time.RFC3339Nano, after.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid cursor value %q: %w", after.Value, err)
+		}
+		value = createdAt
+	}
+
+	condition := fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, operator, argOffset+1, argOffset+2)
+	return condition, []interface{}{value, after.ID}, nil
+}
+
+// UserCursor marks a position in a sorted list of users: the sort column value
+// and id of the last user of the previous page
+type UserCursor struct {
+	Value string
+	ID    int64
+}
+
+// NewUserCursor returns the cursor positioned at u for the given sort field
+func NewUserCursor(u *user.User, sortBy UserSortField) *UserCursor {
+	cursor := &UserCursor{ID: u.ID}
+
+	switch sortBy {
+	case UserSortByUsername:
+		cursor.Value = u.Username
+	case UserSortByEmail:
+		cursor.Value = u.Email
+	case UserSortByFullName:
+		cursor.Value = u.FullName
+	case UserSortByCreatedAt:
+		cursor.Value = u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -4,31 +4,89 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/tenant"
 )
 
 // Common repository errors
 var (
-	ErrNotFound = errors.New("record not found")
+	ErrNotFound          = errors.New("record not found")
+	ErrVersionConflict   = errors.New("record was modified by another request")
+	ErrDuplicateUsername = errors.New("username already exists")
+	ErrDuplicateEmail    = errors.New("email already exists")
 )
 
-// UserRepository defines the interface for user persistence operations
+// uniqueViolation is the Postgres error code for unique constraint violations
+const uniqueViolation = "23505"
+
+// translateUserWriteError converts unique violations on the users table into
+// ErrDuplicateUsername or ErrDuplicateEmail
+func translateUserWriteError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return err
+	}
+
+	switch pqErr.Constraint {
+	case "idx_users_username_active":
+		return ErrDuplicateUsername
+	case "idx_users_email_active":
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+// defaultOrganization selects the ID of the default organization
+const defaultOrganization = "(SELECT id FROM organizations WHERE slug = '" + user.DefaultOrganizationSlug + "')"
+
+// tenantID returns the organization the calls made with ctx are scoped to, or
+// 0 if they act on every organization
+func tenantID(ctx context.Context) int64 {
+	id, _ := tenant.FromContext(ctx)
+	return id
+}
+
+// UserRepository defines the interface for user persistence operations.
+// Except for GetByEmail, every method only sees the users of the organization
+// ctx is scoped to, if any. Usernames are only unique within an organization:
+// unscoped calls create users and look up usernames in the default
+// organization.
 type UserRepository interface {
 	Create(ctx context.Context, user *user.User) error
 	GetByID(ctx context.Context, id int64) (*user.User, error)
 	GetByUsername(ctx context.Context, username string) (*user.User, error)
 	GetByEmail(ctx context.Context, email string) (*user.User, error)
 	Update(ctx context.Context, user *user.User) error
-	Delete(ctx context.Context, id int64) error
-	List(ctx context.Context, offset, limit int) ([]*user.User, int, error)
+	Delete(ctx context.Context, id, version int64) error
+	GetDeletedByID(ctx context.Context, id int64) (*user.User, error)
+	Exists(ctx context.Context, id int64) (bool, error)
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, id int64) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	LockUniqueFields(ctx context.Context, username, email string) error
+	MarkEmailVerified(ctx context.Context, id int64, email string, verifiedAt time.Time) error
+	SetStatus(ctx context.Context, user *user.User) error
+	RehashPassword(ctx context.Context, id int64, oldHash, newHash string) error
+	SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error
+	EnableTOTP(ctx context.Context, id int64, enabledAt time.Time) error
+	DisableTOTP(ctx context.Context, id int64) error
+	UseTOTPStep(ctx context.Context, id, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+	List(ctx context.Context, filter UserFilter, offset, limit int) ([]*user.User, int, error)
+	ListAfter(ctx context.Context, filter UserFilter, after *UserCursor, limit int) ([]*user.User, error)
+	Count(ctx context.Context, filter UserFilter) (int, error)
 }
 
 // PostgresUserRepository is a PostgreSQL implementation of UserRepository
 type PostgresUserRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 // NewPostgresUserRepository creates a new PostgreSQL user repository
@@ -38,36 +96,65 @@ func NewPostgresUserRepository(db *sqlx.DB) *PostgresUserRepository {
 
 // Create inserts a new user into the database
 func (r *PostgresUserRepository) Create(ctx context.Context, user *user.User) error {
+	// Callers scoped to an organization can only add users to it
+	organizationID := tenantID(ctx)
+	if organizationID == 0 {
+		organizationID = user.OrganizationID
+	}
+
 	query := `
-		INSERT INTO users (username, email, password_hash, full_name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
+		INSERT INTO users (organization_id, username, email, password_hash, full_name, password_changed_at, status, created_at, updated_at)
+		VALUES (COALESCE(NULLIF($1::BIGINT, 0), ` + defaultOrganization + `), $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, organization_id
 	`
 
 	row := r.db.QueryRowContext(
 		ctx,
 		query,
+		organizationID,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
 		user.FullName,
+		user.PasswordChangedAt,
+		user.Status,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
 
-	return row.Scan(&user.ID)
+	return translateUserWriteError(row.Scan(&user.ID, &user.OrganizationID))
+}
+
+// LockUniqueFields serializes writes claiming the same username or email by
+// taking transaction-scoped advisory locks on them. It must be called inside
+// a unit of work, before checking that the values are free. Usernames are
+// locked in the organization GetByUsername looks them up in, and email
+// addresses across all organizations.
+func (r *PostgresUserRepository) LockUniqueFields(ctx context.Context, username, email string) error {
+	// Lock in a fixed order so two writers never wait on each other
+	query := `
+		SELECT pg_advisory_xact_lock(hashtext(key))
+		FROM unnest(ARRAY[
+			'username:' || COALESCE(NULLIF($1::BIGINT, 0), ` + defaultOrganization + `) || ':' || $2,
+			'email:' || $3
+		]::text[]) AS key
+		ORDER BY key
+	`
+
+	_, err := r.db.ExecContext(ctx, query, tenantID(ctx), strings.ToLower(username), strings.ToLower(email))
+	return err
 }
 
 // GetByID retrieves a user by ID
 func (r *PostgresUserRepository) GetByID(ctx context.Context, id int64) (*user.User, error) {
 	user := &user.User{}
 	query := `
-		SELECT id, username, email, password_hash, full_name, created_at, updated_at
+		SELECT id, organization_id, username, email, password_hash, full_name, email_verified_at, password_changed_at, totp_secret, totp_enabled_at, status, status_reason, status_changed_at, status_changed_by, created_at, updated_at, deleted_at, version
 		FROM users
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2::BIGINT = 0 OR organization_id = $2)
 	`
 
-	err := r.db.GetContext(ctx, user, query, id)
+	err := r.db.GetContext(ctx, user, query, id, tenantID(ctx))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -82,12 +169,12 @@ func (r *PostgresUserRepository) GetByID(ctx context.Context, id int64) (*user.U
 func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username string) (*user.User, error) {
 	user := &user.User{}
 	query := `
-		SELECT id, username, email, password_hash, full_name, created_at, updated_at
+		SELECT id, organization_id, username, email, password_hash, full_name, email_verified_at, password_changed_at, totp_secret, totp_enabled_at, status, status_reason, status_changed_at, status_changed_by, created_at, updated_at, deleted_at, version
 		FROM users
-		WHERE username = $1
+		WHERE username = $1 AND deleted_at IS NULL AND organization_id = COALESCE(NULLIF($2::BIGINT, 0), ` + defaultOrganization + `)
 	`
 
-	err := r.db.GetContext(ctx, user, query, username)
+	err := r.db.GetContext(ctx, user, query, username, tenantID(ctx))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -98,13 +185,14 @@ func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username str
 	return user, nil
 }
 
-// GetByEmail retrieves a user by email
+// GetByEmail retrieves a user by email, whatever the organization ctx is
+// scoped to, as email addresses are unique across organizations
 func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	user := &user.User{}
 	query := `
-		SELECT id, username, email, password_hash, full_name, created_at, updated_at
+		SELECT id, organization_id, username, email, password_hash, full_name, email_verified_at, password_changed_at, totp_secret, totp_enabled_at, status, status_reason, status_changed_at, status_changed_by, created_at, updated_at, deleted_at, version
 		FROM users
-		WHERE email = $1
+		WHERE email = $1 AND deleted_at IS NULL
 	`
 
 	err := r.db.GetContext(ctx, user, query, email)
@@ -118,14 +206,16 @@ func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (
 	return user, nil
 }
 
-// Update updates an existing user
+// Update updates an existing user. The write only succeeds if the stored
+// version still matches user.Version, which is then incremented.
 func (r *PostgresUserRepository) Update(ctx context.Context, user *user.User) error {
-	user.UpdatedAt = time.Now().UTC()
+	updatedAt := time.Now().UTC()
 
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, password_hash = $3, full_name = $4, updated_at = $5
-		WHERE id = $6
+		SET username = $1, email = $2, password_hash = $3, full_name = $4, email_verified_at = $5,
+			password_changed_at = $6, updated_at = $7, version = version + 1
+		WHERE id = $8 AND deleted_at IS NULL AND version = $9 AND ($10::BIGINT = 0 OR organization_id = $10)
 	`
 
 	result, err := r.db.ExecContext(
@@ -135,9 +225,44 @@ func (r *PostgresUserRepository) Update(ctx context.Context, user *user.User) er
 		user.Email,
 		user.PasswordHash,
 		user.FullName,
-		user.UpdatedAt,
+		user.EmailVerifiedAt,
+		user.PasswordChangedAt,
+		updatedAt,
 		user.ID,
+		user.Version,
+		tenantID(ctx),
 	)
+	if err != nil {
+		return translateUserWriteError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return r.versionMismatchError(ctx, user.ID)
+	}
+
+	user.UpdatedAt = updatedAt
+	user.Version++
+
+	return nil
+}
+
+// MarkEmailVerified records that the user verified the given email address,
+// which activates pending users. It returns ErrNotFound if the user is gone or
+// no longer has that address.
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, id int64, email string, verifiedAt time.Time) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = $1, updated_at = $1, version = version + 1,
+			status = CASE WHEN status = 'pending' THEN 'active' ELSE status END
+		WHERE id = $2 AND email = $3 AND deleted_at IS NULL AND ($4::BIGINT = 0 OR organization_id = $4)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, verifiedAt, id, email, tenantID(ctx))
 	if err != nil {
 		return err
 	}
@@ -154,11 +279,62 @@ func (r *PostgresUserRepository) Update(ctx context.Context, user *user.User) er
 	return nil
 }
 
-// Delete removes a user by ID
-func (r *PostgresUserRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+// SetStatus saves the status of an existing user along with the reason, time
+// and actor of the change. Like Update, it fails with ErrVersionConflict if
+// the user was changed since it was read.
+func (r *PostgresUserRepository) SetStatus(ctx context.Context, user *user.User) error {
+	updatedAt := time.Now().UTC()
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	query := `
+		UPDATE users
+		SET status = $1, status_reason = $2, status_changed_at = $3, status_changed_by = $4,
+			updated_at = $5, version = version + 1
+		WHERE id = $6 AND deleted_at IS NULL AND version = $7 AND ($8::BIGINT = 0 OR organization_id = $8)
+	`
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		user.Status,
+		user.StatusReason,
+		user.StatusChangedAt,
+		user.StatusChangedBy,
+		updatedAt,
+		user.ID,
+		user.Version,
+		tenantID(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return r.versionMismatchError(ctx, user.ID)
+	}
+
+	user.UpdatedAt = updatedAt
+	user.Version++
+
+	return nil
+}
+
+// RehashPassword replaces the password hash of the user with a new hash of
+// the same password. The version and password_changed_at are left alone as
+// the password did not change. It returns ErrNotFound if the user is gone or
+// their password hash is no longer oldHash.
+func (r *PostgresUserRepository) RehashPassword(ctx context.Context, id int64, oldHash, newHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3 AND deleted_at IS NULL AND ($4::BIGINT = 0 OR organization_id = $4)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, newHash, id, oldHash, tenantID(ctx))
 	if err != nil {
 		return err
 	}
@@ -175,29 +351,365 @@ func (r *PostgresUserRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-// List retrieves a paginated list of users
-func (r *PostgresUserRepository) List(ctx context.Context, offset, limit int) ([]*user.User, int, error) {
-	users := []*user.User{}
+// SetTOTPSecret stores the encrypted TOTP secret of a user enrolling TOTP,
+// replacing any unconfirmed one. It returns ErrNotFound if the user is gone
+// or already has TOTP enabled.
+func (r *PostgresUserRepository) SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_last_step = NULL
+		WHERE id = $2 AND totp_enabled_at IS NULL AND deleted_at IS NULL AND ($3::BIGINT = 0 OR organization_id = $3)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, encryptedSecret, id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// EnableTOTP requires the enrolled TOTP secret of a user from now on
+func (r *PostgresUserRepository) EnableTOTP(ctx context.Context, id int64, enabledAt time.Time) error {
 	query := `
-		SELECT id, username, email, password_hash, full_name, created_at, updated_at
+		UPDATE users
+		SET totp_enabled_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL AND deleted_at IS NULL AND ($3::BIGINT = 0 OR organization_id = $3)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, enabledAt, id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DisableTOTP removes the TOTP secret of a user
+func (r *PostgresUserRepository) DisableTOTP(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3::BIGINT = 0 OR organization_id = $3)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted TOTP code. It returns
+// ErrNotFound if a code of the same or a later step was already accepted.
+func (r *PostgresUserRepository) UseTOTPStep(ctx context.Context, id, step int64) error {
+	query := `
+		UPDATE users
+		SET totp_last_step = $1
+		WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1) AND deleted_at IS NULL AND ($3::BIGINT = 0 OR organization_id = $3)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, step, id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user with the given
+// hashes. No hashes removes every recovery code.
+func (r *PostgresUserRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	if _, err := r.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if len(codeHashes) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at)
+		SELECT $1, unnest($2::text[]), $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, pq.Array(codeHashes), time.Now().UTC())
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It
+// returns ErrNotFound if the user has no such unused code.
+func (r *PostgresUserRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+			AND user_id IN (SELECT id FROM users WHERE ($4::BIGINT = 0 OR organization_id = $4))
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID, codeHash, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// versionMismatchError tells apart a conditional write that failed because
+// the user is gone from one that lost a race with another writer
+func (r *PostgresUserRepository) versionMismatchError(ctx context.Context, id int64) error {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+// Delete soft deletes a user by ID. The row is kept until it is purged. A
+// non-zero version makes the delete conditional on the stored version.
+func (r *PostgresUserRepository) Delete(ctx context.Context, id, version int64) error {
+	query := `
+		UPDATE users
+		SET deleted_at = $1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3::BIGINT = 0 OR version = $3) AND ($4::BIGINT = 0 OR organization_id = $4)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id, version, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return r.versionMismatchError(ctx, id)
+	}
+
+	return nil
+}
+
+// GetDeletedByID retrieves a soft deleted user by ID
+func (r *PostgresUserRepository) GetDeletedByID(ctx context.Context, id int64) (*user.User, error) {
+	user := &user.User{}
+	query := `
+		SELECT id, organization_id, username, email, password_hash, full_name, email_verified_at, password_changed_at, totp_secret, totp_enabled_at, status, status_reason, status_changed_at, status_changed_by, created_at, updated_at, deleted_at, version
 		FROM users
-		ORDER BY id
-		LIMIT $1 OFFSET $2
+		WHERE id = $1 AND deleted_at IS NOT NULL AND ($2::BIGINT = 0 OR organization_id = $2)
+	`
+
+	err := r.db.GetContext(ctx, user, query, id, tenantID(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Exists reports whether a user exists, whether soft deleted or not
+func (r *PostgresUserRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND ($2::BIGINT = 0 OR organization_id = $2))`
+
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, query, id, tenantID(ctx)); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Restore clears the deletion mark of a soft deleted user
+func (r *PostgresUserRepository) Restore(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NOT NULL AND ($3::BIGINT = 0 OR organization_id = $3)
 	`
 
-	err := r.db.SelectContext(ctx, &users, query, limit, offset)
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), id, tenantID(ctx))
+	if err != nil {
+		return translateUserWriteError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Purge permanently removes a soft deleted user by ID
+func (r *PostgresUserRepository) Purge(ctx context.Context, id int64) error {
+	query := `DELETE FROM users WHERE id = $1 AND deleted_at IS NOT NULL AND ($2::BIGINT = 0 OR organization_id = $2)`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeletedBefore permanently removes users soft deleted before the cutoff
+// and returns how many rows were removed
+func (r *PostgresUserRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND ($2::BIGINT = 0 OR organization_id = $2)`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, tenantID(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// List retrieves a filtered, sorted and paginated list of users along with
+// the total number of users matching the filter
+func (r *PostgresUserRepository) List(ctx context.Context, filter UserFilter, offset, limit int) ([]*user.User, int, error) {
+	where, args := filter.scoped(ctx).whereClause()
+
+	orderBy, err := filter.orderBy()
 	if err != nil {
 		return nil, 0, err
 	}
 
-	var count int
-	countQuery := `SELECT COUNT(*) FROM users`
+	users := []*user.User{}
+
+	query := fmt.Sprintf(`
+		SELECT id, organization_id, username, email, password_hash, full_name, email_verified_at, password_changed_at, totp_secret, totp_enabled_at, status, status_reason, status_changed_at, status_changed_by, created_at, updated_at, deleted_at, version
+		FROM users
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, orderBy, len(args)+1, len(args)+2)
+
+	err = r.db.SelectContext(ctx, &users, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	err = r.db.GetContext(ctx, &count, countQuery)
+	count, err := r.Count(ctx, filter)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	return users, count, nil
 }
+
+// ListAfter retrieves up to limit users matching the filter that sort after
+// the cursor. A nil cursor starts from the first user.
+func (r *PostgresUserRepository) ListAfter(ctx context.Context, filter UserFilter, after *UserCursor, limit int) ([]*user.User, error) {
+	where, args := filter.scoped(ctx).whereClause()
+
+	orderBy, err := filter.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
+	if after != nil {
+		condition, cursorArgs, err := filter.keysetCondition(after, len(args))
+		if err != nil {
+			return nil, err
+		}
+		where += " AND " + condition
+		args = append(args, cursorArgs...)
+	}
+
+	users := []*user.User{}
+
+	query := fmt.Sprintf(`
+		SELECT id, organization_id, username, email, password_hash, full_name, email_verified_at, password_changed_at, totp_secret, totp_enabled_at, status, status_reason, status_changed_at, status_changed_by, created_at, updated_at, deleted_at, version
+		FROM users
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d
+	`, where, orderBy, len(args)+1)
+
+	err = r.db.SelectContext(ctx, &users, query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Count returns the number of users matching the filter
+func (r *PostgresUserRepository) Count(ctx context.Context, filter UserFilter) (int, error) {
+	where, args := filter.scoped(ctx).whereClause()
+
+	var count int
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM users WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// apiKeyTouchInterval is how often the last use of an API key is recorded,
+// so that busy keys do not write to the database on every call
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyService is responsible for the API keys services use to call the API
+// on behalf of a user
+type APIKeyService struct {
+	userRepo repository.UserRepository
+	repo     repository.APIKeyRepository
+	authz    *Authorizer
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{
+		userRepo: userRepo,
+		repo:     repo,
+		authz:    NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// CreateAPIKey creates an API key acting as a user with the given scopes,
+// valid until expiresAt or, when it is nil, until revoked. It returns the
+// key, which is only stored hashed and cannot be shown again.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, expiresAt *time.Time) (*user.APIKey, string, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageAPIKeys, userID); err != nil {
+		return nil, "", err
+	}
+
+	permissions := make([]user.Permission, len(scopes))
+	for i, scope := range scopes {
+		permission, err := user.ParsePermission(scope)
+		if err != nil {
+			return nil, "", InvalidArgumentError("UNKNOWN_SCOPE", "scopes", err.Error(), err)
+		}
+		permissions[i] = permission
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", InvalidArgumentError("EXPIRY_IN_PAST", "expires_at", "expiry must be in the future", nil)
+	}
+
+	// Make sure the user exists
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, "", userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	token, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	key := user.APIKeyPrefix + token
+
+	apiKey := user.NewAPIKey(userID, name, key, auth.HashToken(key), permissions, expiresAt, actorID(ctx))
+	if err := s.repo.Create(ctx, apiKey); err != nil {
+		return nil, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+// ListAPIKeys returns the API keys of a user that were not revoked,
+// including expired ones
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID int64) ([]*user.APIKey, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageAPIKeys, userID); err != nil {
+		return nil, err
+	}
+
+	// Make sure the user exists
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// RevokeAPIKey revokes an API key of a user, rejecting it from then on
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageAPIKeys, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.Revoke(ctx, keyID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFoundError("API_KEY_NOT_FOUND", fmt.Sprintf("API key not found with ID %d", keyID), err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the principal an active API key acts as, limited
+// to the scopes of the key. It returns auth.ErrInvalidToken for unknown,
+// revoked or expired keys and keys of deleted, suspended or deactivated
+// users.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	if !strings.HasPrefix(key, user.APIKeyPrefix) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	apiKey, err := s.repo.GetByHash(ctx, auth.HashToken(key))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if !apiKey.IsActive() {
+		return nil, auth.ErrInvalidToken
+	}
+
+	u, err := s.userRepo.GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if !u.CanAuthenticate() {
+		return nil, auth.ErrInvalidToken
+	}
+
+	now := time.Now().UTC()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.repo.Touch(ctx, apiKey.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// An empty but non-nil list keeps a key without scopes from doing anything
+	scopes := apiKey.Scopes
+	if scopes == nil {
+		scopes = []user.Permission{}
+	}
+
+	return &auth.Principal{
+		UserID:         u.ID,
+		Username:       u.Username,
+		APIKeyID:       apiKey.ID,
+		Scopes:         scopes,
+		OrganizationID: u.OrganizationID,
+	}, nil
+}
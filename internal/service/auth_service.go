@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/tenant"
+)
+
+// Common authentication errors
+var (
+	ErrInvalidCredentials  = &Error{Kind: KindUnauthenticated, Reason: "INVALID_CREDENTIALS", Message: "invalid username or password"}
+	ErrInvalidRefreshToken = &Error{Kind: KindUnauthenticated, Reason: "INVALID_REFRESH_TOKEN", Message: "invalid or expired refresh token"}
+	ErrInvalidMFAToken     = &Error{Kind: KindUnauthenticated, Reason: "INVALID_MFA_TOKEN", Message: "invalid or expired MFA token"}
+	// ErrAccountSuspended and ErrAccountDeactivated are returned when users
+	// who cannot authenticate give the right password
+	ErrAccountSuspended   = &Error{Kind: KindPermissionDenied, Reason: "ACCOUNT_SUSPENDED", Message: "account is suspended"}
+	ErrAccountDeactivated = &Error{Kind: KindPermissionDenied, Reason: "ACCOUNT_DEACTIVATED", Message: "account is deactivated"}
+)
+
+// TokenPair is the result of a successful authentication. When MFAToken is
+// set the user has a second factor and only MFAToken is filled in; the login
+// is completed by passing it to VerifyMFA along with a code.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	User         *user.User
+	MFAToken     string
+}
+
+// AuthService is responsible for authenticating users and managing their tokens
+type AuthService struct {
+	userRepo   repository.UserRepository
+	orgRepo    repository.OrganizationRepository
+	tokenRepo  repository.RefreshTokenRepository
+	sessions   *SessionService
+	tokens     *auth.TokenManager
+	hasher     user.PasswordHasher
+	lockout    *LockoutService
+	mfa        *MFAService
+	refreshTTL time.Duration
+	mfaTTL     time.Duration
+}
+
+// NewAuthService creates a new authentication service verifying passwords
+// with the hasher and locking out repeated failed logins. Each login starts a
+// new session. Users with a second factor get an MFA token valid for mfaTTL
+// instead of a token pair.
+func NewAuthService(userRepo repository.UserRepository, orgRepo repository.OrganizationRepository, tokenRepo repository.RefreshTokenRepository, sessions *SessionService, tokens *auth.TokenManager, hasher user.PasswordHasher, lockout *LockoutService, mfa *MFAService, refreshTTL, mfaTTL time.Duration) *AuthService {
+	return &AuthService{
+		userRepo:   userRepo,
+		orgRepo:    orgRepo,
+		tokenRepo:  tokenRepo,
+		sessions:   sessions,
+		tokens:     tokens,
+		hasher:     hasher,
+		lockout:    lockout,
+		mfa:        mfa,
+		refreshTTL: refreshTTL,
+		mfaTTL:     mfaTTL,
+	}
+}
+
+// Login verifies the credentials of a user and issues a new token pair for a
+// new session on the named device. Usernames are looked up in the organization
+// with the given slug, or in the default organization if it is empty.
+// Password hashes made with a legacy algorithm or outdated parameters are
+// replaced by a hash from the current hasher. Passwords are not checked while
+// the user or the client IP is locked after too many failed attempts. Users
+// with TOTP enabled get an MFA token to pass to VerifyMFA instead of tokens.
+func (s *AuthService) Login(ctx context.Context, organization, login, password, device string) (*TokenPair, error) {
+	if err := s.lockout.checkClient(ctx); err != nil {
+		return nil, err
+	}
+
+	var organizationID int64
+	if organization != "" {
+		org, err := s.orgRepo.GetBySlug(ctx, organization)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, s.loginFailed(ctx, 0, ErrInvalidCredentials)
+			}
+			return nil, err
+		}
+		organizationID = org.ID
+		ctx = tenant.NewContext(ctx, org.ID)
+	}
+
+	var (
+		u   *user.User
+		err error
+	)
+
+	// Accept either a username or an email address. Email addresses are
+	// looked up in every organization, so check the one given, if any.
+	if strings.Contains(login, "@") {
+		u, err = s.userRepo.GetByEmail(ctx, login)
+		if err == nil && organizationID != 0 && u.OrganizationID != organizationID {
+			err = repository.ErrNotFound
+		}
+	} else {
+		u, err = s.userRepo.GetByUsername(ctx, login)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, s.loginFailed(ctx, 0, ErrInvalidCredentials)
+		}
+		return nil, err
+	}
+
+	if err := s.lockout.checkUser(ctx, u.ID); err != nil {
+		return nil, err
+	}
+
+	if !u.CheckPassword(s.hasher, password) {
+		return nil, s.loginFailed(ctx, u.ID, ErrInvalidCredentials)
+	}
+
+	// Only reveal the status of the account to those who know its password
+	if err := checkStatus(u); err != nil {
+		return nil, err
+	}
+
+	if s.hasher.NeedsRehash(u.PasswordHash) {
+		// The old hash still works, so a failed rehash must not fail the login
+		if err := s.rehashPassword(ctx, u, password); err != nil {
+			log.Printf("failed to rehash password of user %d: %v", u.ID, err)
+		}
+	}
+
+	// Failed attempts are kept until the second factor is verified too, so
+	// that knowing the password does not allow guessing codes forever
+	if u.IsTOTPEnabled() {
+		mfaToken, err := s.tokens.IssueMFAToken(u, s.mfaTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	if err := s.lockout.recordSuccess(ctx, u.ID); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, u, device)
+}
+
+// VerifyMFA completes the login of a user with a second factor, exchanging
+// the MFA token returned by Login and a TOTP or recovery code for a token
+// pair of a new session on the named device. Wrong codes count as failed
+// login attempts.
+func (s *AuthService) VerifyMFA(ctx context.Context, mfaToken, code, device string) (*TokenPair, error) {
+	if err := s.lockout.checkClient(ctx); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.tokens.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	// TOTP was disabled since the password was checked
+	if !u.IsTOTPEnabled() {
+		return nil, ErrInvalidMFAToken
+	}
+
+	if err := s.lockout.checkUser(ctx, u.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.verifyCode(ctx, u, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			return nil, s.loginFailed(ctx, u.ID, err)
+		}
+		return nil, err
+	}
+
+	if err := s.lockout.recordSuccess(ctx, u.ID); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, u, device)
+}
+
+// loginAs completes the login of a user authenticated by other means than
+// their password, such as an external identity provider. Users with TOTP
+// enabled still get an MFA token to pass to VerifyMFA.
+func (s *AuthService) loginAs(ctx context.Context, u *user.User, device string) (*TokenPair, error) {
+	if u.IsTOTPEnabled() {
+		mfaToken, err := s.tokens.IssueMFAToken(u, s.mfaTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	return s.issueTokens(ctx, u, device)
+}
+
+// loginFailed records a failed login attempt for the user, or only for the
+// client IP when userID is 0, and returns loginErr
+func (s *AuthService) loginFailed(ctx context.Context, userID int64, loginErr error) error {
+	if err := s.lockout.recordFailure(ctx, userID); err != nil {
+		return err
+	}
+	return loginErr
+}
+
+// rehashPassword stores a new hash of the verified password of the user
+func (s *AuthService) rehashPassword(ctx context.Context, u *user.User, password string) error {
+	newHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	// Fails if the password was changed since it was verified
+	if err := s.userRepo.RehashPassword(ctx, u.ID, u.PasswordHash, newHash); err != nil {
+		return err
+	}
+
+	u.PasswordHash = newHash
+	return nil
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair of the
+// same session, which lasts until the new refresh token expires. The presented
+// token is rotated: it is revoked and replaced by the new one. Presenting a
+// token that was already rotated revokes all sessions of the user.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, err := s.tokenRepo.GetByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.IsRevoked() {
+		// A revoked token being replayed means it has likely leaked
+		if err := s.sessions.endAll(ctx, stored.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.IsExpired() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	u, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if err := checkStatus(u); err != nil {
+		return nil, err
+	}
+
+	// Admins who were logged in before a second factor was required need one too
+	if err := s.mfa.checkRequired(ctx, u); err != nil {
+		return nil, err
+	}
+
+	session, err := s.refreshSession(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, newToken, err := s.createTokens(ctx, u, session)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenRepo.Revoke(ctx, stored.ID, &newToken.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Another request rotated the token concurrently
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	// Fails if the session was revoked concurrently
+	if err := s.sessions.repo.Extend(ctx, session.ID, newToken.ExpiresAt); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// refreshSession returns the active session of a refresh token. Tokens issued
+// before sessions existed get a new session.
+func (s *AuthService) refreshSession(ctx context.Context, stored *user.RefreshToken) (*user.Session, error) {
+	if stored.SessionID == nil {
+		return s.sessions.start(ctx, stored.UserID, "", s.refreshTTL)
+	}
+
+	session, err := s.sessions.repo.GetByID(ctx, *stored.SessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if !session.IsActive() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return session, nil
+}
+
+// Logout ends the session of the given refresh token, revoking the token
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.tokenRepo.GetByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	if stored.IsRevoked() {
+		return nil
+	}
+
+	if stored.SessionID != nil {
+		err = s.sessions.end(ctx, stored.UserID, *stored.SessionID)
+	} else {
+		err = s.tokenRepo.Revoke(ctx, stored.ID, nil)
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// issueTokens starts a new session of the user on the device and creates its
+// token pair, unless the status of the user does not let them authenticate or
+// they are an admin without a required second factor
+func (s *AuthService) issueTokens(ctx context.Context, u *user.User, device string) (*TokenPair, error) {
+	if err := checkStatus(u); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.checkRequired(ctx, u); err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessions.start(ctx, u.ID, device, s.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, _, err := s.createTokens(ctx, u, session)
+	return pair, err
+}
+
+// createTokens signs an access token and persists a new refresh token for
+// the session
+func (s *AuthService) createTokens(ctx context.Context, u *user.User, session *user.Session) (*TokenPair, *user.RefreshToken, error) {
+	accessToken, err := s.tokens.IssueAccessToken(u, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := user.NewRefreshToken(u.ID, session.ID, refreshHash, s.refreshTTL)
+	if err := s.tokenRepo.Create(ctx, stored); err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.tokens.AccessTokenTTL(),
+		User:         u,
+	}, stored, nil
+}
+
+// checkStatus returns the error telling a user that their account status
+// does not let them authenticate, if so
+func checkStatus(u *user.User) error {
+	switch u.Status {
+	case user.StatusSuspended:
+		return ErrAccountSuspended
+	case user.StatusDeactivated:
+		return ErrAccountDeactivated
+	}
+	return nil
+}
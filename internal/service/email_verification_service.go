@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/mailer"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// Email verification errors
+var (
+	ErrInvalidVerificationToken = InvalidArgumentError("INVALID_VERIFICATION_TOKEN", "token", "invalid or expired verification token", nil)
+	ErrEmailAlreadyVerified     = FailedPreconditionError("EMAIL_ALREADY_VERIFIED", "email address is already verified")
+)
+
+// EmailVerificationService is responsible for proving that users control
+// their email addresses
+type EmailVerificationService struct {
+	userRepo  repository.UserRepository
+	uow       repository.UnitOfWork
+	mailer    mailer.Mailer
+	ttl       time.Duration
+	verifyURL string
+	authz     *Authorizer
+}
+
+// NewEmailVerificationService creates a new email verification service.
+// Verification emails link to verifyURL with the token as a query parameter,
+// or contain only the token when verifyURL is empty.
+func NewEmailVerificationService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, uow repository.UnitOfWork, m mailer.Mailer, ttl time.Duration, verifyURL string) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:  userRepo,
+		uow:       uow,
+		mailer:    m,
+		ttl:       ttl,
+		verifyURL: verifyURL,
+		authz:     NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// VerifyEmail consumes a verification token and marks the email address it
+// was issued for as verified
+func (s *EmailVerificationService) VerifyEmail(ctx context.Context, token string) (*user.User, error) {
+	var verifiedUser *user.User
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		stored, err := repos.EmailVerifications.GetByHash(ctx, auth.HashToken(token))
+		if err != nil {
+			return invalidVerificationToken(err)
+		}
+
+		if stored.IsUsed() || stored.IsExpired() {
+			return ErrInvalidVerificationToken
+		}
+
+		// Fails if another request used the token concurrently
+		if err := repos.EmailVerifications.MarkUsed(ctx, stored.ID); err != nil {
+			return invalidVerificationToken(err)
+		}
+
+		// Fails if the user changed their email since the token was issued
+		if err := repos.Users.MarkEmailVerified(ctx, stored.UserID, stored.Email, time.Now().UTC()); err != nil {
+			return invalidVerificationToken(err)
+		}
+
+		verifiedUser, err = repos.Users.GetByID(ctx, stored.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return verifiedUser, nil
+}
+
+// ResendVerification issues a new verification token for the current email
+// of a user, invalidating the previous ones, and sends it
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, userID); err != nil {
+		return err
+	}
+
+	var (
+		u     *user.User
+		token string
+	)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		u, err = repos.Users.GetByID(ctx, userID)
+		if err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+		}
+
+		if u.IsEmailVerified() {
+			return ErrEmailAlreadyVerified
+		}
+
+		token, err = s.issueToken(ctx, repos.EmailVerifications, u)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sendToken(ctx, u, token)
+}
+
+// issueToken invalidates the outstanding verification tokens of a user and
+// creates a new one for their current email. It runs in the unit of work
+// that created the user or changed their email.
+func (s *EmailVerificationService) issueToken(ctx context.Context, tokens repository.EmailVerificationRepository, u *user.User) (string, error) {
+	if err := tokens.InvalidateAllForUser(ctx, u.ID); err != nil {
+		return "", err
+	}
+
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := tokens.Create(ctx, user.NewEmailVerificationToken(u.ID, u.Email, tokenHash, s.ttl)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// sendToken emails a verification token to the user
+func (s *EmailVerificationService) sendToken(ctx context.Context, u *user.User, token string) error {
+	instructions := fmt.Sprintf("Use this token to verify your email address:\n\n%s", token)
+	if s.verifyURL != "" {
+		link, err := tokenLink(s.verifyURL, token)
+		if err != nil {
+			return err
+		}
+		instructions = fmt.Sprintf("Open this link to verify your email address:\n\n%s", link)
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\n%s\n\nIt expires in %s. If you did not expect this email, you can ignore it.",
+			u.FullName, instructions, s.ttl,
+		),
+	})
+}
+
+// notify sends a verification token issued by another operation. A failed
+// delivery does not fail that operation since the user can ask for the token
+// to be sent again.
+func (s *EmailVerificationService) notify(ctx context.Context, u *user.User, token string) {
+	if err := s.sendToken(ctx, u, token); err != nil {
+		log.Printf("failed to send verification email to user %d: %v", u.ID, err)
+	}
+}
+
+// invalidVerificationToken converts repository.ErrNotFound into ErrInvalidVerificationToken
+func invalidVerificationToken(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidVerificationToken
+	}
+	return err
+}
+
+// tokenLink returns the page URL with the token added as a query parameter
+func tokenLink(pageURL, token string) (string, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// ErrorKind classifies service errors so transports can report them consistently
+type ErrorKind int
+
+// Error kinds
+const (
+	KindInternal ErrorKind = iota
+	KindInvalidArgument
+	KindNotFound
+	KindAlreadyExists
+	KindPermissionDenied
+	KindUnauthenticated
+	KindFailedPrecondition
+	KindAborted
+	KindResourceExhausted
+)
+
+// Error is an error returned by the services, carrying what went wrong in a
+// form clients can act on
+type Error struct {
+	Kind ErrorKind
+	// Reason is a stable UPPER_SNAKE_CASE identifier of the error
+	Reason string
+	// Field is the request field at fault, if any
+	Field   string
+	Message string
+	// Violations lists the individual problems when there are several
+	Violations []FieldViolation
+	// RetryAfter is how long clients should wait before retrying, if known
+	RetryAfter time.Duration
+	// Err is the underlying error, if any
+	Err error
+}
+
+// FieldViolation is one of several problems found with a request field
+type FieldViolation struct {
+	Field string
+	// Reason is a stable UPPER_SNAKE_CASE identifier of the problem
+	Reason      string
+	Description string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// InvalidArgumentError returns an error for a request field with an invalid value
+func InvalidArgumentError(reason, field, message string, err error) *Error {
+	return &Error{Kind: KindInvalidArgument, Reason: reason, Field: field, Message: message, Err: err}
+}
+
+// NotFoundError returns an error for a missing resource
+func NotFoundError(reason, message string, err error) *Error {
+	return &Error{Kind: KindNotFound, Reason: reason, Message: message, Err: err}
+}
+
+// AlreadyExistsError returns an error for a request field conflicting with an existing resource
+func AlreadyExistsError(reason, field, message string, err error) *Error {
+	return &Error{Kind: KindAlreadyExists, Reason: reason, Field: field, Message: message, Err: err}
+}
+
+// FailedPreconditionError returns an error for an operation not allowed in the current state
+func FailedPreconditionError(reason, message string) *Error {
+	return &Error{Kind: KindFailedPrecondition, Reason: reason, Message: message}
+}
+
+// Errors shared by the user operations
+var (
+	errUsernameTaken = AlreadyExistsError("USERNAME_TAKEN", "username", "username already taken", nil)
+	errEmailTaken    = AlreadyExistsError("EMAIL_TAKEN", "email", "email already registered", nil)
+)
+
+// translateRepositoryError converts the duplicate, version conflict and
+// unsupported sort field errors of the repositories into service errors.
+// Duplicates are normally caught by the lookups before a write; this covers
+// concurrent requests racing past them.
+func translateRepositoryError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateUsername):
+		return errUsernameTaken
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		return errEmailTaken
+	case errors.Is(err, repository.ErrVersionConflict):
+		return &Error{Kind: KindAborted, Reason: "VERSION_CONFLICT", Message: err.Error(), Err: err}
+	case errors.Is(err, repository.ErrUnsupportedSortField):
+		return InvalidArgumentError("UNSUPPORTED_SORT_FIELD", "sort_by", err.Error(), err)
+	}
+	return err
+}
+
+// userNotFound converts repository.ErrNotFound into a NotFound error for the user
+func userNotFound(err error, message string) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFoundError("USER_NOT_FOUND", message, err)
+	}
+	return err
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// Group errors
+var (
+	ErrGroupNameTaken = AlreadyExistsError("GROUP_NAME_TAKEN", "name", "group name already taken", nil)
+	// ErrGroupCycle is returned when nesting a group in itself or in one of
+	// the groups nested in it
+	ErrGroupCycle = FailedPreconditionError("GROUP_CYCLE", "a group cannot contain itself, directly or through nested groups")
+	// ErrGroupOrganizationMismatch is returned when adding a member of another
+	// organization to a group
+	ErrGroupOrganizationMismatch = FailedPreconditionError("GROUP_ORGANIZATION_MISMATCH", "groups can only contain users and groups of their organization")
+)
+
+// GroupService is responsible for groups of users. Groups can contain other
+// groups, making the members of the nested groups members of the containing
+// group too. Roles granted to a group apply to all of its members.
+type GroupService struct {
+	userRepo repository.UserRepository
+	roleRepo repository.RoleRepository
+	repo     repository.GroupRepository
+	uow      repository.UnitOfWork
+	authz    *Authorizer
+}
+
+// NewGroupService creates a new group service
+func NewGroupService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.GroupRepository, uow repository.UnitOfWork) *GroupService {
+	return &GroupService{
+		userRepo: userRepo,
+		roleRepo: roleRepo,
+		repo:     repo,
+		uow:      uow,
+		authz:    NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// CreateGroup creates a new group in the organization of the caller
+func (s *GroupService) CreateGroup(ctx context.Context, name, description string) (*user.Group, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return nil, err
+	}
+
+	group := user.NewGroup(name, description)
+	if err := s.repo.Create(ctx, group); err != nil {
+		return nil, translateGroupError(err)
+	}
+
+	return group, nil
+}
+
+// GetGroup retrieves a group by ID
+func (s *GroupService) GetGroup(ctx context.Context, id int64) (*user.Group, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionReadGroups, 0); err != nil {
+		return nil, err
+	}
+
+	group, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, groupNotFound(err, id)
+	}
+
+	return group, nil
+}
+
+// ListGroups returns every group of the organization of the caller
+func (s *GroupService) ListGroups(ctx context.Context) ([]*user.Group, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionReadGroups, 0); err != nil {
+		return nil, err
+	}
+
+	return s.repo.List(ctx)
+}
+
+// UpdateGroup changes the name and description of a group. Nil values are
+// left unchanged.
+func (s *GroupService) UpdateGroup(ctx context.Context, id int64, name, description *string) (*user.Group, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return nil, err
+	}
+
+	group, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, groupNotFound(err, id)
+	}
+
+	if name != nil {
+		group.Name = *name
+	}
+	if description != nil {
+		group.Description = *description
+	}
+
+	if err := s.repo.Update(ctx, group); err != nil {
+		return nil, groupNotFound(translateGroupError(err), id)
+	}
+
+	return group, nil
+}
+
+// DeleteGroup deletes a group. Its members and nested groups are kept, but
+// are no longer members of the groups it was nested in.
+func (s *GroupService) DeleteGroup(ctx context.Context, id int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return groupNotFound(err, id)
+	}
+
+	return nil
+}
+
+// AddUser adds a user of the organization of the group to the group
+func (s *GroupService) AddUser(ctx context.Context, groupID, userID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return err
+	}
+
+	group, err := s.repo.GetByID(ctx, groupID)
+	if err != nil {
+		return groupNotFound(err, groupID)
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	if u.OrganizationID != group.OrganizationID {
+		return ErrGroupOrganizationMismatch
+	}
+
+	return s.repo.AddUser(ctx, groupID, userID, actorID(ctx))
+}
+
+// RemoveUser removes a user from a group they were directly added to
+func (s *GroupService) RemoveUser(ctx context.Context, groupID, userID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.GetByID(ctx, groupID); err != nil {
+		return groupNotFound(err, groupID)
+	}
+
+	if err := s.repo.RemoveUser(ctx, groupID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFoundError("GROUP_MEMBER_NOT_FOUND", fmt.Sprintf("user %d is not a member of group %d", userID, groupID), err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// AddSubgroup nests a group in another group of the same organization. It
+// fails with ErrGroupCycle if the parent group is the group itself or one of
+// the groups nested in it at any depth.
+func (s *GroupService) AddSubgroup(ctx context.Context, parentID, childID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return err
+	}
+
+	parent, err := s.repo.GetByID(ctx, parentID)
+	if err != nil {
+		return groupNotFound(err, parentID)
+	}
+
+	child, err := s.repo.GetByID(ctx, childID)
+	if err != nil {
+		return groupNotFound(err, childID)
+	}
+
+	if child.OrganizationID != parent.OrganizationID {
+		return ErrGroupOrganizationMismatch
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		// Two concurrent nestings could each close a cycle the other cannot see
+		if err := repos.Groups.LockNesting(ctx, parent.OrganizationID); err != nil {
+			return err
+		}
+
+		cycle, err := repos.Groups.Contains(ctx, childID, parentID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrGroupCycle
+		}
+
+		return repos.Groups.AddSubgroup(ctx, parentID, childID, actorID(ctx))
+	})
+}
+
+// RemoveSubgroup removes a group directly nested in another
+func (s *GroupService) RemoveSubgroup(ctx context.Context, parentID, childID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageGroups, 0); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.GetByID(ctx, parentID); err != nil {
+		return groupNotFound(err, parentID)
+	}
+
+	if err := s.repo.RemoveSubgroup(ctx, parentID, childID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFoundError("GROUP_MEMBER_NOT_FOUND", fmt.Sprintf("group %d is not nested in group %d", childID, parentID), err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ListMembers returns the users and the groups directly in a group
+func (s *GroupService) ListMembers(ctx context.Context, groupID int64) ([]*user.User, []*user.Group, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionReadGroups, 0); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := s.repo.GetByID(ctx, groupID); err != nil {
+		return nil, nil, groupNotFound(err, groupID)
+	}
+
+	users, err := s.repo.ListUsers(ctx, groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subgroups, err := s.repo.ListSubgroups(ctx, groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, subgroups, nil
+}
+
+// ListUserGroups returns the groups a user is a member of, including the
+// groups containing those groups at any depth unless directOnly is set
+func (s *GroupService) ListUserGroups(ctx context.Context, userID int64, directOnly bool) ([]*user.Group, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionReadUsers, userID); err != nil {
+		return nil, err
+	}
+
+	// Make sure the user exists
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	if directOnly {
+		return s.repo.ListByUser(ctx, userID)
+	}
+	return s.repo.ListEffectiveByUser(ctx, userID)
+}
+
+// GrantRole grants a role to a group, and so to every user in it, and returns
+// the resulting roles of the group
+func (s *GroupService) GrantRole(ctx context.Context, groupID int64, roleName string) ([]user.Role, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageRoles, 0); err != nil {
+		return nil, err
+	}
+
+	role, err := user.ParseRole(roleName)
+	if err != nil {
+		return nil, InvalidArgumentError("UNKNOWN_ROLE", "role", err.Error(), err)
+	}
+
+	if _, err := s.repo.GetByID(ctx, groupID); err != nil {
+		return nil, groupNotFound(err, groupID)
+	}
+
+	if err := s.roleRepo.GrantToGroup(ctx, groupID, role, actorID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.ListByGroup(ctx, groupID)
+}
+
+// RevokeRole revokes a role from a group and returns the remaining roles of
+// the group. Users keep the role if it was also granted to them otherwise,
+// and admins cannot revoke the admin role they only hold through the group.
+func (s *GroupService) RevokeRole(ctx context.Context, groupID int64, roleName string) ([]user.Role, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageRoles, 0); err != nil {
+		return nil, err
+	}
+
+	role, err := user.ParseRole(roleName)
+	if err != nil {
+		return nil, InvalidArgumentError("UNKNOWN_ROLE", "role", err.Error(), err)
+	}
+
+	if _, err := s.repo.GetByID(ctx, groupID); err != nil {
+		return nil, groupNotFound(err, groupID)
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Roles.RevokeFromGroup(ctx, groupID, role); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return NotFoundError("ROLE_NOT_GRANTED", fmt.Sprintf("group %d does not have role %q", groupID, roleName), err)
+			}
+			return err
+		}
+
+		// Prevent admins from locking themselves out through their groups
+		actor := actorID(ctx)
+		if actor == nil || role != user.RoleAdmin {
+			return nil
+		}
+		roles, err := repos.Roles.ListEffectiveByUser(ctx, *actor)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(roles, user.RoleAdmin) {
+			return ErrRevokeOwnAdmin
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.ListByGroup(ctx, groupID)
+}
+
+// groupNotFound converts repository.ErrNotFound into a NotFound error for the group
+func groupNotFound(err error, id int64) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFoundError("GROUP_NOT_FOUND", fmt.Sprintf("group not found with ID %d", id), err)
+	}
+	return err
+}
+
+// translateGroupError converts repository errors about groups into service errors
+func translateGroupError(err error) error {
+	if errors.Is(err, repository.ErrDuplicateGroupName) {
+		return ErrGroupNameTaken
+	}
+	return err
+}
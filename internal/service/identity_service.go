@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// External identity errors
+var (
+	ErrInvalidLoginState = &Error{Kind: KindUnauthenticated, Reason: "INVALID_LOGIN_STATE", Message: "invalid or expired login state"}
+	// ErrIdentityEmailRequired is returned when a provider does not share the
+	// email address of an account that is not linked to a user yet
+	ErrIdentityEmailRequired = FailedPreconditionError("IDENTITY_EMAIL_REQUIRED", "the identity provider did not share an email address")
+	// ErrIdentityEmailInUse is returned when the email address of a new
+	// identity belongs to a user it cannot safely be linked to
+	ErrIdentityEmailInUse = AlreadyExistsError("IDENTITY_EMAIL_IN_USE", "email", "the email address of the identity is used by another account; sign in to that account with its password instead", nil)
+	ErrLastSignInMethod   = FailedPreconditionError("LAST_SIGN_IN_METHOD", "the user has no password and no other identity to sign in with")
+)
+
+// maxUsernameAttempts bounds the search for a free username for new users
+const maxUsernameAttempts = 100
+
+// ExternalIdentity is an account authenticated by an external OpenID Connect
+// provider, as described by the claims of its ID token
+type ExternalIdentity struct {
+	// Provider is the name of the configured provider
+	Provider          string
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// IdentityService is responsible for signing users in with external OpenID
+// Connect providers and for the identities linked to their users
+type IdentityService struct {
+	userRepo  repository.UserRepository
+	repo      repository.LinkedIdentityRepository
+	stateRepo repository.ExternalLoginStateRepository
+	uow       repository.UnitOfWork
+	auth      *AuthService
+	stateTTL  time.Duration
+	authz     *Authorizer
+}
+
+// NewIdentityService creates a new identity service completing logins through
+// authService. Users have stateTTL to log in at the provider.
+func NewIdentityService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.LinkedIdentityRepository, stateRepo repository.ExternalLoginStateRepository, uow repository.UnitOfWork, authService *AuthService, stateTTL time.Duration) *IdentityService {
+	return &IdentityService{
+		userRepo:  userRepo,
+		repo:      repo,
+		stateRepo: stateRepo,
+		uow:       uow,
+		auth:      authService,
+		stateTTL:  stateTTL,
+		authz:     NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// StateTTL returns how long users have to log in at the provider
+func (s *IdentityService) StateTTL() time.Duration {
+	return s.stateTTL
+}
+
+// BeginLogin starts a login at a provider for a session on the named device.
+// It returns the state parameter to send to the provider, along with the
+// stored login state holding the nonce and PKCE verifier of the request.
+func (s *IdentityService) BeginLogin(ctx context.Context, provider, device string) (*user.ExternalLoginState, string, error) {
+	// Logins abandoned at the provider are never consumed
+	if err := s.stateRepo.DeleteExpired(ctx, time.Now().UTC()); err != nil {
+		return nil, "", err
+	}
+
+	state, stateHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	nonce, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	verifier, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	loginState := &user.ExternalLoginState{
+		StateHash:    stateHash,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Device:       device,
+		ExpiresAt:    now.Add(s.stateTTL),
+		CreatedAt:    now,
+	}
+	if err := s.stateRepo.Create(ctx, loginState); err != nil {
+		return nil, "", err
+	}
+
+	return loginState, state, nil
+}
+
+// CompleteLogin returns the login state of the state parameter the provider
+// redirected back with. Each state can only be completed once.
+func (s *IdentityService) CompleteLogin(ctx context.Context, provider, state string) (*user.ExternalLoginState, error) {
+	loginState, err := s.stateRepo.Consume(ctx, auth.HashToken(state))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidLoginState
+		}
+		return nil, err
+	}
+
+	if loginState.Provider != provider || loginState.IsExpired() {
+		return nil, ErrInvalidLoginState
+	}
+
+	return loginState, nil
+}
+
+// SignIn logs in the user of an identity authenticated by a provider,
+// starting a session on the named device. The first login with an identity
+// links it to the user with the same email address when both the provider
+// and the user verified it, or creates a new user without a password.
+// Users with TOTP enabled get an MFA token to pass to VerifyMFA.
+func (s *IdentityService) SignIn(ctx context.Context, identity *ExternalIdentity, device string) (*TokenPair, error) {
+	var u *user.User
+
+	linked, err := s.repo.GetByIssuerSubject(ctx, identity.Issuer, identity.Subject)
+	switch {
+	case err == nil:
+		u, err = s.userRepo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrInvalidCredentials
+			}
+			return nil, err
+		}
+
+		if err := s.repo.Touch(ctx, linked.ID, identity.Email, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		u, err = s.link(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	return s.auth.loginAs(ctx, u, device)
+}
+
+// link links a new identity to the user with its verified email address, or
+// to a new user
+func (s *IdentityService) link(ctx context.Context, identity *ExternalIdentity) (*user.User, error) {
+	if identity.Email == "" {
+		return nil, ErrIdentityEmailRequired
+	}
+
+	var linkedUser *user.User
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		username, err := availableUsername(ctx, repos.Users, identity)
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Users.LockUniqueFields(ctx, username, identity.Email); err != nil {
+			return err
+		}
+
+		existing, err := repos.Users.GetByEmail(ctx, identity.Email)
+		switch {
+		case err == nil:
+			// Linking on an unverified address on either side would hand the
+			// account to whoever registered the address first
+			if !identity.EmailVerified || !existing.IsEmailVerified() {
+				return ErrIdentityEmailInUse
+			}
+			linkedUser = existing
+		case errors.Is(err, repository.ErrNotFound):
+			linkedUser, err = createExternalUser(ctx, repos, username, identity)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return repos.LinkedIdentities.Create(ctx, user.NewLinkedIdentity(linkedUser.ID, identity.Provider, identity.Issuer, identity.Subject, identity.Email))
+	})
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	return linkedUser, nil
+}
+
+// createExternalUser creates the user of an identity, with the email address
+// verified if the provider verified it
+func createExternalUser(ctx context.Context, repos repository.Repositories, username string, identity *ExternalIdentity) (*user.User, error) {
+	fullName := identity.Name
+	if fullName == "" {
+		fullName = username
+	}
+
+	newUser := user.NewExternalUser(username, identity.Email, fullName)
+	if err := repos.Users.Create(ctx, newUser); err != nil {
+		return nil, err
+	}
+
+	// Every user can manage their own record
+	if err := repos.Roles.Grant(ctx, newUser.ID, user.RoleSelf, nil); err != nil {
+		return nil, err
+	}
+
+	if err := repos.Organizations.AddMember(ctx, user.NewMembership(newUser.OrganizationID, newUser.ID, user.OrganizationRoleMember)); err != nil {
+		return nil, err
+	}
+
+	if identity.EmailVerified {
+		verifiedAt := time.Now().UTC()
+		if err := repos.Users.MarkEmailVerified(ctx, newUser.ID, newUser.Email, verifiedAt); err != nil {
+			return nil, err
+		}
+		newUser.EmailVerifiedAt = &verifiedAt
+		newUser.Status = newUser.ActiveStatus()
+		newUser.Version++
+	}
+
+	return newUser, nil
+}
+
+// availableUsername derives a free username for the user of an identity
+// from its preferred username or email address
+func availableUsername(ctx context.Context, users repository.UserRepository, identity *ExternalIdentity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = sanitizeUsername(base)
+
+	for i := 1; i <= maxUsernameAttempts; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s_%d", base, i)
+		}
+
+		if _, err := users.GetByUsername(ctx, candidate); errors.Is(err, repository.ErrNotFound) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+
+	return "", errUsernameTaken
+}
+
+// sanitizeUsername keeps the characters allowed in usernames, leaving room
+// for a numeric suffix
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		case r == '.' || r == '-':
+			b.WriteRune('_')
+		}
+		if b.Len() == 40 {
+			break
+		}
+	}
+
+	if b.Len() < 3 {
+		return "user"
+	}
+	return b.String()
+}
+
+// ListIdentities returns the identities linked to a user
+func (s *IdentityService) ListIdentities(ctx context.Context, userID int64) ([]*user.LinkedIdentity, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionReadUsers, userID); err != nil {
+		return nil, err
+	}
+
+	// Make sure the user exists
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// UnlinkIdentity removes an identity from a user, who can no longer sign in
+// with it. Users without a password must keep at least one identity.
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userID, identityID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, userID); err != nil {
+		return err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	if !u.HasPassword() {
+		identities, err := s.repo.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(identities) == 1 && identities[0].ID == identityID {
+			return ErrLastSignInMethod
+		}
+	}
+
+	if err := s.repo.Delete(ctx, identityID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFoundError("IDENTITY_NOT_FOUND", fmt.Sprintf("identity not found with ID %d", identityID), err)
+		}
+		return err
+	}
+
+	return nil
+}
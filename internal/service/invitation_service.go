@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/mailer"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/tenant"
+)
+
+// Invitation errors
+var (
+	// ErrInvalidInvitation is returned when an invitation token is unknown,
+	// accepted, revoked or expired
+	ErrInvalidInvitation = InvalidArgumentError("INVALID_INVITATION", "token", "invalid or expired invitation", nil)
+	// ErrInvitationAccepted is returned when revoking an accepted invitation
+	ErrInvitationAccepted = FailedPreconditionError("INVITATION_ACCEPTED", "invitation was already accepted")
+)
+
+// InvitationService is responsible for inviting people to create their
+// account in an organization. Invitations are emailed, so that admins never
+// have to choose and share the password of the account.
+type InvitationService struct {
+	userRepo    repository.UserRepository
+	repo        repository.InvitationRepository
+	uow         repository.UnitOfWork
+	userService *UserService
+	mailer      mailer.Mailer
+	ttl         time.Duration
+	acceptURL   string
+	authz       *Authorizer
+}
+
+// NewInvitationService creates a new invitation service creating accounts
+// with userService. Invitation emails link to acceptURL with the token as a
+// query parameter, or contain only the token when acceptURL is empty.
+func NewInvitationService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.InvitationRepository, uow repository.UnitOfWork, userService *UserService, m mailer.Mailer, ttl time.Duration, acceptURL string) *InvitationService {
+	return &InvitationService{
+		userRepo:    userRepo,
+		repo:        repo,
+		uow:         uow,
+		userService: userService,
+		mailer:      m,
+		ttl:         ttl,
+		acceptURL:   acceptURL,
+		authz:       NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// CreateInvitation invites an email to the organization of the caller and
+// emails them the invitation. The account created from the invitation is
+// granted roleNames, which requires the caller to manage roles. A new
+// invitation replaces the pending invitations of the email.
+func (s *InvitationService) CreateInvitation(ctx context.Context, email string, roleNames []string) (*user.Invitation, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionInviteUsers, 0); err != nil {
+		return nil, err
+	}
+
+	roles, err := parseInvitationRoles(roleNames)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) > 0 {
+		if err := s.authz.Authorize(ctx, user.PermissionManageRoles, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, errEmailTaken
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers without an organization invite to the default one
+	organizationID, _ := tenant.FromContext(ctx)
+	invitation := user.NewInvitation(organizationID, email, roles, tokenHash, s.ttl, actorID(ctx))
+
+	var org *user.Organization
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Invitations.Create(ctx, invitation); err != nil {
+			return err
+		}
+
+		if err := repos.Invitations.RevokeOthers(ctx, invitation); err != nil {
+			return err
+		}
+
+		org, err = repos.Organizations.GetByID(ctx, invitation.OrganizationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Admins can send a new invitation if this one does not arrive
+	if err := s.sendToken(ctx, invitation, org, token); err != nil {
+		log.Printf("failed to send invitation %d: %v", invitation.ID, err)
+	}
+
+	return invitation, nil
+}
+
+// ListInvitations returns the invitations of the organization of the caller,
+// newest first. With pendingOnly, only the invitations that can still be
+// accepted are returned.
+func (s *InvitationService) ListInvitations(ctx context.Context, pendingOnly bool) ([]*user.Invitation, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionInviteUsers, 0); err != nil {
+		return nil, err
+	}
+
+	return s.repo.List(ctx, pendingOnly)
+}
+
+// RevokeInvitation revokes an invitation that was not accepted yet
+func (s *InvitationService) RevokeInvitation(ctx context.Context, id int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionInviteUsers, 0); err != nil {
+		return err
+	}
+
+	invitation, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return invitationNotFound(err, id)
+	}
+
+	if invitation.AcceptedAt != nil {
+		return ErrInvitationAccepted
+	}
+
+	// Fails if the invitation was accepted or revoked in the meantime
+	if err := s.repo.Revoke(ctx, id); err != nil {
+		return invitationNotFound(err, id)
+	}
+
+	return nil
+}
+
+// AcceptInvitation creates the account of an invited person in the
+// organization of the invitation, with the email address it was sent to and
+// the password they chose, and grants it the roles of the invitation. The
+// address is verified, as the invitation was sent to it. Each invitation can
+// only be accepted once.
+func (s *InvitationService) AcceptInvitation(ctx context.Context, token, username, password, fullName string) (*user.User, error) {
+	invitation, err := s.repo.GetByHash(ctx, auth.HashToken(token))
+	if err != nil {
+		return nil, invalidInvitation(err)
+	}
+
+	if !invitation.IsPending() {
+		return nil, ErrInvalidInvitation
+	}
+
+	newUser, err := s.userService.newUser(username, invitation.Email, password, fullName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is kept unless every step succeeds, so that the invitee can
+	// try again, with another username for instance
+	ctx = tenant.NewContext(ctx, invitation.OrganizationID)
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		// Fails if the invitation was accepted or revoked in the meantime
+		if err := repos.Invitations.MarkAccepted(ctx, invitation.ID); err != nil {
+			return invalidInvitation(err)
+		}
+
+		if err := s.userService.insertUser(ctx, repos, newUser); err != nil {
+			return err
+		}
+
+		verifiedAt := time.Now().UTC()
+		if err := repos.Users.MarkEmailVerified(ctx, newUser.ID, newUser.Email, verifiedAt); err != nil {
+			return err
+		}
+		newUser.EmailVerifiedAt = &verifiedAt
+		newUser.Status = newUser.ActiveStatus()
+		newUser.Version++
+
+		for _, role := range invitation.Roles {
+			if err := repos.Roles.Grant(ctx, newUser.ID, role, invitation.InvitedBy); err != nil {
+				return err
+			}
+		}
+
+		return repos.Invitations.SetAcceptedUser(ctx, invitation.ID, newUser.ID)
+	})
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	return newUser, nil
+}
+
+// sendToken emails an invitation token to the invited email address
+func (s *InvitationService) sendToken(ctx context.Context, invitation *user.Invitation, org *user.Organization, token string) error {
+	instructions := fmt.Sprintf("Use this token to create your account:\n\n%s", token)
+	if s.acceptURL != "" {
+		link, err := tokenLink(s.acceptURL, token)
+		if err != nil {
+			return err
+		}
+		instructions = fmt.Sprintf("Open this link to create your account:\n\n%s", link)
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You are invited to join %s", org.Name),
+		Body: fmt.Sprintf(
+			"Hi,\n\nYou are invited to join %s. %s\n\nThe invitation expires in %s. If you did not expect this email, you can ignore it.",
+			org.Name, instructions, s.ttl,
+		),
+	})
+}
+
+// parseInvitationRoles converts the role names of an invitation into roles,
+// leaving out duplicates and RoleSelf, which every user is granted anyway
+func parseInvitationRoles(names []string) ([]user.Role, error) {
+	roles := make([]user.Role, 0, len(names))
+	seen := make(map[user.Role]bool, len(names))
+	for _, name := range names {
+		role, err := user.ParseRole(name)
+		if err != nil {
+			return nil, InvalidArgumentError("UNKNOWN_ROLE", "roles", err.Error(), err)
+		}
+
+		if role == user.RoleSelf || seen[role] {
+			continue
+		}
+		seen[role] = true
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// invitationNotFound converts repository.ErrNotFound into a NotFound error
+// for the invitation
+func invitationNotFound(err error, id int64) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFoundError("INVITATION_NOT_FOUND", fmt.Sprintf("invitation not found with ID %d", id), err)
+	}
+	return err
+}
+
+// invalidInvitation converts repository.ErrNotFound into ErrInvalidInvitation
+func invalidInvitation(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidInvitation
+	}
+	return err
+}
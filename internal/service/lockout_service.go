@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// Lockout errors. The errors returned while locked wrap them and tell how
+// long the client has to wait.
+var (
+	ErrAccountLocked        = &Error{Kind: KindResourceExhausted, Reason: "ACCOUNT_LOCKED", Message: "account is locked after too many failed login attempts"}
+	ErrTooManyLoginAttempts = &Error{Kind: KindResourceExhausted, Reason: "TOO_MANY_LOGIN_ATTEMPTS", Message: "too many failed login attempts from this address"}
+)
+
+// LockoutService is responsible for locking users and client IPs after
+// repeated failed logins
+type LockoutService struct {
+	userRepo repository.UserRepository
+	repo     repository.LoginThrottleRepository
+	policy   *user.LockoutPolicy
+	authz    *Authorizer
+}
+
+// NewLockoutService creates a new lockout service following the policy
+func NewLockoutService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.LoginThrottleRepository, policy *user.LockoutPolicy) *LockoutService {
+	return &LockoutService{
+		userRepo: userRepo,
+		repo:     repo,
+		policy:   policy,
+		authz:    NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// UnlockUser forgets the failed login attempts of a user, lifting their lock
+func (s *LockoutService) UnlockUser(ctx context.Context, id int64) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionUnlockUsers, id); err != nil {
+		return nil, err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+	}
+
+	if err := s.repo.Reset(ctx, user.ThrottleSubjectUser, userSubject(id)); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// checkClient returns ErrTooManyLoginAttempts while the client IP of the
+// request is locked
+func (s *LockoutService) checkClient(ctx context.Context) error {
+	ip := clientIP(ctx)
+	if ip == "" {
+		return nil
+	}
+	return s.check(ctx, user.ThrottleSubjectIP, ip, ErrTooManyLoginAttempts)
+}
+
+// checkUser returns ErrAccountLocked while the user is locked
+func (s *LockoutService) checkUser(ctx context.Context, userID int64) error {
+	return s.check(ctx, user.ThrottleSubjectUser, userSubject(userID), ErrAccountLocked)
+}
+
+// recordFailure counts a failed login attempt against the client IP of the
+// request and, unless it is 0 because no user matched, the user
+func (s *LockoutService) recordFailure(ctx context.Context, userID int64) error {
+	if ip := clientIP(ctx); ip != "" {
+		if err := s.recordSubjectFailure(ctx, user.ThrottleSubjectIP, ip); err != nil {
+			return err
+		}
+	}
+
+	if userID == 0 {
+		return nil
+	}
+	return s.recordSubjectFailure(ctx, user.ThrottleSubjectUser, userSubject(userID))
+}
+
+// recordSuccess forgets the failed login attempts of a user who logged in.
+// Those of the client IP are kept, so that logging into an account of their
+// own does not let a client go on guessing the passwords of others.
+func (s *LockoutService) recordSuccess(ctx context.Context, userID int64) error {
+	return s.repo.Reset(ctx, user.ThrottleSubjectUser, userSubject(userID))
+}
+
+// check returns a copy of lockedErr telling when to retry if the subject is locked
+func (s *LockoutService) check(ctx context.Context, subjectType, subject string, lockedErr *Error) error {
+	t, err := s.repo.Get(ctx, subjectType, subject)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if !t.IsLocked() {
+		return nil
+	}
+
+	return &Error{
+		Kind:       lockedErr.Kind,
+		Reason:     lockedErr.Reason,
+		Message:    lockedErr.Message,
+		RetryAfter: t.RetryAfter(),
+		Err:        lockedErr,
+	}
+}
+
+// recordSubjectFailure counts a failed login attempt of a subject and locks
+// it once it reaches the threshold of the policy
+func (s *LockoutService) recordSubjectFailure(ctx context.Context, subjectType, subject string) error {
+	threshold := s.policy.Threshold(subjectType)
+	if threshold <= 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	t, err := s.repo.RecordFailure(ctx, subjectType, subject, now, now.Add(-s.policy.ResetAfter))
+	if err != nil {
+		return err
+	}
+
+	if t.FailedAttempts < threshold {
+		return nil
+	}
+
+	return s.repo.Lock(ctx, subjectType, subject, now.Add(s.policy.LockDuration(t.Lockouts)))
+}
+
+// userSubject returns the subject tracking the failed logins of a user
+func userSubject(userID int64) string {
+	return strconv.FormatInt(userID, 10)
+}
+
+// clientIP returns the IP address of the client of the request, if known
+func clientIP(ctx context.Context) string {
+	if c, ok := auth.ClientFromContext(ctx); ok {
+		return c.IP
+	}
+	return ""
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// Multi-factor authentication errors
+var (
+	ErrInvalidMFACode     = InvalidArgumentError("INVALID_MFA_CODE", "code", "invalid authentication code", nil)
+	ErrTOTPAlreadyEnabled = FailedPreconditionError("TOTP_ALREADY_ENABLED", "TOTP is already enabled")
+	ErrTOTPNotEnrolled    = FailedPreconditionError("TOTP_NOT_ENROLLED", "TOTP enrollment has not been started")
+	ErrTOTPNotEnabled     = FailedPreconditionError("TOTP_NOT_ENABLED", "TOTP is not enabled")
+	// ErrTOTPEnrollmentDenied is returned when enrolling TOTP for another user,
+	// which would reveal their secret
+	ErrTOTPEnrollmentDenied = &Error{Kind: KindPermissionDenied, Reason: "TOTP_ENROLLMENT_DENIED", Message: "users can only enroll TOTP for themselves"}
+	// ErrMFARequired is returned when admins without TOTP enabled log in
+	// while admins are required to use a second factor
+	ErrMFARequired = FailedPreconditionError("MFA_REQUIRED", "admins must enable TOTP before logging in")
+)
+
+// TOTPEnrollment is the secret of a TOTP enrollment to add to an authenticator app
+type TOTPEnrollment struct {
+	// Secret is the base32 form of the secret
+	Secret string
+	// URI is the otpauth:// URI of the secret, usually shown as a QR code
+	URI string
+}
+
+// MFAService is responsible for the second authentication factors of users
+type MFAService struct {
+	userRepo      repository.UserRepository
+	roleRepo      repository.RoleRepository
+	uow           repository.UnitOfWork
+	box           *auth.SecretBox
+	issuer        string
+	lockout       *LockoutService
+	requireAdmins bool
+	authz         *Authorizer
+}
+
+// NewMFAService creates a new MFA service encrypting TOTP secrets with box.
+// Authenticator apps list the secrets under issuer. Wrong codes count toward
+// the lockout of users. When requireAdmins is set, admins are only issued
+// tokens once they enabled TOTP.
+func NewMFAService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, uow repository.UnitOfWork, box *auth.SecretBox, issuer string, lockout *LockoutService, requireAdmins bool) *MFAService {
+	return &MFAService{
+		userRepo:      userRepo,
+		roleRepo:      roleRepo,
+		uow:           uow,
+		box:           box,
+		issuer:        issuer,
+		lockout:       lockout,
+		requireAdmins: requireAdmins,
+		authz:         NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// EnrollTOTP starts the TOTP enrollment of a user with a new secret, which
+// replaces the secret of an unconfirmed enrollment. TOTP is only required
+// once the enrollment is confirmed with ConfirmTOTP.
+func (s *MFAService) EnrollTOTP(ctx context.Context, userID int64) (*TOTPEnrollment, error) {
+	if err := s.authorizeSelf(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	if u.IsTOTPEnabled() {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := user.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fails if a concurrent enrollment was confirmed in the meantime
+	if err := s.userRepo.SetTOTPSecret(ctx, userID, sealed); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrTOTPAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: user.EncodeTOTPSecret(secret),
+		URI:    user.TOTPURI(s.issuer, u.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables TOTP for a user who proves with a code that their
+// authenticator app holds the enrolled secret. It returns new one-time
+// recovery codes, which are only stored hashed and cannot be shown again.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID int64, code string) (*user.User, []string, error) {
+	if err := s.authorizeSelf(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	if u.IsTOTPEnabled() {
+		return nil, nil, ErrTOTPAlreadyEnabled
+	}
+	if u.TOTPSecret == nil {
+		return nil, nil, ErrTOTPNotEnrolled
+	}
+
+	secret, err := s.box.Open(*u.TOTPSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	step, ok := user.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, nil, ErrInvalidMFACode
+	}
+
+	codes, err := user.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashToken(c)
+	}
+
+	var enabledUser *user.User
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		// Fails if the enrollment was confirmed or restarted concurrently
+		if err := repos.Users.EnableTOTP(ctx, userID, time.Now().UTC()); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+
+		if err := repos.Users.UseTOTPStep(ctx, userID, step); err != nil {
+			return invalidMFACode(err)
+		}
+
+		if err := repos.Users.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+			return err
+		}
+
+		enabledUser, err = repos.Users.GetByID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return enabledUser, codes, nil
+}
+
+// DisableTOTP turns TOTP off for a user and deletes their recovery codes.
+// Users disabling their own TOTP must give a current code or a recovery
+// code, and wrong codes count as failed login attempts; admins can disable it
+// for users who lost their authenticator.
+func (s *MFAService) DisableTOTP(ctx context.Context, userID int64, code string) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, userID); err != nil {
+		return nil, err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	if !u.IsTOTPEnabled() {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	// Wrong codes count as failed login attempts like in VerifyMFA, so that
+	// an access token does not allow guessing codes
+	if isCaller(ctx, userID) {
+		if err := s.lockout.checkClient(ctx); err != nil {
+			return nil, err
+		}
+		if err := s.lockout.checkUser(ctx, userID); err != nil {
+			return nil, err
+		}
+		if err := s.verifyCode(ctx, u, code); err != nil {
+			if errors.Is(err, ErrInvalidMFACode) {
+				if err := s.lockout.recordFailure(ctx, userID); err != nil {
+					return nil, err
+				}
+			}
+			return nil, err
+		}
+	}
+
+	var disabledUser *user.User
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Users.DisableTOTP(ctx, userID); err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+		}
+
+		if err := repos.Users.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+			return err
+		}
+
+		disabledUser, err = repos.Users.GetByID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return disabledUser, nil
+}
+
+// checkRequired returns ErrMFARequired for users without TOTP enabled who are
+// an admin of any organization, when admins are required to use a second
+// factor
+func (s *MFAService) checkRequired(ctx context.Context, u *user.User) error {
+	if !s.requireAdmins || u.IsTOTPEnabled() {
+		return nil
+	}
+
+	admin, err := s.roleRepo.HasRoleAnywhere(ctx, u.ID, user.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admin {
+		return ErrMFARequired
+	}
+	return nil
+}
+
+// verifyCode checks a TOTP code or a recovery code of a user with TOTP
+// enabled, returning ErrInvalidMFACode if it is wrong. Each code can only be
+// used once.
+func (s *MFAService) verifyCode(ctx context.Context, u *user.User, code string) error {
+	if !user.IsTOTPCode(code) {
+		hash := auth.HashToken(user.NormalizeRecoveryCode(code))
+		return invalidMFACode(s.userRepo.UseRecoveryCode(ctx, u.ID, hash))
+	}
+
+	if u.TOTPSecret == nil {
+		return ErrInvalidMFACode
+	}
+
+	secret, err := s.box.Open(*u.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := user.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// Fails if the code, or a later one, was already used
+	return invalidMFACode(s.userRepo.UseTOTPStep(ctx, u.ID, step))
+}
+
+// authorizeSelf checks that the caller may update the user and is the user
+// themselves, as only they should ever see their TOTP secret
+func (s *MFAService) authorizeSelf(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, userID); err != nil {
+		return err
+	}
+
+	if principal, _ := auth.FromContext(ctx); !principal.System && principal.UserID != userID {
+		return ErrTOTPEnrollmentDenied
+	}
+	return nil
+}
+
+// isCaller reports whether the user making the request is the given user
+func isCaller(ctx context.Context, userID int64) bool {
+	id := actorID(ctx)
+	return id != nil && *id == userID
+}
+
+// invalidMFACode converts repository.ErrNotFound into ErrInvalidMFACode
+func invalidMFACode(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidMFACode
+	}
+	return err
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/tenant"
+)
+
+// OAuth errors. Their reasons are the error codes of RFC 6749 in upper case.
+var (
+	ErrOAuthInvalidClient           = &Error{Kind: KindUnauthenticated, Reason: "INVALID_CLIENT", Message: "unknown client or invalid client credentials"}
+	ErrOAuthInvalidRedirectURI      = &Error{Kind: KindInvalidArgument, Reason: "INVALID_REQUEST", Message: "redirect_uri is not registered for the client"}
+	ErrOAuthUnsupportedResponseType = &Error{Kind: KindInvalidArgument, Reason: "UNSUPPORTED_RESPONSE_TYPE", Message: "only the code response type is supported"}
+	ErrOAuthInvalidScope            = &Error{Kind: KindInvalidArgument, Reason: "INVALID_SCOPE", Message: "scope must include openid"}
+	ErrOAuthPKCERequired            = &Error{Kind: KindInvalidArgument, Reason: "INVALID_REQUEST", Message: "a code_challenge with method S256 is required"}
+	ErrOAuthInvalidGrant            = &Error{Kind: KindInvalidArgument, Reason: "INVALID_GRANT", Message: "invalid, expired or used authorization code"}
+	ErrOAuthAccessDenied            = &Error{Kind: KindPermissionDenied, Reason: "ACCESS_DENIED", Message: "the client belongs to another organization"}
+)
+
+// supportedScopes lists the scopes granted when requested; others are ignored
+var supportedScopes = []string{user.ScopeOpenID, user.ScopeProfile, user.ScopeEmail}
+
+// AuthorizationRequest is a request of a client to be authorized by the
+// logged in user, as sent to the authorization endpoint
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// TokenRequest is a request of a client to exchange an authorization code, as
+// sent to the token endpoint
+type TokenRequest struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// OIDCTokens are the tokens a client gets for an authorization code
+type OIDCTokens struct {
+	AccessToken string
+	IDToken     string
+	ExpiresIn   time.Duration
+	Scope       string
+}
+
+// OAuthService is responsible for the OpenID Connect provider: the clients
+// registered with it and the authorization code flow with PKCE
+type OAuthService struct {
+	userRepo   repository.UserRepository
+	clientRepo repository.OAuthClientRepository
+	codeRepo   repository.AuthorizationCodeRepository
+	sessions   *SessionService
+	tokens     *auth.TokenManager
+	signingKey *auth.SigningKey
+	issuer     string
+	codeTTL    time.Duration
+	idTokenTTL time.Duration
+	authz      *Authorizer
+}
+
+// NewOAuthService creates a new OAuth service issuing ID tokens signed with
+// signingKey on behalf of issuer. Authorization codes are valid for codeTTL.
+func NewOAuthService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, clientRepo repository.OAuthClientRepository, codeRepo repository.AuthorizationCodeRepository, sessions *SessionService, tokens *auth.TokenManager, signingKey *auth.SigningKey, issuer string, codeTTL, idTokenTTL time.Duration) *OAuthService {
+	return &OAuthService{
+		userRepo:   userRepo,
+		clientRepo: clientRepo,
+		codeRepo:   codeRepo,
+		sessions:   sessions,
+		tokens:     tokens,
+		signingKey: signingKey,
+		issuer:     issuer,
+		codeTTL:    codeTTL,
+		idTokenTTL: idTokenTTL,
+		authz:      NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// Issuer returns the issuer identifier of the provider
+func (s *OAuthService) Issuer() string {
+	return s.issuer
+}
+
+// SigningKey returns the key signing ID tokens
+func (s *OAuthService) SigningKey() *auth.SigningKey {
+	return s.signingKey
+}
+
+// SupportedScopes returns the scopes the provider grants
+func (s *OAuthService) SupportedScopes() []string {
+	return supportedScopes
+}
+
+// CreateClient registers an application with the provider. Confidential
+// clients get a secret, which is only stored hashed and cannot be shown again.
+func (s *OAuthService) CreateClient(ctx context.Context, name string, redirectURIs []string, confidential bool) (*user.OAuthClient, string, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageOAuthClients, 0); err != nil {
+		return nil, "", err
+	}
+
+	for _, uri := range redirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, "", InvalidArgumentError("INVALID_REDIRECT_URI", "redirect_uris", err.Error(), err)
+		}
+	}
+
+	clientID, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Callers without an organization register clients in the default one
+	organizationID, _ := tenant.FromContext(ctx)
+	client := &user.OAuthClient{
+		OrganizationID: organizationID,
+		ClientID:       clientID,
+		Name:           name,
+		RedirectURIs:   redirectURIs,
+		CreatedBy:      actorID(ctx),
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	var secret string
+	if confidential {
+		var secretHash string
+		secret, secretHash, err = auth.GenerateOpaqueToken()
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = &secretHash
+	}
+
+	if err := s.clientRepo.Create(ctx, client); err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+// ListClients returns the clients registered in the caller's organization
+func (s *OAuthService) ListClients(ctx context.Context) ([]*user.OAuthClient, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageOAuthClients, 0); err != nil {
+		return nil, err
+	}
+
+	return s.clientRepo.List(ctx)
+}
+
+// DeleteClient removes a client. Tokens already issued to it stay valid
+// until their sessions end.
+func (s *OAuthService) DeleteClient(ctx context.Context, clientID string) error {
+	if err := s.authz.Authorize(ctx, user.PermissionManageOAuthClients, 0); err != nil {
+		return err
+	}
+
+	if err := s.clientRepo.Delete(ctx, clientID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFoundError("OAUTH_CLIENT_NOT_FOUND", fmt.Sprintf("OAuth client not found with ID %q", clientID), err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ValidateRedirect returns the client of an authorization request if the
+// redirect URI is registered for it. Until it succeeds, errors must be shown
+// to the user instead of being sent to the redirect URI.
+func (s *OAuthService) ValidateRedirect(ctx context.Context, clientID, redirectURI string) (*user.OAuthClient, error) {
+	client, err := s.clientRepo.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOAuthInvalidClient
+		}
+		return nil, err
+	}
+
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, ErrOAuthInvalidRedirectURI
+	}
+
+	return client, nil
+}
+
+// Authorize grants the client of a validated authorization request access
+// to the logged in user, returning the authorization code to send to the
+// redirect URI. The code is valid once, for the client presenting the PKCE
+// verifier of the challenge.
+func (s *OAuthService) Authorize(ctx context.Context, client *user.OAuthClient, req AuthorizationRequest) (string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.System || principal.SessionID == 0 {
+		return "", ErrUnauthenticated
+	}
+
+	if req.ResponseType != "code" {
+		return "", ErrOAuthUnsupportedResponseType
+	}
+
+	// Clients only get access to the users of their organization
+	if principal.OrganizationID != client.OrganizationID {
+		return "", ErrOAuthAccessDenied
+	}
+
+	scope := grantedScope(req.Scope)
+	if !containsScope(scope, user.ScopeOpenID) {
+		return "", ErrOAuthInvalidScope
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != user.CodeChallengeMethodS256 {
+		return "", ErrOAuthPKCERequired
+	}
+
+	session, err := s.sessions.repo.GetByID(ctx, principal.SessionID)
+	if err != nil {
+		return "", err
+	}
+
+	code, codeHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	stored := &user.AuthorizationCode{
+		CodeHash:      codeHash,
+		ClientID:      client.ClientID,
+		UserID:        principal.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      session.CreatedAt,
+		ExpiresAt:     now.Add(s.codeTTL),
+		CreatedAt:     now,
+	}
+	if err := s.codeRepo.Create(ctx, stored); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeCode exchanges an authorization code for an access token and an ID
+// token of its user. The access token is issued to the client with the
+// granted scope and is only accepted by the userinfo endpoint. It belongs to
+// a new session named after the client, which the user can revoke like any
+// other.
+func (s *OAuthService) ExchangeCode(ctx context.Context, req TokenRequest) (*OIDCTokens, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.codeRepo.GetByHash(ctx, auth.HashToken(req.Code))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOAuthInvalidGrant
+		}
+		return nil, err
+	}
+
+	if code.IsUsed() || code.IsExpired() || code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, ErrOAuthInvalidGrant
+	}
+
+	if !code.VerifyCodeVerifier(req.CodeVerifier) {
+		return nil, ErrOAuthInvalidGrant
+	}
+
+	// Fails if the code was exchanged concurrently
+	if err := s.codeRepo.MarkUsed(ctx, code.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOAuthInvalidGrant
+		}
+		return nil, err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, code.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOAuthInvalidGrant
+		}
+		return nil, err
+	}
+
+	// The user was blocked since they authorized the client
+	if !u.CanAuthenticate() {
+		return nil, ErrOAuthInvalidGrant
+	}
+
+	session, err := s.sessions.start(ctx, u.ID, client.Name, s.tokens.AccessTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.tokens.IssueClientAccessToken(u, session.ID, client.ClientID, code.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := s.signingKey.SignIDToken(s.idTokenClaims(u, client, code))
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCTokens{
+		AccessToken: accessToken,
+		IDToken:     idToken,
+		ExpiresIn:   s.tokens.AccessTokenTTL(),
+		Scope:       code.Scope,
+	}, nil
+}
+
+// UserInfo returns the user authenticated by the access token of the request
+func (s *OAuthService) UserInfo(ctx context.Context) (*user.User, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.System {
+		return nil, ErrUnauthenticated
+	}
+
+	u, err := s.userRepo.GetByID(ctx, principal.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUnauthenticated
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// authenticateClient returns the client with the ID, checking the secret of
+// confidential clients
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*user.OAuthClient, error) {
+	client, err := s.clientRepo.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOAuthInvalidClient
+		}
+		return nil, err
+	}
+
+	if client.IsConfidential() {
+		hash := auth.HashToken(clientSecret)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(*client.SecretHash)) != 1 {
+			return nil, ErrOAuthInvalidClient
+		}
+	}
+
+	return client, nil
+}
+
+// idTokenClaims builds the claims of the ID token of a user, with the profile
+// and email claims of the scopes granted with the code
+func (s *OAuthService) idTokenClaims(u *user.User, client *user.OAuthClient, code *user.AuthorizationCode) *auth.IDTokenClaims {
+	now := time.Now().UTC()
+	claims := &auth.IDTokenClaims{
+		Nonce:    code.Nonce,
+		AuthTime: jwt.NewNumericDate(code.AuthTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(u.ID, 10),
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.idTokenTTL)),
+		},
+	}
+
+	if code.HasScope(user.ScopeProfile) {
+		claims.PreferredUsername = u.Username
+		claims.Name = u.FullName
+	}
+	if code.HasScope(user.ScopeEmail) {
+		verified := u.EmailVerifiedAt != nil
+		claims.Email = u.Email
+		claims.EmailVerified = &verified
+	}
+
+	return claims
+}
+
+// grantedScope returns the supported scopes among the requested ones
+func grantedScope(requested string) string {
+	var granted []string
+	for _, scope := range strings.Fields(requested) {
+		for _, supported := range supportedScopes {
+			if scope == supported {
+				granted = append(granted, scope)
+				break
+			}
+		}
+	}
+	return strings.Join(granted, " ")
+}
+
+// containsScope reports whether a space separated list of scopes contains
+// the scope
+func containsScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedirectURI checks that a redirect URI is an absolute URL without
+// a fragment, as required by RFC 6749
+func validateRedirectURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid redirect URI %q: %v", uri, err)
+	}
+	if !parsed.IsAbs() || parsed.Host == "" {
+		return fmt.Errorf("redirect URI %q must be an absolute URL", uri)
+	}
+	if parsed.Fragment != "" {
+		return fmt.Errorf("redirect URI %q must not have a fragment", uri)
+	}
+	return nil
+}
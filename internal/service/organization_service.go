@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/tenant"
+)
+
+// Organization errors
+var (
+	ErrSlugTaken = AlreadyExistsError("SLUG_TAKEN", "slug", "organization slug already taken", nil)
+	// ErrDefaultOrganization is returned when deleting the default
+	// organization or changing its slug, which users signing up rely on
+	ErrDefaultOrganization = FailedPreconditionError("DEFAULT_ORGANIZATION", "the default organization cannot be deleted or renamed")
+	// ErrOrganizationNotEmpty is returned when deleting an organization that
+	// still has users
+	ErrOrganizationNotEmpty = FailedPreconditionError("ORGANIZATION_NOT_EMPTY", "organization still has users, including deleted users not purged yet")
+	// ErrHomeOrganization is returned when removing users from the
+	// organization they belong to, which they can only leave by being purged
+	ErrHomeOrganization = FailedPreconditionError("HOME_ORGANIZATION", "users cannot be removed from the organization they belong to")
+	// ErrLastOwner is returned when removing or demoting the last owner of an
+	// organization
+	ErrLastOwner = FailedPreconditionError("LAST_OWNER", "organization must keep at least one owner")
+)
+
+// OrganizationService is responsible for organizations, the tenants whose
+// users are kept apart from each other. What a user may do with an
+// organization depends on the role of their membership in it.
+type OrganizationService struct {
+	repo     repository.OrganizationRepository
+	userRepo repository.UserRepository
+	uow      repository.UnitOfWork
+	authz    *Authorizer
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.OrganizationRepository, uow repository.UnitOfWork) *OrganizationService {
+	return &OrganizationService{
+		repo:     repo,
+		userRepo: userRepo,
+		uow:      uow,
+		authz:    NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// CreateOrganization creates an organization and makes the caller its owner
+// and an admin of it
+func (s *OrganizationService) CreateOrganization(ctx context.Context, slug, name string) (*user.Organization, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionCreateOrganizations, 0); err != nil {
+		return nil, err
+	}
+
+	org := user.NewOrganization(slug, name)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Organizations.Create(ctx, org); err != nil {
+			return translateOrganizationError(err)
+		}
+
+		// System callers are not users and cannot be members
+		owner := actorID(ctx)
+		if owner == nil {
+			return nil
+		}
+		if err := repos.Organizations.AddMember(ctx, user.NewMembership(org.ID, *owner, user.OrganizationRoleOwner)); err != nil {
+			return err
+		}
+
+		// Let the owner manage the users of the organization when acting in it
+		return repos.Roles.Grant(tenant.NewContext(ctx, org.ID), *owner, user.RoleAdmin, owner)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// GetOrganization retrieves an organization the caller is a member of
+func (s *OrganizationService) GetOrganization(ctx context.Context, id int64) (*user.Organization, error) {
+	if err := s.authorizeMember(ctx, id, nil); err != nil {
+		return nil, err
+	}
+
+	org, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, organizationNotFound(err, id)
+	}
+
+	return org, nil
+}
+
+// ListOrganizations returns the organizations the caller is a member of, or
+// every organization for system callers
+func (s *OrganizationService) ListOrganizations(ctx context.Context) ([]*user.Organization, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	if principal.System {
+		return s.repo.List(ctx)
+	}
+	return s.repo.ListByMember(ctx, principal.UserID)
+}
+
+// IsMember reports whether the user is a member of the organization
+func (s *OrganizationService) IsMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	if _, err := s.repo.GetMembership(ctx, organizationID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateOrganization changes the slug and name of an organization. Nil
+// values are left unchanged. Only its owners and admins may update it.
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, id int64, slug, name *string) (*user.Organization, error) {
+	if err := authorizeOrganizationChange(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizeMember(ctx, id, user.OrganizationRole.CanManage); err != nil {
+		return nil, err
+	}
+
+	org, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, organizationNotFound(err, id)
+	}
+
+	if slug != nil && *slug != org.Slug {
+		if org.IsDefault() {
+			return nil, ErrDefaultOrganization
+		}
+		org.Slug = *slug
+	}
+	if name != nil {
+		org.Name = *name
+	}
+
+	if err := s.repo.Update(ctx, org); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, organizationNotFound(err, id)
+		}
+		return nil, translateOrganizationError(err)
+	}
+
+	return org, nil
+}
+
+// DeleteOrganization deletes an organization along with its memberships. Only
+// its owners may delete it, once every user of the organization is purged.
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, id int64) error {
+	if err := authorizeOrganizationChange(ctx); err != nil {
+		return err
+	}
+
+	if err := s.authorizeMember(ctx, id, isOwner); err != nil {
+		return err
+	}
+
+	org, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return organizationNotFound(err, id)
+	}
+
+	if org.IsDefault() {
+		return ErrDefaultOrganization
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return organizationNotFound(err, id)
+		}
+		return translateOrganizationError(err)
+	}
+
+	return nil
+}
+
+// AddMember makes an existing user, identified by their email address, a
+// member of an organization with the given role, or changes the role of a
+// member. Only its owners and admins may add members, and only its owners may
+// make members owners or change the role of owners. Members act in the
+// organization with the roles granted to them in it.
+func (s *OrganizationService) AddMember(ctx context.Context, id int64, email, roleName string) (*user.Membership, error) {
+	if err := authorizeOrganizationChange(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizeMember(ctx, id, user.OrganizationRole.CanManage); err != nil {
+		return nil, err
+	}
+
+	role, err := user.ParseOrganizationRole(roleName)
+	if err != nil {
+		return nil, InvalidArgumentError("UNKNOWN_ORGANIZATION_ROLE", "role", err.Error(), err)
+	}
+
+	// Email addresses identify users across organizations
+	u, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with email %s", email))
+	}
+
+	membership := user.NewMembership(id, u.ID, role)
+	current, err := s.repo.GetMembership(ctx, id, u.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		current = nil
+	case err != nil:
+		return nil, err
+	default:
+		membership.CreatedAt = current.CreatedAt
+	}
+
+	if role == user.OrganizationRoleOwner || (current != nil && current.Role == user.OrganizationRoleOwner) {
+		if err := s.authorizeMember(ctx, id, isOwner); err != nil {
+			return nil, err
+		}
+	}
+	if current != nil && current.Role == user.OrganizationRoleOwner && role != user.OrganizationRoleOwner {
+		if err := s.checkOtherOwner(ctx, id, u.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.AddMember(ctx, membership); err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// ListMembers returns the memberships of an organization the caller is a
+// member of
+func (s *OrganizationService) ListMembers(ctx context.Context, id int64) ([]*user.Membership, error) {
+	if err := s.authorizeMember(ctx, id, nil); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListMembers(ctx, id)
+}
+
+// RemoveMember removes a user from an organization, revoking the roles they
+// were granted in it. Only its owners and admins may remove members, and only
+// its owners may remove owners. Users cannot be removed from the organization
+// they belong to.
+func (s *OrganizationService) RemoveMember(ctx context.Context, id, userID int64) error {
+	if err := authorizeOrganizationChange(ctx); err != nil {
+		return err
+	}
+
+	if err := s.authorizeMember(ctx, id, user.OrganizationRole.CanManage); err != nil {
+		return err
+	}
+
+	membership, err := s.repo.GetMembership(ctx, id, userID)
+	if err != nil {
+		return memberNotFound(err, id, userID)
+	}
+
+	if membership.Role == user.OrganizationRoleOwner {
+		if err := s.authorizeMember(ctx, id, isOwner); err != nil {
+			return err
+		}
+		if err := s.checkOtherOwner(ctx, id, userID); err != nil {
+			return err
+		}
+	}
+
+	// The users of the organization itself only leave it by being purged
+	_, err = s.userRepo.GetByID(tenant.NewContext(ctx, id), userID)
+	switch {
+	case err == nil:
+		return ErrHomeOrganization
+	case !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+
+	if err := s.repo.RemoveMember(ctx, id, userID); err != nil {
+		return memberNotFound(err, id, userID)
+	}
+
+	return nil
+}
+
+// checkOtherOwner checks that an organization has an owner other than the
+// user, who is about to stop being one
+func (s *OrganizationService) checkOtherOwner(ctx context.Context, id, userID int64) error {
+	memberships, err := s.repo.ListMembers(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, membership := range memberships {
+		if membership.Role == user.OrganizationRoleOwner && membership.UserID != userID {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+// isOwner reports whether the role is the owner role
+func isOwner(role user.OrganizationRole) bool {
+	return role == user.OrganizationRoleOwner
+}
+
+// authorizeMember checks that the caller is a member of the organization
+// with a role that is allowed, if allowed is not nil. Organizations the
+// caller is not a member of are reported as not found. System callers are
+// always allowed.
+func (s *OrganizationService) authorizeMember(ctx context.Context, id int64, allowed func(user.OrganizationRole) bool) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	if principal.System {
+		return nil
+	}
+
+	membership, err := s.repo.GetMembership(ctx, id, principal.UserID)
+	if err != nil {
+		return organizationNotFound(err, id)
+	}
+
+	if allowed != nil && !allowed(membership.Role) {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// authorizeOrganizationChange checks that the caller may update or delete
+// organizations at all. Only API keys lacking the scope may not.
+func authorizeOrganizationChange(ctx context.Context) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	if !principal.HasScope(user.PermissionManageOrganizations) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// organizationNotFound converts repository.ErrNotFound into a NotFound error
+// for the organization
+func organizationNotFound(err error, id int64) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFoundError("ORGANIZATION_NOT_FOUND", fmt.Sprintf("organization not found with ID %d", id), err)
+	}
+	return err
+}
+
+// memberNotFound converts repository.ErrNotFound into a NotFound error for
+// the membership of the user in the organization
+func memberNotFound(err error, id, userID int64) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFoundError("MEMBER_NOT_FOUND", fmt.Sprintf("user %d is not a member of organization %d", userID, id), err)
+	}
+	return err
+}
+
+// translateOrganizationError converts repository errors about organizations
+// into service errors
+func translateOrganizationError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateSlug):
+		return ErrSlugTaken
+	case errors.Is(err, repository.ErrOrganizationNotEmpty):
+		return ErrOrganizationNotEmpty
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// ErrInvalidPageToken is returned when a page token is malformed or was issued
+// for a different sort order
+var ErrInvalidPageToken = InvalidArgumentError("INVALID_PAGE_TOKEN", "page_token", "invalid page token", nil)
+
+// pageToken is the decoded form of the opaque token handed out to clients. It
+// records the sort order it was issued for so it cannot be replayed against
+// another one.
+type pageToken struct {
+	SortBy   repository.UserSortField `json:"s,omitempty"`
+	SortDesc bool                     `json:"d,omitempty"`
+	Value    string                   `json:"v,omitempty"`
+	ID       int64                    `json:"i"`
+}
+
+// encodePageToken returns the opaque token resuming the list after cursor
+func encodePageToken(filter repository.UserFilter, cursor *repository.UserCursor) (string, error) {
+	data, err := json.Marshal(pageToken{
+		SortBy:   filter.SortBy,
+		SortDesc: filter.SortDesc,
+		Value:    cursor.Value,
+		ID:       cursor.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePageToken parses a token issued by encodePageToken and checks that it
+// matches the sort order of filter
+func decodePageToken(token string, filter repository.UserFilter) (*repository.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var decoded pageToken
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	if decoded.SortBy != filter.SortBy || decoded.SortDesc != filter.SortDesc {
+		return nil, ErrInvalidPageToken
+	}
+
+	return &repository.UserCursor{Value: decoded.Value, ID: decoded.ID}, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// passwordPolicyError converts a *user.PasswordPolicyError about the given
+// request field into an InvalidArgument error listing each broken rule
+func passwordPolicyError(err error, field string) error {
+	var policyErr *user.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return err
+	}
+
+	violations := make([]FieldViolation, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		violations[i] = FieldViolation{Field: field, Reason: v.Rule, Description: v.Message}
+	}
+
+	return &Error{
+		Kind:       KindInvalidArgument,
+		Reason:     "PASSWORD_POLICY_VIOLATION",
+		Field:      field,
+		Message:    policyErr.Error(),
+		Violations: violations,
+		Err:        err,
+	}
+}
+
+// checkPasswordReuse rejects a password matching one of the recent passwords
+// of a user
+func checkPasswordReuse(ctx context.Context, history repository.PasswordHistoryRepository, policy *user.PasswordPolicy, hasher user.PasswordHasher, userID int64, password, field string) error {
+	if policy.HistorySize <= 0 {
+		return nil
+	}
+
+	hashes, err := history.ListRecent(ctx, userID, policy.HistorySize)
+	if err != nil {
+		return err
+	}
+
+	return passwordPolicyError(policy.CheckReuse(hasher, password, hashes), field)
+}
+
+// recordPassword adds the new password hash of a user to their history,
+// dropping the entries the policy no longer needs
+func recordPassword(ctx context.Context, history repository.PasswordHistoryRepository, policy *user.PasswordPolicy, userID int64, passwordHash string) error {
+	if err := history.Add(ctx, userID, passwordHash); err != nil {
+		return err
+	}
+
+	return history.Prune(ctx, userID, policy.HistorySize)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/mailer"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// ErrInvalidPasswordResetToken is returned when a password reset token is unknown, used or expired
+var ErrInvalidPasswordResetToken = InvalidArgumentError("INVALID_PASSWORD_RESET_TOKEN", "token", "invalid or expired password reset token", nil)
+
+// PasswordResetService is responsible for letting users who forgot their
+// password set a new one
+type PasswordResetService struct {
+	userRepo repository.UserRepository
+	uow      repository.UnitOfWork
+	mailer   mailer.Mailer
+	ttl      time.Duration
+	resetURL string
+	policy   *user.PasswordPolicy
+	hasher   user.PasswordHasher
+}
+
+// NewPasswordResetService creates a new password reset service enforcing the
+// password policy and hashing passwords with the hasher. Reset emails link to
+// resetURL with the token as a query parameter, or contain only the token
+// when resetURL is empty.
+func NewPasswordResetService(userRepo repository.UserRepository, uow repository.UnitOfWork, m mailer.Mailer, ttl time.Duration, resetURL string, policy *user.PasswordPolicy, hasher user.PasswordHasher) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo: userRepo,
+		uow:      uow,
+		mailer:   m,
+		ttl:      ttl,
+		resetURL: resetURL,
+		policy:   policy,
+		hasher:   hasher,
+	}
+}
+
+// RequestPasswordReset emails a password reset token to the user with the
+// given email, invalidating the previous ones. It succeeds whether or not
+// such a user exists so that callers cannot probe for registered emails.
+func (s *PasswordResetService) RequestPasswordReset(ctx context.Context, email string) error {
+	u, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var token string
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.PasswordResets.InvalidateAllForUser(ctx, u.ID); err != nil {
+			return err
+		}
+
+		var tokenHash string
+		token, tokenHash, err = auth.GenerateOpaqueToken()
+		if err != nil {
+			return err
+		}
+
+		return repos.PasswordResets.Create(ctx, user.NewPasswordResetToken(u.ID, tokenHash, s.ttl))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reporting a failed delivery would reveal that the email is registered
+	if err := s.sendToken(ctx, u, token); err != nil {
+		log.Printf("failed to send password reset email to user %d: %v", u.ID, err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset consumes a password reset token and sets the new
+// password of its user. Every session of the user is revoked, so other
+// devices have to log in again.
+func (s *PasswordResetService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	if err := s.policy.Validate(newPassword); err != nil {
+		return passwordPolicyError(err, "new_password")
+	}
+
+	// Hash the new password before opening the transaction
+	passwordHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		stored, err := repos.PasswordResets.GetByHash(ctx, auth.HashToken(token))
+		if err != nil {
+			return invalidPasswordResetToken(err)
+		}
+
+		if stored.IsUsed() || stored.IsExpired() {
+			return ErrInvalidPasswordResetToken
+		}
+
+		// Fails if another request used the token concurrently
+		if err := repos.PasswordResets.MarkUsed(ctx, stored.ID); err != nil {
+			return invalidPasswordResetToken(err)
+		}
+
+		u, err := repos.Users.GetByID(ctx, stored.UserID)
+		if err != nil {
+			return invalidPasswordResetToken(err)
+		}
+
+		if err := checkPasswordReuse(ctx, repos.PasswordHistory, s.policy, s.hasher, u.ID, newPassword, "new_password"); err != nil {
+			return err
+		}
+
+		u.SetPasswordHash(passwordHash)
+		if err := repos.Users.Update(ctx, u); err != nil {
+			return translateRepositoryError(err)
+		}
+
+		if err := recordPassword(ctx, repos.PasswordHistory, s.policy, u.ID, passwordHash); err != nil {
+			return err
+		}
+
+		// Tokens requested before this one must not allow another reset
+		if err := repos.PasswordResets.InvalidateAllForUser(ctx, u.ID); err != nil {
+			return err
+		}
+
+		return revokeAllSessions(ctx, repos, u.ID)
+	})
+}
+
+// sendToken emails a password reset token to the user
+func (s *PasswordResetService) sendToken(ctx context.Context, u *user.User, token string) error {
+	instructions := fmt.Sprintf("Use this token to choose a new password:\n\n%s", token)
+	if s.resetURL != "" {
+		link, err := tokenLink(s.resetURL, token)
+		if err != nil {
+			return err
+		}
+		instructions = fmt.Sprintf("Open this link to choose a new password:\n\n%s", link)
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. %s\n\nIt expires in %s. If you did not ask for this, you can ignore this email.",
+			u.FullName, instructions, s.ttl,
+		),
+	})
+}
+
+// invalidPasswordResetToken converts repository.ErrNotFound into ErrInvalidPasswordResetToken
+func invalidPasswordResetToken(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidPasswordResetToken
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+// sessionTouchInterval is how often the last use of a session is recorded,
+// so that authenticated requests do not all write to the database
+const sessionTouchInterval = time.Minute
+
+// SessionService is responsible for the login sessions of users
+type SessionService struct {
+	userRepo repository.UserRepository
+	repo     repository.SessionRepository
+	uow      repository.UnitOfWork
+	authz    *Authorizer
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, repo repository.SessionRepository, uow repository.UnitOfWork) *SessionService {
+	return &SessionService{
+		userRepo: userRepo,
+		repo:     repo,
+		uow:      uow,
+		authz:    NewAuthorizer(userRepo, roleRepo),
+	}
+}
+
+// ListSessions returns the active sessions of a user, most recently seen first
+func (s *SessionService) ListSessions(ctx context.Context, userID int64) ([]*user.Session, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionReadUsers, userID); err != nil {
+		return nil, err
+	}
+
+	// Make sure the user exists
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	return s.repo.ListActiveByUser(ctx, userID)
+}
+
+// RevokeSession ends a session of a user. Its refresh tokens are revoked and
+// its access tokens are rejected from then on.
+func (s *SessionService) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, userID); err != nil {
+		return err
+	}
+
+	err := s.end(ctx, userID, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFoundError("SESSION_NOT_FOUND", fmt.Sprintf("active session not found with ID %d", sessionID), err)
+	}
+	return err
+}
+
+// RevokeAllSessions ends every session of a user, including the one of the
+// caller when users revoke their own sessions
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, userID); err != nil {
+		return err
+	}
+
+	// Make sure the user exists
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	return s.endAll(ctx, userID)
+}
+
+// IsSessionActive reports whether a session of the user is neither revoked
+// nor expired, recording its use every sessionTouchInterval. It lets the auth
+// interceptor reject the access tokens of ended sessions.
+func (s *SessionService) IsSessionActive(ctx context.Context, userID, sessionID int64) (bool, error) {
+	if sessionID == 0 {
+		return false, nil
+	}
+
+	session, err := s.repo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if session.UserID != userID || !session.IsActive() {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	ip := clientIP(ctx)
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval || (ip != "" && ip != session.IP) {
+		if ip == "" {
+			ip = session.IP
+		}
+		if err := s.repo.Touch(ctx, sessionID, ip, now); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// start creates a session for a user logging in from the client of the
+// request, lasting ttl unless its refresh token is rotated
+func (s *SessionService) start(ctx context.Context, userID int64, device string, ttl time.Duration) (*user.Session, error) {
+	var userAgent string
+	if c, ok := auth.ClientFromContext(ctx); ok {
+		userAgent = c.UserAgent
+	}
+
+	session := user.NewSession(userID, device, clientIP(ctx), userAgent, ttl)
+	if err := s.repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// end revokes a session of a user along with its refresh tokens, returning
+// repository.ErrNotFound if the user has no such active session
+func (s *SessionService) end(ctx context.Context, userID, sessionID int64) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Sessions.Revoke(ctx, sessionID, userID); err != nil {
+			return err
+		}
+
+		return repos.RefreshTokens.RevokeAllForSession(ctx, sessionID)
+	})
+}
+
+// endAll revokes every session and refresh token of a user
+func (s *SessionService) endAll(ctx context.Context, userID int64) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		return revokeAllSessions(ctx, repos, userID)
+	})
+}
+
+// revokeAllSessions revokes every session and refresh token of a user within
+// a unit of work
+func revokeAllSessions(ctx context.Context, repos repository.Repositories, userID int64) error {
+	if err := repos.Sessions.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	return repos.RefreshTokens.RevokeAllForUser(ctx, userID)
+}
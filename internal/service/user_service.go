@@ -3,106 +3,521 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/truongtu268/project_maker/internal/auth"
 	"github.com/truongtu268/project_maker/internal/domain/user"
 	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/tenant"
+)
+
+var (
+	// ErrRevokeOwnAdmin is returned when an admin tries to revoke their own admin role
+	ErrRevokeOwnAdmin = FailedPreconditionError("REVOKE_OWN_ADMIN", "cannot revoke your own admin role")
+	// ErrUserNotDeleted is returned when purging a user that has not been deleted first
+	ErrUserNotDeleted = FailedPreconditionError("USER_NOT_DELETED", "user must be deleted before it can be purged")
+	// ErrPasswordUpdateDenied is returned when a caller who may not set passwords updates one with UpdateUser
+	ErrPasswordUpdateDenied = &Error{Kind: KindPermissionDenied, Reason: "PASSWORD_UPDATE_DENIED", Field: "password", Message: "use ChangePassword to change your password"}
+	// ErrIncorrectPassword is returned when the current password given to ChangePassword is wrong
+	ErrIncorrectPassword = InvalidArgumentError("INCORRECT_PASSWORD", "current_password", "current password is incorrect", nil)
+	// ErrSuspendSelf is returned when an admin tries to suspend or deactivate
+	// their own account
+	ErrSuspendSelf = FailedPreconditionError("SUSPEND_SELF", "cannot suspend or deactivate your own account")
 )
 
 // UserService is responsible for user-related business logic
 type UserService struct {
-	repo repository.UserRepository
+	repo     repository.UserRepository
+	roleRepo repository.RoleRepository
+	orgRepo  repository.OrganizationRepository
+	uow      repository.UnitOfWork
+	verifier *EmailVerificationService
+	policy   *user.PasswordPolicy
+	hasher   user.PasswordHasher
+	lockout  *LockoutService
+	authz    *Authorizer
 }
 
-// NewUserService creates a new user service
-func NewUserService(repo repository.UserRepository) *UserService {
+// NewUserService creates a new user service enforcing the password policy,
+// hashing passwords with the hasher and counting wrong current passwords
+// toward the lockout of users
+func NewUserService(repo repository.UserRepository, roleRepo repository.RoleRepository, orgRepo repository.OrganizationRepository, uow repository.UnitOfWork, verifier *EmailVerificationService, policy *user.PasswordPolicy, hasher user.PasswordHasher, lockout *LockoutService) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:     repo,
+		roleRepo: roleRepo,
+		orgRepo:  orgRepo,
+		uow:      uow,
+		verifier: verifier,
+		policy:   policy,
+		hasher:   hasher,
+		lockout:  lockout,
+		authz:    NewAuthorizer(repo, roleRepo),
 	}
 }
 
-// CreateUser creates a new user
+// CreateUser creates a new user in the organization ctx is scoped to.
+// Anonymous callers sign up on their own in the default organization, while
+// authenticated callers need the permission to add users to theirs.
 func (s *UserService) CreateUser(ctx context.Context, username, email, password, fullName string) (*user.User, error) {
-	// Check if user with same username or email already exists
-	if _, err := s.repo.GetByUsername(ctx, username); err == nil {
-		return nil, errors.New("username already taken")
+	if _, ok := auth.FromContext(ctx); ok {
+		if err := s.authz.Authorize(ctx, user.PermissionCreateUsers, 0); err != nil {
+			return nil, err
+		}
 	}
 
-	if _, err := s.repo.GetByEmail(ctx, email); err == nil {
-		return nil, errors.New("email already registered")
+	newUser, err := s.newUser(username, email, password, fullName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create new user
-	newUser, err := user.NewUser(username, email, password, fullName)
+	var verificationToken string
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := s.insertUser(ctx, repos, newUser); err != nil {
+			return err
+		}
+
+		verificationToken, err = s.verifier.issueToken(ctx, repos.EmailVerifications, newUser)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, translateRepositoryError(err)
+	}
+
+	s.verifier.notify(ctx, newUser, verificationToken)
+
+	return newUser, nil
+}
+
+// newUser creates a new user whose password follows the policy
+func (s *UserService) newUser(username, email, password, fullName string) (*user.User, error) {
+	newUser, err := user.NewUser(username, email, password, fullName, s.policy, s.hasher)
+	if err != nil {
+		return nil, passwordPolicyError(err, "password")
+	}
+	return newUser, nil
+}
+
+// insertUser saves a new user in a unit of work, along with the password
+// history, role and membership every user starts with
+func (s *UserService) insertUser(ctx context.Context, repos repository.Repositories, newUser *user.User) error {
+	if err := checkUniqueFields(ctx, repos.Users, newUser.Username, newUser.Email, true, true); err != nil {
+		return err
 	}
 
 	// Save to repository
-	if err := s.repo.Create(ctx, newUser); err != nil {
-		return nil, err
+	if err := repos.Users.Create(ctx, newUser); err != nil {
+		return err
 	}
 
-	return newUser, nil
+	if err := recordPassword(ctx, repos.PasswordHistory, s.policy, newUser.ID, newUser.PasswordHash); err != nil {
+		return err
+	}
+
+	// Every user can manage their own record
+	if err := repos.Roles.Grant(ctx, newUser.ID, user.RoleSelf, actorID(ctx)); err != nil {
+		return err
+	}
+
+	return repos.Organizations.AddMember(ctx, user.NewMembership(newUser.OrganizationID, newUser.ID, user.OrganizationRoleMember))
+}
+
+// checkUniqueFields locks the username and email and checks that the ones
+// being claimed are not used by another active user, of the organization ctx
+// is scoped to for usernames and of any organization for email addresses
+func checkUniqueFields(ctx context.Context, users repository.UserRepository, username, email string, checkUsername, checkEmail bool) error {
+	if err := users.LockUniqueFields(ctx, username, email); err != nil {
+		return err
+	}
+
+	// Check if user with same username or email already exists
+	if checkUsername {
+		if _, err := users.GetByUsername(ctx, username); err == nil {
+			return errUsernameTaken
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+
+	if checkEmail {
+		if _, err := users.GetByEmail(ctx, email); err == nil {
+			return errEmailTaken
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // GetUser retrieves a user by ID
 func (s *UserService) GetUser(ctx context.Context, id int64) (*user.User, error) {
-	return s.repo.GetByID(ctx, id)
+	if err := s.authz.Authorize(ctx, user.PermissionReadUsers, id); err != nil {
+		return nil, err
+	}
+
+	foundUser, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+	}
+
+	return foundUser, nil
 }
 
-// UpdateUser updates user details
-func (s *UserService) UpdateUser(ctx context.Context, id int64, username, email, password, fullName *string) (*user.User, error) {
-	// Get existing user
+// UpdateUser updates user details. A non-zero expectedVersion makes the update
+// fail with repository.ErrVersionConflict if the user was changed since the
+// caller read it. Only callers allowed to set passwords can change the
+// password here; users change their own with ChangePassword.
+func (s *UserService) UpdateUser(ctx context.Context, id, expectedVersion int64, username, email, password, fullName *string) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, id); err != nil {
+		return nil, err
+	}
+
+	// Hash the new password before opening the transaction
+	var passwordHash string
+	if password != nil {
+		if err := s.authz.Authorize(ctx, user.PermissionSetPasswords, id); err != nil {
+			if errors.Is(err, ErrPermissionDenied) {
+				return nil, ErrPasswordUpdateDenied
+			}
+			return nil, err
+		}
+
+		if err := s.policy.Validate(*password); err != nil {
+			return nil, passwordPolicyError(err, "password")
+		}
+
+		hashedPassword, err := s.hasher.Hash(*password)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = hashedPassword
+	}
+
+	var (
+		existingUser      *user.User
+		verificationToken string
+	)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		// Get existing user
+		var err error
+		existingUser, err = repos.Users.GetByID(ctx, id)
+		if err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+		}
+
+		if expectedVersion != 0 && expectedVersion != existingUser.Version {
+			return repository.ErrVersionConflict
+		}
+
+		// Check if username or email is being changed and already exists
+		usernameChanged := username != nil && *username != existingUser.Username
+		emailChanged := email != nil && *email != existingUser.Email
+		if usernameChanged || emailChanged {
+			newUsername, newEmail := existingUser.Username, existingUser.Email
+			if usernameChanged {
+				newUsername = *username
+			}
+			if emailChanged {
+				newEmail = *email
+			}
+
+			// Usernames are unique within the organization of the user
+			orgCtx := tenant.NewContext(ctx, existingUser.OrganizationID)
+			if err := checkUniqueFields(orgCtx, repos.Users, newUsername, newEmail, usernameChanged, emailChanged); err != nil {
+				return err
+			}
+
+			existingUser.Username = newUsername
+			existingUser.Email = newEmail
+		}
+
+		// A new email address has to be verified again
+		if emailChanged {
+			existingUser.EmailVerifiedAt = nil
+		}
+
+		// Update password if provided
+		if password != nil {
+			if err := checkPasswordReuse(ctx, repos.PasswordHistory, s.policy, s.hasher, id, *password, "password"); err != nil {
+				return err
+			}
+			existingUser.SetPasswordHash(passwordHash)
+		}
+
+		// Update full name if provided
+		if fullName != nil {
+			existingUser.FullName = *fullName
+		}
+
+		// Save changes
+		if err := repos.Users.Update(ctx, existingUser); err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+		}
+
+		if password != nil {
+			if err := recordPassword(ctx, repos.PasswordHistory, s.policy, id, passwordHash); err != nil {
+				return err
+			}
+		}
+
+		if !emailChanged {
+			return nil
+		}
+
+		verificationToken, err = s.verifier.issueToken(ctx, repos.EmailVerifications, existingUser)
+		return err
+	})
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	if verificationToken != "" {
+		s.verifier.notify(ctx, existingUser, verificationToken)
+	}
+
+	return existingUser, nil
+}
+
+// ChangePassword replaces the password of a user after checking their current
+// one. Wrong current passwords count as failed login attempts, and passwords
+// are not checked while the user is locked. Every session of the user is
+// revoked, so each of their devices has to log in again.
+func (s *UserService) ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionUpdateUsers, id); err != nil {
+		return nil, err
+	}
+
 	existingUser, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+	}
+
+	// An access token must not allow guessing the password of its user
+	if err := s.lockout.checkClient(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.lockout.checkUser(ctx, id); err != nil {
+		return nil, err
+	}
+	if !existingUser.CheckPassword(s.hasher, currentPassword) {
+		if err := s.lockout.recordFailure(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrIncorrectPassword
+	}
+
+	if err := s.policy.Validate(newPassword); err != nil {
+		return nil, passwordPolicyError(err, "new_password")
+	}
+
+	passwordHash, err := s.hasher.Hash(newPassword)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if username is being changed and already exists
-	if username != nil && *username != existingUser.Username {
-		if _, err := s.repo.GetByUsername(ctx, *username); err == nil {
-			return nil, errors.New("username already taken")
+	// The update only succeeds if the user was not changed since the password
+	// was checked
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkPasswordReuse(ctx, repos.PasswordHistory, s.policy, s.hasher, id, newPassword, "new_password"); err != nil {
+			return err
+		}
+
+		existingUser.SetPasswordHash(passwordHash)
+		if err := repos.Users.Update(ctx, existingUser); err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+		}
+
+		if err := recordPassword(ctx, repos.PasswordHistory, s.policy, id, passwordHash); err != nil {
+			return err
 		}
-		existingUser.Username = *username
+
+		return revokeAllSessions(ctx, repos, id)
+	})
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	return existingUser, nil
+}
+
+// DeleteUser soft deletes a user by ID and revokes all their sessions. The
+// user can be restored until it is purged. A non-zero expectedVersion makes
+// the delete conditional like in UpdateUser.
+func (s *UserService) DeleteUser(ctx context.Context, id, expectedVersion int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionDeleteUsers, id); err != nil {
+		return err
 	}
 
-	// Check if email is being changed and already exists
-	if email != nil && *email != existingUser.Email {
-		if _, err := s.repo.GetByEmail(ctx, *email); err == nil {
-			return nil, errors.New("email already registered")
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Users.Delete(ctx, id, expectedVersion); err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
 		}
-		existingUser.Email = *email
+
+		return revokeAllSessions(ctx, repos, id)
+	})
+	if err != nil {
+		return translateRepositoryError(err)
 	}
 
-	// Update password if provided
-	if password != nil {
-		hashedPassword, err := user.HashPassword(*password)
+	return nil
+}
+
+// RestoreUser restores a soft deleted user
+func (s *UserService) RestoreUser(ctx context.Context, id int64) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionDeleteUsers, id); err != nil {
+		return nil, err
+	}
+
+	var restoredUser *user.User
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		deletedUser, err := repos.Users.GetDeletedByID(ctx, id)
 		if err != nil {
-			return nil, err
+			return userNotFound(err, fmt.Sprintf("deleted user not found with ID %d", id))
+		}
+
+		// The username or email may have been reused while the user was
+		// deleted. Usernames are unique within the organization of the user.
+		orgCtx := tenant.NewContext(ctx, deletedUser.OrganizationID)
+		if err := checkUniqueFields(orgCtx, repos.Users, deletedUser.Username, deletedUser.Email, true, true); err != nil {
+			return err
 		}
-		existingUser.PasswordHash = hashedPassword
+
+		if err := repos.Users.Restore(ctx, id); err != nil {
+			return userNotFound(err, fmt.Sprintf("deleted user not found with ID %d", id))
+		}
+
+		restoredUser, err = repos.Users.GetByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	return restoredUser, nil
+}
+
+// PurgeUser permanently removes a soft deleted user
+func (s *UserService) PurgeUser(ctx context.Context, id int64) error {
+	if err := s.authz.Authorize(ctx, user.PermissionPurgeUsers, id); err != nil {
+		return err
+	}
+
+	_, err := s.repo.GetByID(ctx, id)
+	switch {
+	case err == nil:
+		return ErrUserNotDeleted
+	case !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+
+	if err := s.repo.Purge(ctx, id); err != nil {
+		return userNotFound(err, fmt.Sprintf("deleted user not found with ID %d", id))
 	}
 
-	// Update full name if provided
-	if fullName != nil {
-		existingUser.FullName = *fullName
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes users that were soft deleted longer
+// than the retention period ago and returns how many were removed
+func (s *UserService) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionPurgeUsers, 0); err != nil {
+		return 0, err
 	}
 
-	// Save changes
-	if err := s.repo.Update(ctx, existingUser); err != nil {
+	return s.repo.PurgeDeletedBefore(ctx, time.Now().UTC().Add(-retention))
+}
+
+// SuspendUser blocks a user from authenticating until they are reactivated
+// and ends all their sessions. The reason is recorded along with the caller.
+func (s *UserService) SuspendUser(ctx context.Context, id int64, reason string) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionSuspendUsers, id); err != nil {
 		return nil, err
 	}
 
-	return existingUser, nil
+	// Prevent admins from locking themselves out
+	if isCaller(ctx, id) {
+		return nil, ErrSuspendSelf
+	}
+
+	return s.changeStatus(ctx, id, reason, func(*user.User) user.Status { return user.StatusSuspended })
+}
+
+// DeactivateUser closes the account of a user and ends all their sessions.
+// Like the other status changes it is reserved to admins.
+func (s *UserService) DeactivateUser(ctx context.Context, id int64, reason string) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionSuspendUsers, id); err != nil {
+		return nil, err
+	}
+
+	// Prevent admins from locking themselves out
+	if isCaller(ctx, id) {
+		return nil, ErrSuspendSelf
+	}
+
+	return s.changeStatus(ctx, id, reason, func(*user.User) user.Status { return user.StatusDeactivated })
 }
 
-// DeleteUser deletes a user by ID
-func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
-	return s.repo.Delete(ctx, id)
+// ReactivateUser lets a suspended or deactivated user authenticate again.
+// Users who never verified their email address go back to pending.
+func (s *UserService) ReactivateUser(ctx context.Context, id int64, reason string) (*user.User, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionSuspendUsers, id); err != nil {
+		return nil, err
+	}
+
+	return s.changeStatus(ctx, id, reason, func(u *user.User) user.Status {
+		if u.CanAuthenticate() {
+			// Not blocked, so there is nothing to reactivate
+			return u.Status
+		}
+		return u.ActiveStatus()
+	})
+}
+
+// changeStatus moves a user to the status chosen by next, recording the
+// reason and the caller. Users who can no longer authenticate have all their
+// sessions ended.
+func (s *UserService) changeStatus(ctx context.Context, id int64, reason string, next func(*user.User) user.Status) (*user.User, error) {
+	var changedUser *user.User
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		u, err := repos.Users.GetByID(ctx, id)
+		if err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+		}
+
+		status := next(u)
+		if !u.Status.CanTransitionTo(status) {
+			return FailedPreconditionError("INVALID_STATUS_TRANSITION", fmt.Sprintf("cannot change status from %s to %s", u.Status, status))
+		}
+
+		now := time.Now().UTC()
+		u.Status = status
+		u.StatusReason = reason
+		u.StatusChangedAt = &now
+		u.StatusChangedBy = actorID(ctx)
+		if err := repos.Users.SetStatus(ctx, u); err != nil {
+			return userNotFound(err, fmt.Sprintf("user not found with ID %d", id))
+		}
+		changedUser = u
+
+		if u.CanAuthenticate() {
+			return nil
+		}
+		return revokeAllSessions(ctx, repos, id)
+	})
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	return changedUser, nil
 }
 
-// ListUsers retrieves a paginated list of users
-func (s *UserService) ListUsers(ctx context.Context, page, pageSize int) ([]*user.User, int, error) {
+// ListUsers retrieves a filtered, sorted and paginated list of users
+func (s *UserService) ListUsers(ctx context.Context, filter repository.UserFilter, page, pageSize int) ([]*user.User, int, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionListUsers, 0); err != nil {
+		return nil, 0, err
+	}
+
 	// Calculate offset
 	offset := (page - 1) * pageSize
 	if offset < 0 {
@@ -116,5 +531,140 @@ func (s *UserService) ListUsers(ctx context.Context, page, pageSize int) ([]*use
 		pageSize = 100
 	}
 
-	return s.repo.List(ctx, offset, pageSize)
+	users, total, err := s.repo.List(ctx, filter, offset, pageSize)
+	if err != nil {
+		return nil, 0, translateRepositoryError(err)
+	}
+	return users, total, nil
+}
+
+// UserPage is a page of users returned by ListUsersPage
+type UserPage struct {
+	Users []*user.User
+	// NextPageToken resumes the list after the last user, empty on the last page
+	NextPageToken string
+	// TotalCount is only set when it was requested
+	TotalCount *int
+}
+
+// ListUsersPage retrieves a filtered and sorted page of users using keyset
+// pagination. An empty page token starts from the first user.
+func (s *UserService) ListUsersPage(ctx context.Context, filter repository.UserFilter, pageToken string, pageSize int, withTotal bool) (*UserPage, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionListUsers, 0); err != nil {
+		return nil, err
+	}
+
+	// Ensure page size is reasonable
+	if pageSize <= 0 {
+		pageSize = 10
+	} else if pageSize > 100 {
+		pageSize = 100
+	}
+
+	var after *repository.UserCursor
+	if pageToken != "" {
+		cursor, err := decodePageToken(pageToken, filter)
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+	}
+
+	// Fetch one extra user to know whether there is a next page
+	users, err := s.repo.ListAfter(ctx, filter, after, pageSize+1)
+	if err != nil {
+		return nil, translateRepositoryError(err)
+	}
+
+	page := &UserPage{Users: users}
+	if len(users) > pageSize {
+		page.Users = users[:pageSize]
+		last := page.Users[pageSize-1]
+		page.NextPageToken, err = encodePageToken(filter, repository.NewUserCursor(last, filter.SortBy))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if withTotal {
+		total, err := s.repo.Count(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = &total
+	}
+
+	return page, nil
+}
+
+// GrantRole grants a role to a user and returns the user's resulting roles
+func (s *UserService) GrantRole(ctx context.Context, userID int64, roleName string) ([]user.Role, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageRoles, 0); err != nil {
+		return nil, err
+	}
+
+	role, err := user.ParseRole(roleName)
+	if err != nil {
+		return nil, InvalidArgumentError("UNKNOWN_ROLE", "role", err.Error(), err)
+	}
+
+	if err := s.checkRoleHolder(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.Grant(ctx, userID, role, actorID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.ListByUser(ctx, userID)
+}
+
+// RevokeRole revokes a role from a user and returns the user's remaining roles
+func (s *UserService) RevokeRole(ctx context.Context, userID int64, roleName string) ([]user.Role, error) {
+	if err := s.authz.Authorize(ctx, user.PermissionManageRoles, 0); err != nil {
+		return nil, err
+	}
+
+	role, err := user.ParseRole(roleName)
+	if err != nil {
+		return nil, InvalidArgumentError("UNKNOWN_ROLE", "role", err.Error(), err)
+	}
+
+	// Prevent admins from locking themselves out
+	if actor := actorID(ctx); actor != nil && *actor == userID && role == user.RoleAdmin {
+		return nil, ErrRevokeOwnAdmin
+	}
+
+	if err := s.checkRoleHolder(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.Revoke(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, NotFoundError("ROLE_NOT_GRANTED", fmt.Sprintf("user %d does not have role %q", userID, roleName), err)
+		}
+		return nil, err
+	}
+
+	return s.roleRepo.ListByUser(ctx, userID)
+}
+
+// checkRoleHolder checks that the user exists and may hold roles in the
+// organization ctx is scoped to: its own users and the members added to it
+// from other organizations
+func (s *UserService) checkRoleHolder(ctx context.Context, userID int64) error {
+	organizationID, _ := tenant.FromContext(ctx)
+	if organizationID == 0 {
+		_, err := s.repo.GetByID(ctx, userID)
+		return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+
+	// Members may belong to any organization
+	if _, err := s.repo.GetByID(tenant.NewContext(ctx, 0), userID); err != nil {
+		return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+	if _, err := s.orgRepo.GetMembership(ctx, organizationID, userID); err != nil {
+		return userNotFound(err, fmt.Sprintf("user not found with ID %d", userID))
+	}
+	return nil
 }
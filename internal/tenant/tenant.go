@@ -0,0 +1,19 @@
+// Package tenant carries the organization a request acts in through its
+// context, so that the data of one organization is never served to another.
+package tenant
+
+import "context"
+
+type organizationKey struct{}
+
+// NewContext returns a copy of ctx scoped to the organization
+func NewContext(ctx context.Context, organizationID int64) context.Context {
+	return context.WithValue(ctx, organizationKey{}, organizationID)
+}
+
+// FromContext returns the organization ctx is scoped to, if any. Contexts
+// without one, such as those of system callers, act on every organization.
+func FromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(organizationKey{}).(int64)
+	return id, ok
+}
@@ -0,0 +1,20 @@
+package user
+
+// PublicMethods lists the RPCs that can be called without an access token
+var PublicMethods = map[string]bool{
+	UserService_Login_FullMethodName:                true,
+	UserService_RefreshToken_FullMethodName:         true,
+	UserService_Logout_FullMethodName:               true,
+	UserService_VerifyEmail_FullMethodName:          true,
+	UserService_RequestPasswordReset_FullMethodName: true,
+	UserService_ConfirmPasswordReset_FullMethodName: true,
+	UserService_VerifyMFA_FullMethodName:            true,
+	UserService_AcceptInvitation_FullMethodName:     true,
+}
+
+// OptionalMethods lists the RPCs that can be called without an access token,
+// but act as the caller when one is sent. Users signing up on their own
+// join the default organization, while admins add users to theirs.
+var OptionalMethods = map[string]bool{
+	UserService_CreateUser_FullMethodName: true,
+}
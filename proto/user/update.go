@@ -0,0 +1,60 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UpdatedFields returns the new values of the fields the request changes, nil
+// meaning unchanged. With an update mask, listed fields are set to their value
+// in User, even when empty. Without one, the deprecated top-level fields are
+// used and empty values are ignored.
+func (m *UpdateUserRequest) UpdatedFields() (username, email, password, fullName *string, err error) {
+	if len(m.GetUpdateMask().GetPaths()) == 0 {
+		if m.Username != nil && *m.Username != "" {
+			username = m.Username
+		}
+		if m.Email != nil && *m.Email != "" {
+			email = m.Email
+		}
+		if m.Password != nil && *m.Password != "" {
+			password = m.Password
+		}
+		if m.FullName != nil && *m.FullName != "" {
+			fullName = m.FullName
+		}
+		return username, email, password, fullName, nil
+	}
+
+	values := m.GetUser()
+	if values == nil {
+		values = &UserUpdate{}
+	}
+
+	for _, path := range m.UpdateMask.Paths {
+		switch path {
+		case "username":
+			username = &values.Username
+		case "email":
+			email = &values.Email
+		case "password":
+			password = &values.Password
+		case "full_name":
+			fullName = &values.FullName
+		default:
+			return nil, nil, nil, nil, fmt.Errorf("invalid update_mask path %q", path)
+		}
+	}
+
+	// Only the full name may be cleared
+	switch {
+	case username != nil && *username == "":
+		return nil, nil, nil, nil, errors.New("username cannot be cleared")
+	case email != nil && *email == "":
+		return nil, nil, nil, nil, errors.New("email cannot be cleared")
+	case password != nil && *password == "":
+		return nil, nil, nil, nil, errors.New("password cannot be cleared")
+	}
+
+	return username, email, password, fullName, nil
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestAPIKeyService_APIKeys(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+
+	created, err := testSetup.UserService.CreateUser(ctx, "batchjob", "batch@example.com", "s3cret-passw0rd", "Batch Job")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// Only admins manage API keys
+	selfCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+	if _, _, err := testSetup.APIKeyService.CreateAPIKey(selfCtx, created.ID, "nightly", []string{"users.read"}, nil); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	var serviceErr *service.Error
+	if _, _, err := testSetup.APIKeyService.CreateAPIKey(adminCtx, created.ID, "nightly", []string{"users.everything"}, nil); !errors.As(err, &serviceErr) || serviceErr.Reason != "UNKNOWN_SCOPE" {
+		t.Errorf("Expected an unknown scope error, got %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	apiKey, key, err := testSetup.APIKeyService.CreateAPIKey(adminCtx, created.ID, "nightly", []string{"users.read"}, &expiresAt)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	if !strings.HasPrefix(key, user.APIKeyPrefix) || !strings.HasPrefix(key, apiKey.Prefix) {
+		t.Errorf("Expected key %q to start with prefix %q", key, apiKey.Prefix)
+	}
+
+	// The key acts as its user within its scopes
+	principal, err := testSetup.APIKeyService.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("Failed to authenticate API key: %v", err)
+	}
+	if principal.UserID != created.ID || principal.APIKeyID != apiKey.ID {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+	keyCtx := auth.NewContext(ctx, principal)
+	if _, err := testSetup.UserService.GetUser(keyCtx, created.ID); err != nil {
+		t.Errorf("Failed to get user with API key: %v", err)
+	}
+	fullName := "Renamed"
+	if _, err := testSetup.UserService.UpdateUser(keyCtx, created.ID, 0, nil, nil, nil, &fullName); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	if _, err := testSetup.APIKeyService.AuthenticateAPIKey(ctx, user.APIKeyPrefix+"unknown"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected error %v but got %v", auth.ErrInvalidToken, err)
+	}
+
+	keys, err := testSetup.APIKeyService.ListAPIKeys(adminCtx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to list API keys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != apiKey.ID || keys[0].LastUsedAt == nil {
+		t.Errorf("Expected the used key to be listed, got %+v", keys)
+	}
+
+	if err := testSetup.APIKeyService.RevokeAPIKey(adminCtx, created.ID, apiKey.ID); err != nil {
+		t.Fatalf("Failed to revoke API key: %v", err)
+	}
+	if _, err := testSetup.APIKeyService.AuthenticateAPIKey(ctx, key); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected error %v but got %v", auth.ErrInvalidToken, err)
+	}
+}
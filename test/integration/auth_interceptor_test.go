@@ -0,0 +1,167 @@
+package integration
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+	CleanupDatabase(t, testSetup.DB)
+
+	created, err := testSetup.UserService.CreateUser(ctx, "authuser", "authuser@example.com", "s3cret-passw0rd", "Auth User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	pair, err := testSetup.AuthService.Login(ctx, "", created.Username, "s3cret-passw0rd", "laptop")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	claims, err := testSetup.TokenManager.ParseAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+
+	// call runs the interceptor for a method with the given metadata and
+	// returns the principal the handler was called with
+	call := func(method string, md metadata.MD) (*auth.Principal, bool, error) {
+		var (
+			principal *auth.Principal
+			called    bool
+		)
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			principal, _ = auth.FromContext(ctx)
+			called = true
+			return nil, nil
+		}
+		_, err := testSetup.AuthInterceptor(metadata.NewIncomingContext(ctx, md), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return principal, called, err
+	}
+	bearer := func(token string) metadata.MD {
+		return metadata.Pairs("authorization", "Bearer "+token)
+	}
+
+	// Public methods are called anonymously
+	if principal, called, err := call(pb.UserService_Login_FullMethodName, nil); err != nil || !called || principal != nil {
+		t.Errorf("Expected an anonymous call to Login but got %v, %v, %v", principal, called, err)
+	}
+
+	// Optional methods authenticate the caller only when credentials are sent
+	if principal, called, err := call(pb.UserService_CreateUser_FullMethodName, nil); err != nil || !called || principal != nil {
+		t.Errorf("Expected an anonymous call to CreateUser but got %v, %v, %v", principal, called, err)
+	}
+	if _, _, err := call(pb.UserService_CreateUser_FullMethodName, bearer("not-a-token")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected code %v but got %v", codes.Unauthenticated, err)
+	}
+
+	expiredTokens := auth.NewTokenManager("test-secret", "project_maker_test", -time.Minute)
+	expired, err := expiredTokens.IssueAccessToken(created, claims.SessionID)
+	if err != nil {
+		t.Fatalf("Failed to issue expired token: %v", err)
+	}
+	mfaToken, err := testSetup.TokenManager.IssueMFAToken(created, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to issue MFA token: %v", err)
+	}
+	clientToken, err := testSetup.TokenManager.IssueClientAccessToken(created, claims.SessionID, "example-app", "openid")
+	if err != nil {
+		t.Fatalf("Failed to issue client access token: %v", err)
+	}
+
+	// Every other method needs valid credentials
+	rejected := map[string]metadata.MD{
+		"NoMetadata":        nil,
+		"NoAuthorization":   metadata.Pairs("x-request-id", "1"),
+		"BasicScheme":       metadata.Pairs("authorization", "Basic "+pair.AccessToken),
+		"InvalidToken":      bearer("not-a-token"),
+		"ExpiredToken":      bearer(expired),
+		"MFAToken":          bearer(mfaToken),
+		"ClientAccessToken": bearer(clientToken),
+		"InvalidAPIKey":     metadata.Pairs(auth.APIKeyHeader, "not-a-key"),
+	}
+	for name, md := range rejected {
+		t.Run(name, func(t *testing.T) {
+			_, called, err := call(pb.UserService_GetUser_FullMethodName, md)
+			if status.Code(err) != codes.Unauthenticated || called {
+				t.Errorf("Expected code %v but got %v", codes.Unauthenticated, err)
+			}
+		})
+	}
+
+	principal, _, err := call(pb.UserService_GetUser_FullMethodName, bearer(pair.AccessToken))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if principal.UserID != created.ID || principal.SessionID != claims.SessionID || principal.OrganizationID != created.OrganizationID {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+
+	// API keys act as their user with their scopes
+	apiKey, key, err := testSetup.APIKeyService.CreateAPIKey(adminCtx, created.ID, "nightly", []string{"users.read"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	principal, _, err = call(pb.UserService_GetUser_FullMethodName, metadata.Pairs(auth.APIKeyHeader, key))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if principal.UserID != created.ID || principal.APIKeyID != apiKey.ID || len(principal.Scopes) != 1 {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+
+	// Callers only select organizations they are members of
+	owner, err := testSetup.UserService.CreateUser(ctx, "authowner", "authowner@example.com", "s3cret-passw0rd", "Auth Owner")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if _, err := testSetup.UserService.GrantRole(adminCtx, owner.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+	ownerCtx := auth.NewContext(ctx, &auth.Principal{UserID: owner.ID, Username: owner.Username, OrganizationID: owner.OrganizationID})
+	acme, err := testSetup.OrganizationService.CreateOrganization(ownerCtx, "acme", "Acme")
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	ownerPair, err := testSetup.AuthService.Login(ctx, "", owner.Username, "s3cret-passw0rd", "laptop")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	selecting := func(token, organization string) metadata.MD {
+		return metadata.Join(bearer(token), metadata.Pairs(auth.OrganizationHeader, organization))
+	}
+	acmeID := strconv.FormatInt(acme.ID, 10)
+	if _, _, err := call(pb.UserService_GetUser_FullMethodName, selecting(pair.AccessToken, "acme")); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected code %v but got %v", codes.InvalidArgument, err)
+	}
+	if _, _, err := call(pb.UserService_GetUser_FullMethodName, selecting(pair.AccessToken, acmeID)); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected code %v but got %v", codes.PermissionDenied, err)
+	}
+	principal, _, err = call(pb.UserService_GetUser_FullMethodName, selecting(ownerPair.AccessToken, acmeID))
+	if err != nil || principal.OrganizationID != acme.ID {
+		t.Errorf("Expected the owner to act in acme but got %v, %v", principal, err)
+	}
+
+	// Tokens of revoked sessions are rejected
+	if err := testSetup.SessionService.RevokeSession(adminCtx, created.ID, claims.SessionID); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	_, called, err := call(pb.UserService_GetUser_FullMethodName, bearer(pair.AccessToken))
+	if status.Code(err) != codes.Unauthenticated || called {
+		t.Errorf("Expected code %v for a revoked session but got %v", codes.Unauthenticated, err)
+	}
+}
@@ -0,0 +1,296 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_Login(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	// Create a test user first
+	_, err := testSetup.UserService.CreateUser(ctx, "loginuser", "login@example.com", "s3cret-passw0rd", "Login User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		wantErr  error
+	}{
+		{
+			name:     "ByUsername",
+			login:    "loginuser",
+			password: "s3cret-passw0rd",
+		},
+		{
+			name:     "ByEmail",
+			login:    "login@example.com",
+			password: "s3cret-passw0rd",
+		},
+		{
+			name:     "WrongPassword",
+			login:    "loginuser",
+			password: "wrongpassword",
+			wantErr:  service.ErrInvalidCredentials,
+		},
+		{
+			name:     "UnknownUser",
+			login:    "nobody",
+			password: "s3cret-passw0rd",
+			wantErr:  service.ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := testSetup.AuthService.Login(ctx, "", tt.login, tt.password, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected error %v but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if pair.AccessToken == "" {
+				t.Error("Expected access token to be set")
+			}
+			if pair.RefreshToken == "" {
+				t.Error("Expected refresh token to be set")
+			}
+			if pair.User.Username != "loginuser" {
+				t.Errorf("Expected username %q but got %q", "loginuser", pair.User.Username)
+			}
+		})
+	}
+}
+
+func TestAuthService_RefreshToken(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	_, err := testSetup.UserService.CreateUser(ctx, "refreshuser", "refresh@example.com", "s3cret-passw0rd", "Refresh User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "", "refreshuser", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	// Rotating the refresh token returns a new one
+	rotated, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Error("Expected refresh token to be rotated")
+	}
+
+	// Replaying the old token is rejected and revokes the whole family
+	if _, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(ctx, rotated.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected rotated token to be revoked after reuse, got %v", err)
+	}
+
+	// Logging out revokes the token
+	pair, err = testSetup.AuthService.Login(ctx, "", "refreshuser", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	if err := testSetup.AuthService.Logout(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("Failed to log out: %v", err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+}
+
+func TestAuthService_RehashLegacyPassword(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	created, err := testSetup.UserService.CreateUser(ctx, "legacyuser", "legacy@example.com", "s3cret-passw0rd", "Legacy User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if !strings.HasPrefix(created.PasswordHash, "$argon2id$") {
+		t.Fatalf("Expected an argon2id hash but got %q", created.PasswordHash)
+	}
+
+	// Simulate a user whose password was hashed before argon2id was introduced
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("s3cret-passw0rd"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if _, err := testSetup.DB.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", string(legacyHash), created.ID); err != nil {
+		t.Fatalf("Failed to store legacy hash: %v", err)
+	}
+
+	// A wrong password leaves the legacy hash alone
+	if _, err := testSetup.AuthService.Login(ctx, "", "legacyuser", "wrongpassword", ""); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidCredentials, err)
+	}
+
+	if _, err := testSetup.AuthService.Login(ctx, "", "legacyuser", "s3cret-passw0rd", ""); err != nil {
+		t.Fatalf("Failed to log in with legacy hash: %v", err)
+	}
+
+	rehashed, err := testSetup.UserService.GetUser(auth.SystemContext(ctx), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if !strings.HasPrefix(rehashed.PasswordHash, "$argon2id$") {
+		t.Errorf("Expected legacy hash to be replaced by an argon2id hash, got %q", rehashed.PasswordHash)
+	}
+	if rehashed.Version != created.Version {
+		t.Errorf("Expected rehashing to keep version %d but got %d", created.Version, rehashed.Version)
+	}
+
+	// The new hash still verifies the same password
+	if _, err := testSetup.AuthService.Login(ctx, "", "legacyuser", "s3cret-passw0rd", ""); err != nil {
+		t.Errorf("Failed to log in with rehashed password: %v", err)
+	}
+}
+
+func TestAuthService_Lockout(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := auth.NewClientContext(context.Background(), &auth.Client{IP: "198.51.100.1"})
+
+	created, err := testSetup.UserService.CreateUser(ctx, "lockeduser", "locked@example.com", "s3cret-passw0rd", "Locked User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// The third failed attempt locks the user
+	for i := 0; i < 3; i++ {
+		if _, err := testSetup.AuthService.Login(ctx, "", "lockeduser", "wrongpassword", ""); !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("Attempt %d: expected error %v but got %v", i+1, service.ErrInvalidCredentials, err)
+		}
+	}
+
+	// Even the right password is rejected while locked
+	_, err = testSetup.AuthService.Login(ctx, "", "lockeduser", "s3cret-passw0rd", "")
+	if !errors.Is(err, service.ErrAccountLocked) {
+		t.Fatalf("Expected error %v but got %v", service.ErrAccountLocked, err)
+	}
+	var serviceErr *service.Error
+	if !errors.As(err, &serviceErr) || serviceErr.RetryAfter <= 0 {
+		t.Errorf("Expected a retry delay, got %v", err)
+	}
+
+	// Only admins can unlock users
+	if _, err := testSetup.LockoutService.UnlockUser(auth.NewContext(ctx, &auth.Principal{UserID: created.ID}), created.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.LockoutService.UnlockUser(auth.SystemContext(ctx), created.ID); err != nil {
+		t.Fatalf("Failed to unlock user: %v", err)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "lockeduser", "s3cret-passw0rd", ""); err != nil {
+		t.Errorf("Failed to log in after unlock: %v", err)
+	}
+
+	// Guessing across accounts locks the client IP
+	attackerCtx := auth.NewClientContext(context.Background(), &auth.Client{IP: "203.0.113.7"})
+	for i := 0; i < 10; i++ {
+		if _, err := testSetup.AuthService.Login(attackerCtx, "", "nobody", "wrongpassword", ""); !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("Attempt %d: expected error %v but got %v", i+1, service.ErrInvalidCredentials, err)
+		}
+	}
+	if _, err := testSetup.AuthService.Login(attackerCtx, "", "lockeduser", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrTooManyLoginAttempts) {
+		t.Errorf("Expected error %v but got %v", service.ErrTooManyLoginAttempts, err)
+	}
+
+	// Other clients are not affected
+	if _, err := testSetup.AuthService.Login(ctx, "", "lockeduser", "s3cret-passw0rd", ""); err != nil {
+		t.Errorf("Failed to log in from another address: %v", err)
+	}
+
+	// Wrong current passwords count toward the lockout too
+	userCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+	for i := 0; i < 3; i++ {
+		if _, err := testSetup.UserService.ChangePassword(userCtx, created.ID, "wrongpassword", "newpassword123"); !errors.Is(err, service.ErrIncorrectPassword) {
+			t.Fatalf("Attempt %d: expected error %v but got %v", i+1, service.ErrIncorrectPassword, err)
+		}
+	}
+	if _, err := testSetup.UserService.ChangePassword(userCtx, created.ID, "s3cret-passw0rd", "newpassword123"); !errors.Is(err, service.ErrAccountLocked) {
+		t.Errorf("Expected error %v but got %v", service.ErrAccountLocked, err)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "lockeduser", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrAccountLocked) {
+		t.Errorf("Expected error %v but got %v", service.ErrAccountLocked, err)
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	created, err := testSetup.UserService.CreateUser(ctx, "changer", "changer@example.com", "s3cret-passw0rd", "Password Changer")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "", "changer", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	userCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+
+	// Users cannot set their password without proving they know the current one
+	newPassword := "newpassword123"
+	if _, err := testSetup.UserService.UpdateUser(userCtx, created.ID, 0, nil, nil, &newPassword, nil); !errors.Is(err, service.ErrPasswordUpdateDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPasswordUpdateDenied, err)
+	}
+	if _, err := testSetup.UserService.ChangePassword(userCtx, created.ID, "wrongpassword", newPassword); !errors.Is(err, service.ErrIncorrectPassword) {
+		t.Errorf("Expected error %v but got %v", service.ErrIncorrectPassword, err)
+	}
+
+	changed, err := testSetup.UserService.ChangePassword(userCtx, created.ID, "s3cret-passw0rd", newPassword)
+	if err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+	if changed.PasswordChangedAt == nil || !changed.PasswordChangedAt.After(*created.PasswordChangedAt) {
+		t.Errorf("Expected password change time to move forward, got %v", changed.PasswordChangedAt)
+	}
+
+	// The new password is in effect and existing sessions are revoked
+	if _, err := testSetup.AuthService.Login(ctx, "", "changer", newPassword, ""); err != nil {
+		t.Errorf("Failed to log in with the new password: %v", err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+}
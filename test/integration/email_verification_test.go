@@ -0,0 +1,73 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestEmailVerification(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := auth.SystemContext(context.Background())
+
+	created, err := testSetup.UserService.CreateUser(ctx, "verifyme", "verifyme@example.com", "s3cret-passw0rd", "Verify Me")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if created.IsEmailVerified() {
+		t.Fatal("Expected new user to have an unverified email")
+	}
+
+	// Creating a user emails a verification token
+	token := testSetup.LastMailedToken(t)
+
+	verified, err := testSetup.VerificationService.VerifyEmail(ctx, token)
+	if err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+	if !verified.IsEmailVerified() {
+		t.Error("Expected email to be verified")
+	}
+
+	// Tokens are single-use
+	if _, err := testSetup.VerificationService.VerifyEmail(ctx, token); !errors.Is(err, service.ErrInvalidVerificationToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidVerificationToken, err)
+	}
+	if err := testSetup.VerificationService.ResendVerification(ctx, created.ID); !errors.Is(err, service.ErrEmailAlreadyVerified) {
+		t.Errorf("Expected error %v but got %v", service.ErrEmailAlreadyVerified, err)
+	}
+
+	// Changing the email resets the verification and sends a new token
+	newEmail := "verified@example.com"
+	updated, err := testSetup.UserService.UpdateUser(ctx, created.ID, 0, nil, &newEmail, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	if updated.IsEmailVerified() {
+		t.Error("Expected changed email to be unverified")
+	}
+	changeToken := testSetup.LastMailedToken(t)
+
+	// Resending invalidates the previous token
+	if err := testSetup.VerificationService.ResendVerification(ctx, created.ID); err != nil {
+		t.Fatalf("Failed to resend verification: %v", err)
+	}
+	resentToken := testSetup.LastMailedToken(t)
+	if _, err := testSetup.VerificationService.VerifyEmail(ctx, changeToken); !errors.Is(err, service.ErrInvalidVerificationToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidVerificationToken, err)
+	}
+
+	verified, err = testSetup.VerificationService.VerifyEmail(ctx, resentToken)
+	if err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+	if !verified.IsEmailVerified() || verified.Email != newEmail {
+		t.Errorf("Expected %s to be verified, got %s verified at %v", newEmail, verified.Email, verified.EmailVerifiedAt)
+	}
+}
@@ -0,0 +1,201 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestGroupService_NestedGroups(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+	CleanupDatabase(t, testSetup.DB)
+
+	created, err := testSetup.UserService.CreateUser(ctx, "grouped", "grouped@example.com", "s3cret-passw0rd", "Grouped User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// Only admins manage groups
+	selfCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+	if _, err := testSetup.GroupService.CreateGroup(selfCtx, "engineering", ""); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	groups := map[string]*user.Group{}
+	for _, name := range []string{"engineering", "backend", "api"} {
+		group, err := testSetup.GroupService.CreateGroup(adminCtx, name, "")
+		if err != nil {
+			t.Fatalf("Failed to create group %q: %v", name, err)
+		}
+		groups[name] = group
+	}
+	if _, err := testSetup.GroupService.CreateGroup(adminCtx, "api", ""); !errors.Is(err, service.ErrGroupNameTaken) {
+		t.Errorf("Expected error %v but got %v", service.ErrGroupNameTaken, err)
+	}
+
+	// engineering > backend > api
+	if err := testSetup.GroupService.AddSubgroup(adminCtx, groups["engineering"].ID, groups["backend"].ID); err != nil {
+		t.Fatalf("Failed to nest group: %v", err)
+	}
+	if err := testSetup.GroupService.AddSubgroup(adminCtx, groups["backend"].ID, groups["api"].ID); err != nil {
+		t.Fatalf("Failed to nest group: %v", err)
+	}
+	if err := testSetup.GroupService.AddUser(adminCtx, groups["api"].ID, created.ID); err != nil {
+		t.Fatalf("Failed to add user to group: %v", err)
+	}
+
+	// Groups can contain neither themselves nor the groups containing them
+	if err := testSetup.GroupService.AddSubgroup(adminCtx, groups["api"].ID, groups["api"].ID); !errors.Is(err, service.ErrGroupCycle) {
+		t.Errorf("Expected error %v but got %v", service.ErrGroupCycle, err)
+	}
+	if err := testSetup.GroupService.AddSubgroup(adminCtx, groups["api"].ID, groups["engineering"].ID); !errors.Is(err, service.ErrGroupCycle) {
+		t.Errorf("Expected error %v but got %v", service.ErrGroupCycle, err)
+	}
+
+	// Users are members of the groups containing their groups, and can see them
+	effective, err := testSetup.GroupService.ListUserGroups(selfCtx, created.ID, false)
+	if err != nil {
+		t.Fatalf("Failed to list user groups: %v", err)
+	}
+	if len(effective) != 3 {
+		t.Errorf("Expected 3 effective groups but got %d", len(effective))
+	}
+	direct, err := testSetup.GroupService.ListUserGroups(selfCtx, created.ID, true)
+	if err != nil {
+		t.Fatalf("Failed to list user groups: %v", err)
+	}
+	if len(direct) != 1 || direct[0].ID != groups["api"].ID {
+		t.Errorf("Expected only the api group but got %d groups", len(direct))
+	}
+
+	users, subgroups, err := testSetup.GroupService.ListMembers(adminCtx, groups["backend"].ID)
+	if err != nil {
+		t.Fatalf("Failed to list group members: %v", err)
+	}
+	if len(users) != 0 || len(subgroups) != 1 || subgroups[0].ID != groups["api"].ID {
+		t.Errorf("Expected only the api group in backend but got %d users and %d groups", len(users), len(subgroups))
+	}
+
+	// Removing a nested group removes its members from the containing groups
+	if err := testSetup.GroupService.RemoveSubgroup(adminCtx, groups["backend"].ID, groups["api"].ID); err != nil {
+		t.Fatalf("Failed to remove nested group: %v", err)
+	}
+	if err := testSetup.GroupService.RemoveSubgroup(adminCtx, groups["backend"].ID, groups["api"].ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	effective, err = testSetup.GroupService.ListUserGroups(adminCtx, created.ID, false)
+	if err != nil {
+		t.Fatalf("Failed to list user groups: %v", err)
+	}
+	if len(effective) != 1 {
+		t.Errorf("Expected 1 effective group but got %d", len(effective))
+	}
+
+	if err := testSetup.GroupService.DeleteGroup(adminCtx, groups["api"].ID); err != nil {
+		t.Fatalf("Failed to delete group: %v", err)
+	}
+	if _, err := testSetup.GroupService.GetGroup(adminCtx, groups["api"].ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+}
+
+func TestGroupService_Roles(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+	CleanupDatabase(t, testSetup.DB)
+
+	analyst, err := testSetup.UserService.CreateUser(ctx, "analyst", "analyst@example.com", "s3cret-passw0rd", "Analyst")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	analystCtx := auth.NewContext(ctx, &auth.Principal{UserID: analyst.ID, Username: analyst.Username, OrganizationID: analyst.OrganizationID})
+
+	// The analyst is in the support group through a nested group
+	supportGroup, err := testSetup.GroupService.CreateGroup(adminCtx, "support", "")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	tier1, err := testSetup.GroupService.CreateGroup(adminCtx, "tier1", "")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := testSetup.GroupService.AddSubgroup(adminCtx, supportGroup.ID, tier1.ID); err != nil {
+		t.Fatalf("Failed to nest group: %v", err)
+	}
+	if err := testSetup.GroupService.AddUser(adminCtx, tier1.ID, analyst.ID); err != nil {
+		t.Fatalf("Failed to add user to group: %v", err)
+	}
+	if _, _, err := testSetup.UserService.ListUsers(analystCtx, repository.UserFilter{}, 1, 10); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	// Only admins grant roles to groups
+	if _, err := testSetup.GroupService.GrantRole(analystCtx, supportGroup.ID, "support"); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	var serviceErr *service.Error
+	if _, err := testSetup.GroupService.GrantRole(adminCtx, supportGroup.ID, "superuser"); !errors.As(err, &serviceErr) || serviceErr.Reason != "UNKNOWN_ROLE" {
+		t.Errorf("Expected an UNKNOWN_ROLE error but got %v", err)
+	}
+	roles, err := testSetup.GroupService.GrantRole(adminCtx, supportGroup.ID, "support")
+	if err != nil {
+		t.Fatalf("Failed to grant role to group: %v", err)
+	}
+	if len(roles) != 1 || roles[0] != user.RoleSupport {
+		t.Errorf("Expected the support role but got %v", roles)
+	}
+
+	// Roles of a group apply to the members of its nested groups
+	if _, _, err := testSetup.UserService.ListUsers(analystCtx, repository.UserFilter{}, 1, 10); err != nil {
+		t.Errorf("Expected the support role of the group to let the analyst list users but got %v", err)
+	}
+
+	roles, err = testSetup.GroupService.RevokeRole(adminCtx, supportGroup.ID, "support")
+	if err != nil {
+		t.Fatalf("Failed to revoke role from group: %v", err)
+	}
+	if len(roles) != 0 {
+		t.Errorf("Expected no roles left but got %v", roles)
+	}
+	if _, _, err := testSetup.UserService.ListUsers(analystCtx, repository.UserFilter{}, 1, 10); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.GroupService.RevokeRole(adminCtx, supportGroup.ID, "support"); !errors.As(err, &serviceErr) || serviceErr.Reason != "ROLE_NOT_GRANTED" {
+		t.Errorf("Expected a ROLE_NOT_GRANTED error but got %v", err)
+	}
+
+	// Admins cannot revoke the admin role they only hold through a group
+	admins, err := testSetup.GroupService.CreateGroup(adminCtx, "admins", "")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := testSetup.GroupService.AddUser(adminCtx, admins.ID, analyst.ID); err != nil {
+		t.Fatalf("Failed to add user to group: %v", err)
+	}
+	if _, err := testSetup.GroupService.GrantRole(adminCtx, admins.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant role to group: %v", err)
+	}
+	if _, err := testSetup.GroupService.RevokeRole(analystCtx, admins.ID, "admin"); !errors.Is(err, service.ErrRevokeOwnAdmin) {
+		t.Errorf("Expected error %v but got %v", service.ErrRevokeOwnAdmin, err)
+	}
+	if _, err := testSetup.UserService.GrantRole(analystCtx, analyst.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+	if _, err := testSetup.GroupService.RevokeRole(analystCtx, admins.ID, "admin"); err != nil {
+		t.Errorf("Failed to revoke admin role held directly too: %v", err)
+	}
+}
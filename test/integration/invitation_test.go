@@ -0,0 +1,117 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestInvitationService_AcceptInvitation(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+	CleanupDatabase(t, testSetup.DB)
+
+	if _, err := testSetup.InvitationService.CreateInvitation(adminCtx, "invitee@example.com", []string{"superuser"}); err == nil {
+		t.Error("Expected unknown roles to be rejected")
+	}
+
+	// Only the latest invitation of an email can be accepted
+	if _, err := testSetup.InvitationService.CreateInvitation(adminCtx, "invitee@example.com", nil); err != nil {
+		t.Fatalf("Failed to create invitation: %v", err)
+	}
+	staleToken := testSetup.LastMailedToken(t)
+	invitation, err := testSetup.InvitationService.CreateInvitation(adminCtx, "invitee@example.com", []string{"support"})
+	if err != nil {
+		t.Fatalf("Failed to create invitation: %v", err)
+	}
+	token := testSetup.LastMailedToken(t)
+
+	if _, err := testSetup.InvitationService.AcceptInvitation(ctx, staleToken, "invitee", "s3cret-passw0rd", "Invited User"); !errors.Is(err, service.ErrInvalidInvitation) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidInvitation, err)
+	}
+
+	// A rejected password leaves the invitation pending
+	if _, err := testSetup.InvitationService.AcceptInvitation(ctx, token, "invitee", "short", "Invited User"); err == nil {
+		t.Error("Expected the password policy to be enforced")
+	}
+
+	// A failed account creation leaves the invitation pending too
+	if _, err := testSetup.UserService.CreateUser(ctx, "taken", "taken@example.com", "s3cret-passw0rd", "Taken"); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if _, err := testSetup.InvitationService.AcceptInvitation(ctx, token, "taken", "s3cret-passw0rd", "Invited User"); err == nil {
+		t.Error("Expected a taken username to be rejected")
+	}
+
+	invitee, err := testSetup.InvitationService.AcceptInvitation(ctx, token, "invitee", "s3cret-passw0rd", "Invited User")
+	if err != nil {
+		t.Fatalf("Failed to accept invitation: %v", err)
+	}
+	if invitee.Email != "invitee@example.com" {
+		t.Errorf("Expected email %q but got %q", "invitee@example.com", invitee.Email)
+	}
+	// The invitation was sent to the address, which proves the invitee owns it
+	if !invitee.IsEmailVerified() || invitee.Status != user.StatusActive {
+		t.Errorf("Expected a verified and active user but got status %q", invitee.Status)
+	}
+	if _, err := testSetup.InvitationService.AcceptInvitation(ctx, token, "invitee2", "s3cret-passw0rd", "Invited User"); !errors.Is(err, service.ErrInvalidInvitation) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidInvitation, err)
+	}
+
+	// The invitee holds the roles of the invitation and can log in
+	inviteeCtx := auth.NewContext(ctx, &auth.Principal{UserID: invitee.ID, Username: invitee.Username})
+	if _, _, err := testSetup.UserService.ListUsers(inviteeCtx, repository.UserFilter{}, 1, 10); err != nil {
+		t.Errorf("Expected the invitee to list users with the support role, got %v", err)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "invitee", "s3cret-passw0rd", ""); err != nil {
+		t.Errorf("Failed to log in as the invitee: %v", err)
+	}
+
+	// Users cannot invite, and accepted invitations cannot be revoked
+	if _, err := testSetup.InvitationService.CreateInvitation(inviteeCtx, "other@example.com", nil); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if err := testSetup.InvitationService.RevokeInvitation(adminCtx, invitation.ID); !errors.Is(err, service.ErrInvitationAccepted) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvitationAccepted, err)
+	}
+
+	pending, err := testSetup.InvitationService.ListInvitations(adminCtx, true)
+	if err != nil {
+		t.Fatalf("Failed to list invitations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending invitations but got %d", len(pending))
+	}
+}
+
+func TestInvitationService_RevokeInvitation(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+	CleanupDatabase(t, testSetup.DB)
+
+	invitation, err := testSetup.InvitationService.CreateInvitation(adminCtx, "revoked@example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to create invitation: %v", err)
+	}
+	token := testSetup.LastMailedToken(t)
+
+	if err := testSetup.InvitationService.RevokeInvitation(adminCtx, invitation.ID); err != nil {
+		t.Fatalf("Failed to revoke invitation: %v", err)
+	}
+	if _, err := testSetup.InvitationService.AcceptInvitation(ctx, token, "revoked", "s3cret-passw0rd", "Revoked User"); !errors.Is(err, service.ErrInvalidInvitation) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidInvitation, err)
+	}
+}
@@ -0,0 +1,197 @@
+package integration
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestMFAService_TOTP(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	created, err := testSetup.UserService.CreateUser(ctx, "mfauser", "mfa@example.com", "s3cret-passw0rd", "MFA User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	selfCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+
+	// Nobody else can see the secret of a user
+	other, err := testSetup.UserService.CreateUser(ctx, "otheruser", "other@example.com", "s3cret-passw0rd", "Other User")
+	if err != nil {
+		t.Fatalf("Failed to create other user: %v", err)
+	}
+	otherCtx := auth.NewContext(ctx, &auth.Principal{UserID: other.ID, Username: other.Username})
+	if _, err := testSetup.MFAService.EnrollTOTP(otherCtx, created.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	enrollment, err := testSetup.MFAService.EnrollTOTP(selfCtx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to enroll TOTP: %v", err)
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("Failed to decode secret %q: %v", enrollment.Secret, err)
+	}
+
+	// An unconfirmed enrollment does not affect logins
+	pair, err := testSetup.AuthService.Login(ctx, "", "mfauser", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	if pair.AccessToken == "" || pair.MFAToken != "" {
+		t.Fatal("Expected tokens without MFA before TOTP is confirmed")
+	}
+
+	if _, _, err := testSetup.MFAService.ConfirmTOTP(selfCtx, created.ID, "000000"); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidMFACode, err)
+	}
+	enabled, recoveryCodes, err := testSetup.MFAService.ConfirmTOTP(selfCtx, created.ID, user.TOTPCode(secret, time.Now()))
+	if err != nil {
+		t.Fatalf("Failed to confirm TOTP: %v", err)
+	}
+	if !enabled.IsTOTPEnabled() {
+		t.Error("Expected TOTP to be enabled")
+	}
+	if len(recoveryCodes) != user.RecoveryCodeCount {
+		t.Errorf("Expected %d recovery codes but got %d", user.RecoveryCodeCount, len(recoveryCodes))
+	}
+
+	// The password alone no longer yields tokens
+	pair, err = testSetup.AuthService.Login(ctx, "", "mfauser", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	if pair.MFAToken == "" || pair.AccessToken != "" {
+		t.Fatal("Expected only an MFA token")
+	}
+
+	// The MFA token is not an access token
+	if _, err := auth.NewTokenManager("test-secret", "project_maker_test", 15*time.Minute).ParseAccessToken(pair.MFAToken); err == nil {
+		t.Error("Expected MFA token to be rejected as an access token")
+	}
+
+	// The code used for the confirmation cannot be replayed
+	if _, err := testSetup.AuthService.VerifyMFA(ctx, pair.MFAToken, user.TOTPCode(secret, time.Now()), ""); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidMFACode, err)
+	}
+
+	// The code of the next time step is accepted to allow for clock drift
+	tokens, err := testSetup.AuthService.VerifyMFA(ctx, pair.MFAToken, user.TOTPCode(secret, time.Now().Add(user.TOTPPeriod)), "")
+	if err != nil {
+		t.Fatalf("Failed to verify TOTP code: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Error("Expected a token pair")
+	}
+
+	// Recovery codes work once
+	if _, err := testSetup.AuthService.VerifyMFA(ctx, pair.MFAToken, recoveryCodes[0], ""); err != nil {
+		t.Fatalf("Failed to verify recovery code: %v", err)
+	}
+	if _, err := testSetup.AuthService.VerifyMFA(ctx, pair.MFAToken, recoveryCodes[0], ""); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidMFACode, err)
+	}
+
+	// Users must give a code to disable their own TOTP
+	if _, err := testSetup.MFAService.DisableTOTP(selfCtx, created.ID, ""); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidMFACode, err)
+	}
+
+	// Wrong codes count toward the lockout, after the reused recovery code
+	if _, err := testSetup.MFAService.DisableTOTP(selfCtx, created.ID, "000000"); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidMFACode, err)
+	}
+	if _, err := testSetup.MFAService.DisableTOTP(selfCtx, created.ID, recoveryCodes[1]); !errors.Is(err, service.ErrAccountLocked) {
+		t.Errorf("Expected error %v but got %v", service.ErrAccountLocked, err)
+	}
+	if _, err := testSetup.LockoutService.UnlockUser(auth.SystemContext(ctx), created.ID); err != nil {
+		t.Fatalf("Failed to unlock user: %v", err)
+	}
+	disabled, err := testSetup.MFAService.DisableTOTP(selfCtx, created.ID, recoveryCodes[1])
+	if err != nil {
+		t.Fatalf("Failed to disable TOTP: %v", err)
+	}
+	if disabled.IsTOTPEnabled() {
+		t.Error("Expected TOTP to be disabled")
+	}
+
+	pair, err = testSetup.AuthService.Login(ctx, "", "mfauser", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	if pair.AccessToken == "" {
+		t.Error("Expected tokens after TOTP is disabled")
+	}
+}
+
+func TestMFAService_RequireAdmins(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	// Services requiring a second factor from admins
+	userRepo := repository.NewPostgresUserRepository(testSetup.DB)
+	roleRepo := repository.NewPostgresRoleRepository(testSetup.DB)
+	secretBox, err := auth.NewSecretBox("test-mfa-key")
+	if err != nil {
+		t.Fatalf("Failed to create secret box: %v", err)
+	}
+	mfaService := service.NewMFAService(userRepo, roleRepo, repository.NewPostgresUnitOfWork(testSetup.DB), secretBox, "project_maker_test", testSetup.LockoutService, true)
+	authService := service.NewAuthService(userRepo, repository.NewPostgresOrganizationRepository(testSetup.DB), repository.NewPostgresRefreshTokenRepository(testSetup.DB),
+		testSetup.SessionService, testSetup.TokenManager, user.DefaultPasswordHasher(), testSetup.LockoutService, mfaService, time.Hour, 5*time.Minute)
+
+	created, err := testSetup.UserService.CreateUser(ctx, "mfaadmin", "mfaadmin@example.com", "s3cret-passw0rd", "MFA Admin")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	pair, err := authService.Login(ctx, "", "mfaadmin", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Expected users other than admins to log in without TOTP but got %v", err)
+	}
+
+	// Admins without TOTP are refused tokens, even through sessions started before
+	if _, err := testSetup.UserService.GrantRole(auth.SystemContext(ctx), created.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+	if _, err := authService.Login(ctx, "", "mfaadmin", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrMFARequired) {
+		t.Errorf("Expected error %v but got %v", service.ErrMFARequired, err)
+	}
+	if _, err := authService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrMFARequired) {
+		t.Errorf("Expected error %v but got %v", service.ErrMFARequired, err)
+	}
+
+	// Enabling TOTP lets them log in with their second factor
+	selfCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+	enrollment, err := mfaService.EnrollTOTP(selfCtx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to enroll TOTP: %v", err)
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("Failed to decode secret %q: %v", enrollment.Secret, err)
+	}
+	if _, _, err := mfaService.ConfirmTOTP(selfCtx, created.ID, user.TOTPCode(secret, time.Now())); err != nil {
+		t.Fatalf("Failed to confirm TOTP: %v", err)
+	}
+	pair, err = authService.Login(ctx, "", "mfaadmin", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	if _, err := authService.VerifyMFA(ctx, pair.MFAToken, user.TOTPCode(secret, time.Now().Add(user.TOTPPeriod)), ""); err != nil {
+		t.Errorf("Failed to verify TOTP code: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/oidc"
+	"github.com/truongtu268/project_maker/internal/repository"
+)
+
+func TestOIDC_AuthorizationCodeFlow(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+
+	created, err := testSetup.UserService.CreateUser(ctx, "oidcuser", "oidc@example.com", "s3cret-passw0rd", "OIDC User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	const redirectURI = "https://app.example.com/callback"
+	client, secret, err := testSetup.OAuthService.CreateClient(adminCtx, "Example App", []string{redirectURI}, true)
+	if err != nil {
+		t.Fatalf("Failed to create OAuth client: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("Expected a secret for a confidential client")
+	}
+
+	// Admins of other organizations neither see nor delete the client
+	other, err := testSetup.OrganizationService.CreateOrganization(adminCtx, "other", "Other")
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	otherCtx := auth.NewContext(ctx, &auth.Principal{System: true, OrganizationID: other.ID})
+	if clients, err := testSetup.OAuthService.ListClients(otherCtx); err != nil || len(clients) != 0 {
+		t.Errorf("Expected no clients in another organization but got %d, %v", len(clients), err)
+	}
+	if err := testSetup.OAuthService.DeleteClient(otherCtx, client.ClientID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "", created.Username, "s3cret-passw0rd", "browser")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	// serve sends a request to the provider endpoints
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		testSetup.OIDCHandler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := serve(httptest.NewRequest(http.MethodGet, oidc.DiscoveryPath, nil))
+	var discovery map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &discovery); err != nil || discovery["issuer"] != testSetup.OAuthService.Issuer() {
+		t.Fatalf("Expected discovery document of the issuer, got %d: %s", rec.Code, rec.Body)
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid profile email"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	// Unknown redirect URIs are rejected without redirecting
+	badQuery := url.Values{"client_id": {client.ClientID}, "redirect_uri": {"https://evil.example.com/"}}
+	if rec := serve(httptest.NewRequest(http.MethodGet, oidc.AuthorizePath+"?"+badQuery.Encode(), nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unregistered redirect URI, got %d", rec.Code)
+	}
+
+	// Anonymous users are sent back with login_required
+	rec = serve(httptest.NewRequest(http.MethodGet, oidc.AuthorizePath+"?"+query.Encode(), nil))
+	if location := redirectParams(t, rec); location.Get("error") != "login_required" || location.Get("state") != "xyz" {
+		t.Errorf("Expected login_required error, got %v", location)
+	}
+
+	authorize := httptest.NewRequest(http.MethodGet, oidc.AuthorizePath+"?"+query.Encode(), nil)
+	authorize.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	location := redirectParams(t, serve(authorize))
+	code := location.Get("code")
+	if code == "" || location.Get("state") != "xyz" {
+		t.Fatalf("Expected an authorization code, got %v", location)
+	}
+
+	// exchange redeems the code with the verifier
+	exchange := func(codeVerifier string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {codeVerifier},
+		}
+		r := httptest.NewRequest(http.MethodPost, oidc.TokenPath, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth(client.ClientID, secret)
+		return serve(r)
+	}
+
+	if rec := exchange("wrong-verifier"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant for a wrong verifier, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = exchange(verifier)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected tokens, got %d: %s", rec.Code, rec.Body)
+	}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("Failed to decode token response: %v", err)
+	}
+
+	// The ID token is signed with the published key
+	claims := &auth.IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, claims, func(*jwt.Token) (interface{}, error) {
+		return testSetup.OAuthService.SigningKey().PublicKey(), nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience(client.ClientID), jwt.WithIssuer(testSetup.OAuthService.Issuer()))
+	if err != nil {
+		t.Fatalf("Failed to verify ID token: %v", err)
+	}
+	if claims.Subject != strconv.FormatInt(created.ID, 10) || claims.Nonce != "n-0S6_WzA2Mj" || claims.Email != created.Email {
+		t.Errorf("Unexpected ID token claims: %+v", claims)
+	}
+
+	// Codes are single use
+	if rec := exchange(verifier); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a used code to be rejected, got %d", rec.Code)
+	}
+
+	userInfo := httptest.NewRequest(http.MethodGet, oidc.UserInfoPath, nil)
+	userInfo.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	rec = serve(userInfo)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"preferred_username":"oidcuser"`) {
+		t.Errorf("Expected the user's claims, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// The access token of the client is limited to userinfo, and the access
+	// token of the user is not accepted there
+	accessClaims, err := testSetup.TokenManager.ParseClientAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to parse client access token: %v", err)
+	}
+	if accessClaims.ClientID != client.ClientID || accessClaims.Scope != "openid profile email" {
+		t.Errorf("Unexpected client access token claims: %+v", accessClaims)
+	}
+	if _, err := testSetup.TokenManager.ParseAccessToken(tokens.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected error %v but got %v", auth.ErrInvalidToken, err)
+	}
+	userInfo = httptest.NewRequest(http.MethodGet, oidc.UserInfoPath, nil)
+	userInfo.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	if rec := serve(userInfo); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for the access token of the user, got %d", rec.Code)
+	}
+}
+
+// redirectParams returns the query parameters of the redirect of a response
+func redirectParams(t *testing.T, rec *httptest.ResponseRecorder) url.Values {
+	t.Helper()
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect, got %d: %s", rec.Code, rec.Body)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect location: %v", err)
+	}
+	return location.Query()
+}
@@ -0,0 +1,295 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestOrganizationService_Tenancy(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	CleanupDatabase(t, testSetup.DB)
+
+	owner, err := testSetup.UserService.CreateUser(ctx, "alice", "alice@example.com", "s3cret-passw0rd", "Alice")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	ownerCtx := auth.NewContext(ctx, &auth.Principal{UserID: owner.ID, Username: owner.Username, OrganizationID: owner.OrganizationID})
+
+	// Only users allowed to create organizations may do so
+	if _, err := testSetup.OrganizationService.CreateOrganization(ownerCtx, "acme", "Acme"); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.UserService.GrantRole(auth.SystemContext(ctx), owner.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+
+	// The creator of an organization owns it
+	acme, err := testSetup.OrganizationService.CreateOrganization(ownerCtx, "acme", "Acme")
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	if _, err := testSetup.OrganizationService.CreateOrganization(ownerCtx, "acme", "Acme again"); !errors.Is(err, service.ErrSlugTaken) {
+		t.Errorf("Expected error %v but got %v", service.ErrSlugTaken, err)
+	}
+
+	orgs, err := testSetup.OrganizationService.ListOrganizations(ownerCtx)
+	if err != nil {
+		t.Fatalf("Failed to list organizations: %v", err)
+	}
+	if len(orgs) != 2 {
+		t.Errorf("Expected the default organization and acme but got %d organizations", len(orgs))
+	}
+
+	// Usernames are only unique within an organization
+	acmeAdminCtx := auth.NewContext(ctx, &auth.Principal{System: true, OrganizationID: acme.ID})
+	other, err := testSetup.UserService.CreateUser(acmeAdminCtx, "alice", "alice@acme.example.com", "s3cret-passw0rd", "Alice at Acme")
+	if err != nil {
+		t.Fatalf("Failed to create user with the same username in another organization: %v", err)
+	}
+	if other.OrganizationID != acme.ID {
+		t.Errorf("Expected user in organization %d but got %d", acme.ID, other.OrganizationID)
+	}
+
+	// Email addresses stay unique across organizations
+	var serviceErr *service.Error
+	_, err = testSetup.UserService.CreateUser(acmeAdminCtx, "bob", "alice@example.com", "s3cret-passw0rd", "Bob")
+	if !errors.As(err, &serviceErr) || serviceErr.Reason != "EMAIL_TAKEN" {
+		t.Errorf("Expected an EMAIL_TAKEN error but got %v", err)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "acme", "alice@example.com", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidCredentials, err)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "acme", "alice", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in to acme: %v", err)
+	}
+	if pair.User.ID != other.ID {
+		t.Errorf("Expected to log in as user %d but got %d", other.ID, pair.User.ID)
+	}
+	pair, err = testSetup.AuthService.Login(ctx, "", "alice", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in to the default organization: %v", err)
+	}
+	if pair.User.ID != owner.ID {
+		t.Errorf("Expected to log in as user %d but got %d", owner.ID, pair.User.ID)
+	}
+
+	// Admins of one organization never see the users of another
+	outsider, err := testSetup.UserService.CreateUser(ctx, "carol", "carol@example.com", "s3cret-passw0rd", "Carol")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if _, err := testSetup.UserService.GrantRole(acmeAdminCtx, other.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+	otherCtx := auth.NewContext(ctx, &auth.Principal{UserID: other.ID, Username: other.Username, OrganizationID: acme.ID})
+	if _, err := testSetup.UserService.GetUser(otherCtx, owner.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	if _, err := testSetup.UserService.RevokeRole(otherCtx, outsider.ID, "self"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	users, total, err := testSetup.UserService.ListUsers(otherCtx, repository.UserFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].ID != other.ID {
+		t.Errorf("Expected only the users of acme but got %d of %d", len(users), total)
+	}
+
+	// Members act in an organization by selecting it, with their roles in it
+	ownerInAcmeCtx := auth.NewContext(ctx, &auth.Principal{UserID: owner.ID, Username: owner.Username, OrganizationID: acme.ID})
+	users, _, err = testSetup.UserService.ListUsers(ownerInAcmeCtx, repository.UserFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to list users as the owner of acme: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != other.ID {
+		t.Errorf("Expected only the users of acme but got %d", len(users))
+	}
+	if member, err := testSetup.OrganizationService.IsMember(ctx, acme.ID, owner.ID); err != nil || !member {
+		t.Errorf("Expected the owner to be a member of acme but got %v, %v", member, err)
+	}
+	if member, err := testSetup.OrganizationService.IsMember(ctx, owner.OrganizationID, other.ID); err != nil || member {
+		t.Errorf("Expected the user of acme not to be a member of the default organization but got %v, %v", member, err)
+	}
+
+	// Roles are granted within an organization
+	otherInDefaultCtx := auth.NewContext(ctx, &auth.Principal{UserID: other.ID, Username: other.Username, OrganizationID: owner.OrganizationID})
+	if _, _, err := testSetup.UserService.ListUsers(otherInDefaultCtx, repository.UserFilter{}, 1, 10); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.UserService.CreateUser(otherInDefaultCtx, "bob", "bob@example.com", "s3cret-passw0rd", "Bob"); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	// Only members see an organization and only owners and admins change it
+	if _, err := testSetup.OrganizationService.GetOrganization(otherCtx, owner.OrganizationID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	if _, err := testSetup.OrganizationService.GetOrganization(otherCtx, acme.ID); err != nil {
+		t.Errorf("Failed to get organization as a member: %v", err)
+	}
+	name := "Acme Corporation"
+	if _, err := testSetup.OrganizationService.UpdateOrganization(otherCtx, acme.ID, nil, &name); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	updated, err := testSetup.OrganizationService.UpdateOrganization(ownerCtx, acme.ID, nil, &name)
+	if err != nil {
+		t.Fatalf("Failed to update organization: %v", err)
+	}
+	if updated.Name != name || updated.Slug != "acme" {
+		t.Errorf("Unexpected organization %+v", updated)
+	}
+
+	// Organizations can only be deleted once they have no users left
+	if err := testSetup.OrganizationService.DeleteOrganization(ownerCtx, acme.ID); !errors.Is(err, service.ErrOrganizationNotEmpty) {
+		t.Errorf("Expected error %v but got %v", service.ErrOrganizationNotEmpty, err)
+	}
+	if err := testSetup.UserService.DeleteUser(acmeAdminCtx, other.ID, 0); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if err := testSetup.UserService.PurgeUser(acmeAdminCtx, other.ID); err != nil {
+		t.Fatalf("Failed to purge user: %v", err)
+	}
+	if err := testSetup.OrganizationService.DeleteOrganization(ownerCtx, acme.ID); err != nil {
+		t.Errorf("Failed to delete organization: %v", err)
+	}
+
+	if err := testSetup.OrganizationService.DeleteOrganization(auth.SystemContext(ctx), owner.OrganizationID); !errors.Is(err, service.ErrDefaultOrganization) {
+		t.Errorf("Expected error %v but got %v", service.ErrDefaultOrganization, err)
+	}
+}
+
+func TestOrganizationService_Members(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	CleanupDatabase(t, testSetup.DB)
+
+	newUser := func(username string) *user.User {
+		u, err := testSetup.UserService.CreateUser(ctx, username, username+"@example.com", "s3cret-passw0rd", username)
+		if err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
+		return u
+	}
+	userCtx := func(u *user.User, organizationID int64) context.Context {
+		return auth.NewContext(ctx, &auth.Principal{UserID: u.ID, Username: u.Username, OrganizationID: organizationID})
+	}
+
+	owner, bob, carol := newUser("alice"), newUser("bob"), newUser("carol")
+	if _, err := testSetup.UserService.GrantRole(auth.SystemContext(ctx), owner.ID, "admin"); err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+	ownerCtx := userCtx(owner, owner.OrganizationID)
+	acme, err := testSetup.OrganizationService.CreateOrganization(ownerCtx, "acme", "Acme")
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	ownerInAcmeCtx := userCtx(owner, acme.ID)
+
+	// Only the owners and admins of an organization add members to it
+	if _, err := testSetup.OrganizationService.AddMember(userCtx(bob, bob.OrganizationID), acme.ID, carol.Email, "member"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	var serviceErr *service.Error
+	if _, err := testSetup.OrganizationService.AddMember(ownerCtx, acme.ID, bob.Email, "guest"); !errors.As(err, &serviceErr) || serviceErr.Reason != "UNKNOWN_ORGANIZATION_ROLE" {
+		t.Errorf("Expected an UNKNOWN_ORGANIZATION_ROLE error but got %v", err)
+	}
+	if _, err := testSetup.OrganizationService.AddMember(ownerCtx, acme.ID, "nobody@example.com", "member"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	membership, err := testSetup.OrganizationService.AddMember(ownerCtx, acme.ID, bob.Email, "member")
+	if err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	if membership.UserID != bob.ID || membership.Role != user.OrganizationRoleMember {
+		t.Errorf("Unexpected membership %+v", membership)
+	}
+	bobInAcmeCtx := userCtx(bob, acme.ID)
+	if _, err := testSetup.OrganizationService.AddMember(bobInAcmeCtx, acme.ID, carol.Email, "member"); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	members, err := testSetup.OrganizationService.ListMembers(bobInAcmeCtx, acme.ID)
+	if err != nil {
+		t.Fatalf("Failed to list members: %v", err)
+	}
+	if len(members) != 2 || members[0].UserID != owner.ID || members[1].UserID != bob.ID {
+		t.Errorf("Expected the owner and bob to be members but got %d members", len(members))
+	}
+
+	// Members are granted roles in the organization by its admins
+	roles, err := testSetup.UserService.GrantRole(ownerInAcmeCtx, bob.ID, "support")
+	if err != nil {
+		t.Fatalf("Failed to grant role to member: %v", err)
+	}
+	if len(roles) != 1 || roles[0] != user.RoleSupport {
+		t.Errorf("Expected the support role in acme but got %v", roles)
+	}
+	if _, _, err := testSetup.UserService.ListUsers(userCtx(bob, bob.OrganizationID), repository.UserFilter{}, 1, 10); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected roles in acme not to apply to the default organization but got %v", err)
+	}
+	if _, err := testSetup.UserService.GrantRole(ownerInAcmeCtx, carol.ID, "support"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+
+	// Only owners make or change owners, and the last owner stays one
+	if _, err := testSetup.OrganizationService.AddMember(ownerCtx, acme.ID, bob.Email, "admin"); err != nil {
+		t.Fatalf("Failed to change role of member: %v", err)
+	}
+	if _, err := testSetup.OrganizationService.AddMember(bobInAcmeCtx, acme.ID, carol.Email, "owner"); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if err := testSetup.OrganizationService.RemoveMember(bobInAcmeCtx, acme.ID, owner.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.OrganizationService.AddMember(ownerCtx, acme.ID, owner.Email, "admin"); !errors.Is(err, service.ErrLastOwner) {
+		t.Errorf("Expected error %v but got %v", service.ErrLastOwner, err)
+	}
+	if err := testSetup.OrganizationService.RemoveMember(ownerCtx, acme.ID, owner.ID); !errors.Is(err, service.ErrLastOwner) {
+		t.Errorf("Expected error %v but got %v", service.ErrLastOwner, err)
+	}
+	if _, err := testSetup.OrganizationService.AddMember(bobInAcmeCtx, acme.ID, carol.Email, "member"); err != nil {
+		t.Errorf("Failed to add member as an admin: %v", err)
+	}
+
+	// Users stay members of their own organization
+	dave, err := testSetup.UserService.CreateUser(auth.NewContext(ctx, &auth.Principal{System: true, OrganizationID: acme.ID}), "dave", "dave@example.com", "s3cret-passw0rd", "Dave")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if err := testSetup.OrganizationService.RemoveMember(ownerCtx, acme.ID, dave.ID); !errors.Is(err, service.ErrHomeOrganization) {
+		t.Errorf("Expected error %v but got %v", service.ErrHomeOrganization, err)
+	}
+
+	// Removed members lose their roles in the organization
+	if err := testSetup.OrganizationService.RemoveMember(ownerCtx, acme.ID, bob.ID); err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	if member, err := testSetup.OrganizationService.IsMember(ctx, acme.ID, bob.ID); err != nil || member {
+		t.Errorf("Expected bob not to be a member of acme but got %v, %v", member, err)
+	}
+	if err := testSetup.OrganizationService.RemoveMember(ownerCtx, acme.ID, bob.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	if _, err := testSetup.OrganizationService.AddMember(ownerCtx, acme.ID, bob.Email, "member"); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	if _, err := testSetup.UserService.RevokeRole(ownerInAcmeCtx, bob.ID, "support"); !errors.As(err, &serviceErr) || serviceErr.Reason != "ROLE_NOT_GRANTED" {
+		t.Errorf("Expected a ROLE_NOT_GRANTED error but got %v", err)
+	}
+}
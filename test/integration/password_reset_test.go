@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestPasswordReset(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	_, err := testSetup.UserService.CreateUser(ctx, "forgetful", "forgetful@example.com", "s3cret-passw0rd", "Forgetful User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "", "forgetful", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	// Unknown emails are accepted without sending anything
+	mailed := testSetup.Mailbox.Len()
+	if err := testSetup.PasswordResetService.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("Expected reset for unknown email to succeed, got %v", err)
+	}
+	if testSetup.Mailbox.Len() != mailed {
+		t.Error("Expected no email for an unknown address")
+	}
+
+	// Only the latest requested token is valid
+	if err := testSetup.PasswordResetService.RequestPasswordReset(ctx, "forgetful@example.com"); err != nil {
+		t.Fatalf("Failed to request password reset: %v", err)
+	}
+	staleToken := testSetup.LastMailedToken(t)
+	if err := testSetup.PasswordResetService.RequestPasswordReset(ctx, "forgetful@example.com"); err != nil {
+		t.Fatalf("Failed to request password reset: %v", err)
+	}
+	token := testSetup.LastMailedToken(t)
+
+	if err := testSetup.PasswordResetService.ConfirmPasswordReset(ctx, staleToken, "newpassword123"); !errors.Is(err, service.ErrInvalidPasswordResetToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidPasswordResetToken, err)
+	}
+	if err := testSetup.PasswordResetService.ConfirmPasswordReset(ctx, token, "newpassword123"); err != nil {
+		t.Fatalf("Failed to reset password: %v", err)
+	}
+
+	// Tokens are single-use
+	if err := testSetup.PasswordResetService.ConfirmPasswordReset(ctx, token, "otherpassword123"); !errors.Is(err, service.ErrInvalidPasswordResetToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidPasswordResetToken, err)
+	}
+
+	// The new password replaces the old one and existing sessions are revoked
+	if _, err := testSetup.AuthService.Login(ctx, "", "forgetful", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidCredentials, err)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "forgetful", "newpassword123", ""); err != nil {
+		t.Errorf("Failed to log in with the new password: %v", err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+}
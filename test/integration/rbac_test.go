@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestUserService_RoleBasedAccess(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	alice, err := testSetup.UserService.CreateUser(ctx, "alice", "alice@example.com", "s3cret-passw0rd", "Alice")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	bob, err := testSetup.UserService.CreateUser(ctx, "bob", "bob@example.com", "s3cret-passw0rd", "Bob")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	aliceCtx := auth.NewContext(ctx, &auth.Principal{UserID: alice.ID, Username: alice.Username})
+
+	// A regular user can read and edit their own record
+	if _, err := testSetup.UserService.GetUser(aliceCtx, alice.ID); err != nil {
+		t.Errorf("Expected to read own record, got %v", err)
+	}
+	fullName := "Alice Updated"
+	if _, err := testSetup.UserService.UpdateUser(aliceCtx, alice.ID, 0, nil, nil, nil, &fullName); err != nil {
+		t.Errorf("Expected to update own record, got %v", err)
+	}
+
+	// ...but not anybody else's
+	if _, err := testSetup.UserService.GetUser(aliceCtx, bob.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if err := testSetup.UserService.DeleteUser(aliceCtx, bob.ID, 0); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, _, err := testSetup.UserService.ListUsers(aliceCtx, repository.UserFilter{}, 1, 10); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.UserService.GrantRole(aliceCtx, alice.ID, string(user.RoleAdmin)); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	// Requests without a principal are rejected
+	if _, err := testSetup.UserService.GetUser(ctx, alice.ID); !errors.Is(err, service.ErrUnauthenticated) {
+		t.Errorf("Expected error %v but got %v", service.ErrUnauthenticated, err)
+	}
+
+	// Once promoted to admin, the user can manage everyone
+	roles, err := testSetup.UserService.GrantRole(auth.SystemContext(ctx), alice.ID, string(user.RoleAdmin))
+	if err != nil {
+		t.Fatalf("Failed to grant admin role: %v", err)
+	}
+	if len(roles) != 2 {
+		t.Errorf("Expected 2 roles but got %v", roles)
+	}
+
+	if _, _, err := testSetup.UserService.ListUsers(aliceCtx, repository.UserFilter{}, 1, 10); err != nil {
+		t.Errorf("Expected admin to list users, got %v", err)
+	}
+	if _, err := testSetup.UserService.RevokeRole(aliceCtx, alice.ID, string(user.RoleAdmin)); !errors.Is(err, service.ErrRevokeOwnAdmin) {
+		t.Errorf("Expected error %v but got %v", service.ErrRevokeOwnAdmin, err)
+	}
+	if err := testSetup.UserService.DeleteUser(aliceCtx, bob.ID, 0); err != nil {
+		t.Errorf("Expected admin to delete user, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestSessionService_Sessions(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	created, err := testSetup.UserService.CreateUser(ctx, "sessionuser", "session@example.com", "s3cret-passw0rd", "Session User")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	selfCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+
+	laptopCtx := auth.NewClientContext(ctx, &auth.Client{IP: "198.51.100.1", UserAgent: "laptop-browser"})
+	laptop, err := testSetup.AuthService.Login(laptopCtx, "", "sessionuser", "s3cret-passw0rd", "Laptop")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	phoneCtx := auth.NewClientContext(ctx, &auth.Client{IP: "198.51.100.2", UserAgent: "phone-app"})
+	phone, err := testSetup.AuthService.Login(phoneCtx, "", "sessionuser", "s3cret-passw0rd", "Phone")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	sessions, err := testSetup.SessionService.ListSessions(selfCtx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	devices := map[string]string{}
+	for _, s := range sessions {
+		devices[s.Device] = s.IP + " " + s.UserAgent
+	}
+	if devices["Laptop"] != "198.51.100.1 laptop-browser" || devices["Phone"] != "198.51.100.2 phone-app" {
+		t.Errorf("Unexpected sessions %v", devices)
+	}
+
+	// Refreshing keeps the session
+	if laptop, err = testSetup.AuthService.RefreshToken(laptopCtx, laptop.RefreshToken); err != nil {
+		t.Fatalf("Failed to refresh tokens: %v", err)
+	}
+
+	var phoneID int64
+	for _, s := range sessions {
+		if s.Device == "Phone" {
+			phoneID = s.ID
+		}
+		active, err := testSetup.SessionService.IsSessionActive(ctx, created.ID, s.ID)
+		if err != nil || !active {
+			t.Errorf("Expected session %d to be active, got %v, %v", s.ID, active, err)
+		}
+	}
+
+	// Users cannot revoke the sessions of others
+	other, err := testSetup.UserService.CreateUser(ctx, "othersession", "othersession@example.com", "s3cret-passw0rd", "Other User")
+	if err != nil {
+		t.Fatalf("Failed to create other user: %v", err)
+	}
+	otherCtx := auth.NewContext(ctx, &auth.Principal{UserID: other.ID, Username: other.Username})
+	if err := testSetup.SessionService.RevokeSession(otherCtx, created.ID, phoneID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+
+	if err := testSetup.SessionService.RevokeSession(selfCtx, created.ID, phoneID); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	if active, err := testSetup.SessionService.IsSessionActive(ctx, created.ID, phoneID); err != nil || active {
+		t.Errorf("Expected revoked session to be inactive, got %v, %v", active, err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(phoneCtx, phone.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+	if err := testSetup.SessionService.RevokeSession(selfCtx, created.ID, phoneID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+
+	if err := testSetup.SessionService.RevokeAllSessions(selfCtx, created.ID); err != nil {
+		t.Fatalf("Failed to revoke all sessions: %v", err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(laptopCtx, laptop.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+	sessions, err = testSetup.SessionService.ListSessions(selfCtx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Expected no sessions, got %d", len(sessions))
+	}
+}
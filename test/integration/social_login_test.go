@@ -0,0 +1,229 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/oidc"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+const (
+	stubClientID     = "project-maker"
+	stubClientSecret = "stub-secret"
+)
+
+// stubAccount is the account a user logs in with at the stub issuer
+type stubAccount struct {
+	subject       string
+	email         string
+	emailVerified bool
+}
+
+// stubGrant is an authorization code issued by the stub issuer
+type stubGrant struct {
+	account   stubAccount
+	challenge string
+	nonce     string
+}
+
+// stubIssuer is a minimal OpenID Connect provider issuing ID tokens for
+// whichever account the test picks
+type stubIssuer struct {
+	*httptest.Server
+	key    *auth.SigningKey
+	mu     sync.Mutex
+	grants map[string]stubGrant
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+
+	key, err := auth.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to generate stub issuer key: %v", err)
+	}
+
+	stub := &stubIssuer{key: key, grants: map[string]stubGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.URL,
+			"authorization_endpoint": stub.URL + "/authorize",
+			"token_endpoint":         stub.URL + "/token",
+			"jwks_uri":               stub.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]auth.JSONWebKey{"keys": {key.JWK()}})
+	})
+	mux.HandleFunc("POST /token", stub.token)
+	stub.Server = httptest.NewServer(mux)
+	return stub
+}
+
+// authorize records that the account logged in for an authorization request
+// and returns the code to send back
+func (s *stubIssuer) authorize(t *testing.T, authURL string, account stubAccount) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil || !strings.HasPrefix(authURL, s.URL+"/authorize") {
+		t.Fatalf("Expected a redirect to the stub issuer, got %q", authURL)
+	}
+	query := u.Query()
+	if query.Get("client_id") != stubClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("Unexpected authorization request: %v", query)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code = "code-" + account.subject + "-" + query.Get("state")[:8]
+	s.grants[code] = stubGrant{account: account, challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	return code, query.Get("state")
+}
+
+// token exchanges a code for an ID token after checking the client and verifier
+func (s *stubIssuer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, _ := r.BasicAuth()
+	r.ParseForm()
+
+	s.mu.Lock()
+	grant, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if clientID != stubClientID || secret != stubClientSecret || !ok || user.CodeChallengeS256(r.PostForm.Get("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken, _ := s.key.SignIDToken(&auth.IDTokenClaims{
+		Nonce:         grant.nonce,
+		Email:         grant.account.email,
+		EmailVerified: &grant.account.emailVerified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.URL,
+			Subject:   grant.account.subject,
+			Audience:  jwt.ClaimStrings{stubClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "stub", "token_type": "Bearer", "id_token": idToken})
+}
+
+// socialLogin goes through a login at the stub issuer as a browser would and
+// returns the response of the callback
+func socialLogin(t *testing.T, handler http.Handler, stub *stubIssuer, account stubAccount) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/stub/login?device=Laptop", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider, got %d: %s", rec.Code, rec.Body)
+	}
+	code, state := stub.authorize(t, rec.Header().Get("Location"), account)
+
+	callback := httptest.NewRequest(http.MethodGet, "/auth/stub/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, callback)
+	return rec
+}
+
+func TestIdentityService_SocialLogin(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+
+	stub := newStubIssuer(t)
+	defer stub.Close()
+	provider := oidc.NewExternalProvider("stub", stub.URL, stubClientID, stubClientSecret, []string{"openid", "email"}, stub.Client())
+	handler := oidc.NewSocialLoginHandler(testSetup.IdentityService, []*oidc.ExternalProvider{provider}, "http://localhost:8081", "")
+
+	// The first login creates a user without a password
+	alice := stubAccount{subject: "alice-sub", email: "alice@corp.example", emailVerified: true}
+	rec := socialLogin(t, handler, stub, alice)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"access_token"`) {
+		t.Fatalf("Expected tokens, got %d: %s", rec.Code, rec.Body)
+	}
+
+	created, _, err := testSetup.UserService.ListUsers(adminCtx, repository.UserFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(created) != 1 || created[0].Email != alice.email || !created[0].IsEmailVerified() || created[0].HasPassword() {
+		t.Fatalf("Expected one verified passwordless user, got %+v", created)
+	}
+	aliceID := created[0].ID
+
+	// Later logins find the same user
+	if rec := socialLogin(t, handler, stub, alice); rec.Code != http.StatusOK {
+		t.Fatalf("Expected tokens on the second login, got %d: %s", rec.Code, rec.Body)
+	}
+	identities, err := testSetup.IdentityService.ListIdentities(adminCtx, aliceID)
+	if err != nil {
+		t.Fatalf("Failed to list identities: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Issuer != stub.URL || identities[0].Subject != alice.subject {
+		t.Fatalf("Expected the identity of the stub account, got %+v", identities)
+	}
+
+	// The only way a passwordless user signs in cannot be unlinked
+	if err := testSetup.IdentityService.UnlinkIdentity(adminCtx, aliceID, identities[0].ID); !errors.Is(err, service.ErrLastSignInMethod) {
+		t.Errorf("Expected ErrLastSignInMethod, got %v", err)
+	}
+
+	// An account is only linked to a user whose email address is verified
+	bob, err := testSetup.UserService.CreateUser(ctx, "bob", "bob@corp.example", "s3cret-passw0rd", "Bob")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	bobAccount := stubAccount{subject: "bob-sub", email: bob.Email, emailVerified: true}
+	if rec := socialLogin(t, handler, stub, bobAccount); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "identity_email_in_use") {
+		t.Errorf("Expected identity_email_in_use, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if _, err := testSetup.VerificationService.VerifyEmail(ctx, testSetup.LastMailedToken(t)); err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+	if rec := socialLogin(t, handler, stub, bobAccount); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the account to be linked, got %d: %s", rec.Code, rec.Body)
+	}
+	identities, err = testSetup.IdentityService.ListIdentities(adminCtx, bob.ID)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("Expected one identity linked to bob, got %+v (%v)", identities, err)
+	}
+
+	// Users with a password can unlink all their identities
+	if err := testSetup.IdentityService.UnlinkIdentity(adminCtx, bob.ID, identities[0].ID); err != nil {
+		t.Errorf("Failed to unlink identity: %v", err)
+	}
+
+	// Callbacks without the state cookie of the browser that started the login fail
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/stub/callback?code=x&state=y", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "invalid_login_state") {
+		t.Errorf("Expected invalid_login_state, got %d: %s", rec.Code, rec.Body)
+	}
+}
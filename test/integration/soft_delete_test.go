@@ -0,0 +1,91 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestUserService_SoftDelete(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := auth.SystemContext(context.Background())
+
+	created, err := testSetup.UserService.CreateUser(ctx, "softdelete", "softdelete@example.com", "s3cret-passw0rd", "Soft Delete")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// Active users cannot be purged
+	if err := testSetup.UserService.PurgeUser(ctx, created.ID); !errors.Is(err, service.ErrUserNotDeleted) {
+		t.Errorf("Expected error %v but got %v", service.ErrUserNotDeleted, err)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "", "softdelete", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	if err := testSetup.UserService.DeleteUser(ctx, created.ID, 0); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	// Deleted users are logged out everywhere
+	if _, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+	claims, err := testSetup.TokenManager.ParseAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+	if active, err := testSetup.SessionService.IsSessionActive(ctx, created.ID, claims.SessionID); err != nil || active {
+		t.Errorf("Expected the session to be revoked but got %v, %v", active, err)
+	}
+
+	// Deleted users are hidden from reads and lists
+	if _, err := testSetup.UserService.GetUser(ctx, created.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+	if _, total, err := testSetup.UserService.ListUsers(ctx, repository.UserFilter{}, 1, 10); err != nil || total != 0 {
+		t.Errorf("Expected no listed users, got total %d and error %v", total, err)
+	}
+
+	// The row is still there and can be restored
+	restored, err := testSetup.UserService.RestoreUser(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to restore user: %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("Expected restored user to have no deletion time, got %v", restored.DeletedAt)
+	}
+
+	// A username freed by a deletion can be reused, which blocks the restore
+	if err := testSetup.UserService.DeleteUser(ctx, created.ID, 0); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if _, err := testSetup.UserService.CreateUser(ctx, "softdelete", "other@example.com", "s3cret-passw0rd", "Other"); err != nil {
+		t.Fatalf("Expected username of deleted user to be reusable, got %v", err)
+	}
+	if _, err := testSetup.UserService.RestoreUser(ctx, created.ID); err == nil {
+		t.Error("Expected restore to fail while the username is taken")
+	}
+
+	// Purging removes the row for good
+	purged, err := testSetup.UserService.PurgeDeletedUsers(ctx, time.Hour)
+	if err != nil || purged != 0 {
+		t.Errorf("Expected recently deleted user to be retained, purged %d with error %v", purged, err)
+	}
+	if err := testSetup.UserService.PurgeUser(ctx, created.ID); err != nil {
+		t.Fatalf("Failed to purge user: %v", err)
+	}
+	if _, err := testSetup.UserService.RestoreUser(ctx, created.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected error %v but got %v", repository.ErrNotFound, err)
+	}
+}
@@ -1,12 +1,15 @@
 package integration
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"regexp"
 	"testing"
 	"time"
 
@@ -17,10 +20,17 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/grpcerr"
+	"github.com/truongtu268/project_maker/internal/mailer"
+	"github.com/truongtu268/project_maker/internal/oidc"
 	"github.com/truongtu268/project_maker/internal/repository"
 	"github.com/truongtu268/project_maker/internal/service"
 	pb "github.com/truongtu268/project_maker/proto/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -45,6 +55,7 @@ func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 			FullName:  user.FullName,
 			CreatedAt: user.CreatedAt.Format(time.RFC3339),
 			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
+			Version:   user.Version,
 		},
 	}, nil
 }
@@ -53,9 +64,6 @@ func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserResponse, error) {
 	user, err := s.userService.GetUser(ctx, req.Id)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			return nil, fmt.Errorf("user not found with ID %d", req.Id)
-		}
 		return nil, err
 	}
 
@@ -67,32 +75,20 @@ func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserR
 			FullName:  user.FullName,
 			CreatedAt: user.CreatedAt.Format(time.RFC3339),
 			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
+			Version:   user.Version,
 		},
 	}, nil
 }
 
 // UpdateUser implements the UpdateUser RPC method
 func (s *server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UserResponse, error) {
-	var username, email, password, fullName *string
-
-	if req.Username != nil && *req.Username != "" {
-		username = req.Username
-	}
-	if req.Email != nil && *req.Email != "" {
-		email = req.Email
-	}
-	if req.Password != nil && *req.Password != "" {
-		password = req.Password
-	}
-	if req.FullName != nil && *req.FullName != "" {
-		fullName = req.FullName
+	username, email, password, fullName, err := req.UpdatedFields()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	user, err := s.userService.UpdateUser(ctx, req.Id, username, email, password, fullName)
+	user, err := s.userService.UpdateUser(ctx, req.Id, req.ExpectedVersion, username, email, password, fullName)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			return nil, fmt.Errorf("user not found with ID %d", req.Id)
-		}
 		return nil, err
 	}
 
@@ -104,17 +100,15 @@ func (s *server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb
 			FullName:  user.FullName,
 			CreatedAt: user.CreatedAt.Format(time.RFC3339),
 			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
+			Version:   user.Version,
 		},
 	}, nil
 }
 
 // DeleteUser implements the DeleteUser RPC method
 func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
-	err := s.userService.DeleteUser(ctx, req.Id)
+	err := s.userService.DeleteUser(ctx, req.Id, req.ExpectedVersion)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			return nil, fmt.Errorf("user not found with ID %d", req.Id)
-		}
 		return nil, err
 	}
 
@@ -125,27 +119,63 @@ func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb
 
 // ListUsers implements the ListUsers RPC method
 func (s *server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
-	users, total, err := s.userService.ListUsers(ctx, int(req.Page), int(req.PageSize))
+	filter := repository.UserFilter{
+		UsernamePrefix: req.UsernamePrefix,
+		EmailPrefix:    req.EmailPrefix,
+		Query:          req.Query,
+		SortBy:         repository.UserSortField(req.SortBy),
+		SortDesc:       req.SortOrder == "desc",
+	}
+
+	if req.Page == 0 {
+		page, err := s.userService.ListUsersPage(ctx, filter, req.PageToken, int(req.PageSize), req.IncludeTotalCount)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &pb.ListUsersResponse{
+			Users:         toPBUsers(page.Users),
+			NextPageToken: page.NextPageToken,
+		}
+		if page.TotalCount != nil {
+			totalCount := int32(*page.TotalCount)
+			resp.TotalCount = &totalCount
+		}
+		return resp, nil
+	}
+
+	users, total, err := s.userService.ListUsers(ctx, filter, int(req.Page), int(req.PageSize))
 	if err != nil {
 		return nil, err
 	}
 
+	totalCount := int32(total)
+	return &pb.ListUsersResponse{
+		Users:      toPBUsers(users),
+		TotalCount: &totalCount,
+	}, nil
+}
+
+// toPBUsers converts domain users into their protobuf representation
+func toPBUsers(users []*user.User) []*pb.User {
 	var pbUsers []*pb.User
-	for _, user := range users {
+	for _, u := range users {
 		pbUsers = append(pbUsers, &pb.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			FullName:  user.FullName,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
+			Id:        u.ID,
+			Username:  u.Username,
+			Email:     u.Email,
+			FullName:  u.FullName,
+			CreatedAt: u.CreatedAt.Format(time.RFC3339),
+			UpdatedAt: u.UpdatedAt.Format(time.RFC3339),
+			Version:   u.Version,
 		})
 	}
+	return pbUsers
+}
 
-	return &pb.ListUsersResponse{
-		Users:      pbUsers,
-		TotalCount: int32(total),
-	}, nil
+// systemPrincipalInterceptor runs every request as the system principal
+func systemPrincipalInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(auth.SystemContext(ctx), req)
 }
 
 const bufSize = 1024 * 1024
@@ -161,7 +191,44 @@ type TestSetup struct {
 	GrpcServer  *grpc.Server
 	Conn        *grpc.ClientConn
 	UserService *service.UserService
-	Cleanup     func()
+	AuthService *service.AuthService
+	// TokenManager signs the access tokens of AuthService and OAuthService
+	TokenManager *auth.TokenManager
+	// AuthInterceptor is the authentication interceptor of the server, which
+	// the gRPC server of the tests replaces with a system principal
+	AuthInterceptor grpc.UnaryServerInterceptor
+	// VerificationService, PasswordResetService and InvitationService send
+	// their emails to Mailbox
+	VerificationService  *service.EmailVerificationService
+	PasswordResetService *service.PasswordResetService
+	Mailbox              *bytes.Buffer
+	// LockoutService locks users after 3 and client IPs after 10 failed logins
+	LockoutService *service.LockoutService
+	MFAService     *service.MFAService
+	SessionService *service.SessionService
+	APIKeyService  *service.APIKeyService
+	OAuthService   *service.OAuthService
+	// OIDCHandler serves the OpenID Connect provider endpoints of OAuthService
+	OIDCHandler         http.Handler
+	IdentityService     *service.IdentityService
+	OrganizationService *service.OrganizationService
+	GroupService        *service.GroupService
+	InvitationService   *service.InvitationService
+	Cleanup             func()
+}
+
+// mailedTokenPattern matches the opaque tokens sent in emails
+var mailedTokenPattern = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`)
+
+// LastMailedToken returns the token in the most recent email of the mailbox
+func (s *TestSetup) LastMailedToken(t *testing.T) string {
+	t.Helper()
+
+	tokens := mailedTokenPattern.FindAllString(s.Mailbox.String(), -1)
+	if len(tokens) == 0 {
+		t.Fatal("Expected an email with a token")
+	}
+	return tokens[len(tokens)-1]
 }
 
 // bufDialer is a helper function for bufconn
@@ -268,10 +335,43 @@ func SetupIntegrationTest(t *testing.T) *TestSetup {
 
 	// Set up repository and service layers
 	userRepo := repository.NewPostgresUserRepository(dbx)
-	userService := service.NewUserService(userRepo)
+	roleRepo := repository.NewPostgresRoleRepository(dbx)
+	refreshTokenRepo := repository.NewPostgresRefreshTokenRepository(dbx)
+	organizationRepo := repository.NewPostgresOrganizationRepository(dbx)
+	unitOfWork := repository.NewPostgresUnitOfWork(dbx)
+	tokenManager := auth.NewTokenManager("test-secret", "project_maker_test", 15*time.Minute)
+	mailbox := &bytes.Buffer{}
+	verificationService := service.NewEmailVerificationService(userRepo, roleRepo, unitOfWork, mailer.NewLogMailer(mailbox), time.Hour, "")
+	passwordPolicy := user.DefaultPasswordPolicy()
+	passwordHasher := user.DefaultPasswordHasher()
+	lockoutService := service.NewLockoutService(userRepo, roleRepo, repository.NewPostgresLoginThrottleRepository(dbx), &user.LockoutPolicy{
+		MaxAttempts:   3,
+		MaxIPAttempts: 10,
+		BaseDuration:  time.Minute,
+		MaxDuration:   time.Hour,
+		ResetAfter:    15 * time.Minute,
+	})
+	userService := service.NewUserService(userRepo, roleRepo, organizationRepo, unitOfWork, verificationService, passwordPolicy, passwordHasher, lockoutService)
+	passwordResetService := service.NewPasswordResetService(userRepo, unitOfWork, mailer.NewLogMailer(mailbox), time.Hour, "", passwordPolicy, passwordHasher)
+	secretBox, err := auth.NewSecretBox("test-mfa-key")
+	if err != nil {
+		t.Fatalf("Failed to create secret box: %v", err)
+	}
+	mfaService := service.NewMFAService(userRepo, roleRepo, unitOfWork, secretBox, "project_maker_test", lockoutService, false)
+	sessionService := service.NewSessionService(userRepo, roleRepo, repository.NewPostgresSessionRepository(dbx), unitOfWork)
+	apiKeyService := service.NewAPIKeyService(userRepo, roleRepo, repository.NewPostgresAPIKeyRepository(dbx))
+	authService := service.NewAuthService(userRepo, organizationRepo, refreshTokenRepo, sessionService, tokenManager, passwordHasher, lockoutService, mfaService, time.Hour, 5*time.Minute)
+	signingKey, err := auth.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	identityService := service.NewIdentityService(userRepo, roleRepo, repository.NewPostgresLinkedIdentityRepository(dbx), repository.NewPostgresExternalLoginStateRepository(dbx), unitOfWork, authService, 10*time.Minute)
+	organizationService := service.NewOrganizationService(userRepo, roleRepo, organizationRepo, unitOfWork)
+	oauthService := service.NewOAuthService(userRepo, roleRepo, repository.NewPostgresOAuthClientRepository(dbx), repository.NewPostgresAuthorizationCodeRepository(dbx), sessionService, tokenManager, signingKey, "http://localhost:8081", time.Minute, time.Hour)
 
-	// Setup gRPC server
-	grpcServer := grpc.NewServer()
+	// Setup gRPC server. Requests are treated as trusted internal calls so the
+	// tests can exercise every method without logging in first.
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(systemPrincipalInterceptor, grpcerr.UnaryServerInterceptor()))
 	pb.RegisterUserServiceServer(grpcServer, &server{userService: userService})
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
@@ -304,12 +404,28 @@ func SetupIntegrationTest(t *testing.T) *TestSetup {
 	}
 
 	setup := &TestSetup{
-		DB:          dbx,
-		GrpcClient:  client,
-		GrpcServer:  grpcServer,
-		Conn:        conn,
-		UserService: userService,
-		Cleanup:     cleanup,
+		DB:                   dbx,
+		GrpcClient:           client,
+		GrpcServer:           grpcServer,
+		Conn:                 conn,
+		UserService:          userService,
+		AuthService:          authService,
+		TokenManager:         tokenManager,
+		AuthInterceptor:      auth.UnaryServerInterceptor(tokenManager, sessionService, apiKeyService, organizationService, pb.PublicMethods, pb.OptionalMethods),
+		VerificationService:  verificationService,
+		PasswordResetService: passwordResetService,
+		Mailbox:              mailbox,
+		LockoutService:       lockoutService,
+		MFAService:           mfaService,
+		SessionService:       sessionService,
+		APIKeyService:        apiKeyService,
+		OAuthService:         oauthService,
+		OIDCHandler:          oidc.NewHandler(oauthService, tokenManager, sessionService, ""),
+		IdentityService:      identityService,
+		OrganizationService:  organizationService,
+		GroupService:         service.NewGroupService(userRepo, roleRepo, repository.NewPostgresGroupRepository(dbx), unitOfWork),
+		InvitationService:    service.NewInvitationService(userRepo, roleRepo, repository.NewPostgresInvitationRepository(dbx), unitOfWork, userService, mailer.NewLogMailer(mailbox), time.Hour, ""),
+		Cleanup:              cleanup,
 	}
 
 	// Only set docker resources if we're not in CI
@@ -325,9 +441,20 @@ func SetupIntegrationTest(t *testing.T) *TestSetup {
 func CleanupDatabase(t *testing.T, db *sqlx.DB) {
 	t.Helper()
 
-	// Clear all users from the database
+	// Clear all users and invitations from the database, then the
+	// organizations they left empty
 	_, err := db.Exec("DELETE FROM users")
 	if err != nil {
 		t.Fatalf("Failed to clean up database: %v", err)
 	}
+
+	_, err = db.Exec("DELETE FROM invitations")
+	if err != nil {
+		t.Fatalf("Failed to clean up database: %v", err)
+	}
+
+	_, err = db.Exec("DELETE FROM organizations WHERE slug <> 'default'")
+	if err != nil {
+		t.Fatalf("Failed to clean up database: %v", err)
+	}
 }
@@ -2,11 +2,21 @@ package integration
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
 	pb "github.com/truongtu268/project_maker/proto/user"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func TestUserService_CreateUser(t *testing.T) {
@@ -29,7 +39,7 @@ func TestUserService_CreateUser(t *testing.T) {
 			req: &pb.CreateUserRequest{
 				Username: "testuser",
 				Email:    "test@example.com",
-				Password: "password123",
+				Password: "s3cret-passw0rd",
 				FullName: "Test User",
 			},
 			wantErr:     false,
@@ -40,7 +50,7 @@ func TestUserService_CreateUser(t *testing.T) {
 			req: &pb.CreateUserRequest{
 				Username: "testuser",
 				Email:    "different@example.com",
-				Password: "password123",
+				Password: "s3cret-passw0rd",
 				FullName: "Test User 2",
 			},
 			wantErr:  true,
@@ -51,7 +61,7 @@ func TestUserService_CreateUser(t *testing.T) {
 			req: &pb.CreateUserRequest{
 				Username: "differentuser",
 				Email:    "test@example.com",
-				Password: "password123",
+				Password: "s3cret-passw0rd",
 				FullName: "Test User 3",
 			},
 			wantErr:  true,
@@ -62,7 +72,7 @@ func TestUserService_CreateUser(t *testing.T) {
 			req: &pb.CreateUserRequest{
 				Username: "",
 				Email:    "new@example.com",
-				Password: "password123",
+				Password: "s3cret-passw0rd",
 				FullName: "Test User 4",
 			},
 			wantErr: false,
@@ -122,7 +132,7 @@ func TestUserService_GetUser(t *testing.T) {
 	createResp, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
 		Username: "getuser",
 		Email:    "getuser@example.com",
-		Password: "password123",
+		Password: "s3cret-passw0rd",
 		FullName: "Get User",
 	})
 	if err != nil {
@@ -194,7 +204,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 	createResp, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
 		Username: "updateuser",
 		Email:    "updateuser@example.com",
-		Password: "password123",
+		Password: "s3cret-passw0rd",
 		FullName: "Update User",
 	})
 	if err != nil {
@@ -207,7 +217,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 	_, err = testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
 		Username: "anotheruser",
 		Email:    "another@example.com",
-		Password: "password123",
+		Password: "s3cret-passw0rd",
 		FullName: "Another User",
 	})
 	if err != nil {
@@ -335,7 +345,7 @@ func TestUserService_DeleteUser(t *testing.T) {
 	createResp, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
 		Username: "deleteuser",
 		Email:    "deleteuser@example.com",
-		Password: "password123",
+		Password: "s3cret-passw0rd",
 		FullName: "Delete User",
 	})
 	if err != nil {
@@ -407,6 +417,139 @@ func TestUserService_DeleteUser(t *testing.T) {
 	}
 }
 
+func TestUserService_UpdateUserMask(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	createResp, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
+		Username: "maskuser",
+		Email:    "maskuser@example.com",
+		Password: "s3cret-passw0rd",
+		FullName: "Mask User",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// Only the listed field changes, the other values in user are ignored
+	resp, err := testSetup.GrpcClient.UpdateUser(ctx, &pb.UpdateUserRequest{
+		Id:         createResp.User.Id,
+		User:       &pb.UserUpdate{Username: "ignored", Email: "maskuser2@example.com"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.User.Username != "maskuser" || resp.User.Email != "maskuser2@example.com" {
+		t.Errorf("Expected only the email to change, got %v", resp.User)
+	}
+
+	// A listed field without a value is cleared
+	resp, err = testSetup.GrpcClient.UpdateUser(ctx, &pb.UpdateUserRequest{
+		Id:         createResp.User.Id,
+		User:       &pb.UserUpdate{},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"full_name"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.User.FullName != "" {
+		t.Errorf("Expected full name to be cleared, got %q", resp.User.FullName)
+	}
+
+	// Unknown paths and clearing required fields are rejected
+	invalidMasks := map[string]*pb.UpdateUserRequest{
+		"UnknownPath": {
+			Id:         createResp.User.Id,
+			User:       &pb.UserUpdate{},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"version"}},
+		},
+		"ClearUsername": {
+			Id:         createResp.User.Id,
+			User:       &pb.UserUpdate{FullName: "Mask User"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"username", "full_name"}},
+		},
+		"ClearEmail": {
+			Id:         createResp.User.Id,
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}},
+		},
+	}
+	for name, req := range invalidMasks {
+		t.Run(name, func(t *testing.T) {
+			_, err := testSetup.GrpcClient.UpdateUser(ctx, req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Expected code %v but got %v", codes.InvalidArgument, err)
+			}
+		})
+	}
+
+	resp, err = testSetup.GrpcClient.GetUser(ctx, &pb.GetUserRequest{Id: createResp.User.Id})
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if resp.User.Username != "maskuser" || resp.User.FullName != "" {
+		t.Errorf("Expected rejected updates to change nothing, got %v", resp.User)
+	}
+}
+
+func TestUserService_VersionConflict(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	createResp, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
+		Username: "versionuser",
+		Email:    "versionuser@example.com",
+		Password: "s3cret-passw0rd",
+		FullName: "Version User",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	userID := createResp.User.Id
+	staleVersion := createResp.User.Version
+
+	// The first writer wins and bumps the version
+	fullName := "First Writer"
+	updateResp, err := testSetup.GrpcClient.UpdateUser(ctx, &pb.UpdateUserRequest{
+		Id:              userID,
+		FullName:        &fullName,
+		ExpectedVersion: staleVersion,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if updateResp.User.Version != staleVersion+1 {
+		t.Errorf("Expected version %d but got %d", staleVersion+1, updateResp.User.Version)
+	}
+
+	// A second writer holding the old version is rejected
+	fullName = "Second Writer"
+	_, err = testSetup.GrpcClient.UpdateUser(ctx, &pb.UpdateUserRequest{
+		Id:              userID,
+		FullName:        &fullName,
+		ExpectedVersion: staleVersion,
+	})
+	if err == nil || !strings.Contains(err.Error(), repository.ErrVersionConflict.Error()) {
+		t.Errorf("Expected version conflict but got %v", err)
+	}
+
+	// Deletes are conditional too
+	_, err = testSetup.GrpcClient.DeleteUser(ctx, &pb.DeleteUserRequest{Id: userID, ExpectedVersion: staleVersion})
+	if err == nil || !strings.Contains(err.Error(), repository.ErrVersionConflict.Error()) {
+		t.Errorf("Expected version conflict but got %v", err)
+	}
+	if _, err := testSetup.GrpcClient.DeleteUser(ctx, &pb.DeleteUserRequest{Id: userID, ExpectedVersion: updateResp.User.Version}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
 func TestUserService_ListUsers(t *testing.T) {
 	// Setup test environment
 	testSetup := SetupIntegrationTest(t)
@@ -424,7 +567,7 @@ func TestUserService_ListUsers(t *testing.T) {
 		_, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
 			Username: username,
 			Email:    email,
-			Password: "password123",
+			Password: "s3cret-passw0rd",
 			FullName: fmt.Sprintf("List User %d", i),
 		})
 		if err != nil {
@@ -499,8 +642,8 @@ func TestUserService_ListUsers(t *testing.T) {
 				t.Errorf("Expected %d users but got %d", tt.checkCount, len(resp.Users))
 			}
 
-			if resp.TotalCount != 5 {
-				t.Errorf("Expected total count 5 but got %d", resp.TotalCount)
+			if resp.GetTotalCount() != 5 {
+				t.Errorf("Expected total count 5 but got %d", resp.GetTotalCount())
 			}
 
 			if tt.checkFields && len(resp.Users) > 0 {
@@ -529,3 +672,314 @@ func TestUserService_ListUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_ListUsersFilters(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	// Clean up database first to ensure consistent state
+	CleanupDatabase(t, testSetup.DB)
+
+	for _, u := range []*pb.CreateUserRequest{
+		{Username: "alice", Email: "alice@example.com", Password: "s3cret-passw0rd", FullName: "Alice Smith"},
+		{Username: "bob", Email: "bob@corp.io", Password: "s3cret-passw0rd", FullName: "Bob Jones"},
+		{Username: "albert", Email: "albert@corp.io", Password: "s3cret-passw0rd", FullName: "Albert Smithers"},
+	} {
+		if _, err := testSetup.GrpcClient.CreateUser(ctx, u); err != nil {
+			t.Fatalf("Failed to create test user %s: %v", u.Username, err)
+		}
+	}
+
+	// Test cases
+	tests := []struct {
+		name      string
+		req       *pb.ListUsersRequest
+		wantUsers []string
+	}{
+		{
+			name:      "UsernamePrefix",
+			req:       &pb.ListUsersRequest{Page: 1, PageSize: 10, UsernamePrefix: "AL"},
+			wantUsers: []string{"alice", "albert"},
+		},
+		{
+			name:      "EmailPrefix",
+			req:       &pb.ListUsersRequest{Page: 1, PageSize: 10, EmailPrefix: "bob@"},
+			wantUsers: []string{"bob"},
+		},
+		{
+			name:      "Query",
+			req:       &pb.ListUsersRequest{Page: 1, PageSize: 10, Query: "smith"},
+			wantUsers: []string{"alice", "albert"},
+		},
+		{
+			name:      "QueryWildcardIsLiteral",
+			req:       &pb.ListUsersRequest{Page: 1, PageSize: 10, Query: "%"},
+			wantUsers: []string{},
+		},
+		{
+			name:      "SortByUsernameDesc",
+			req:       &pb.ListUsersRequest{Page: 1, PageSize: 10, SortBy: "username", SortOrder: "desc"},
+			wantUsers: []string{"bob", "alice", "albert"},
+		},
+		{
+			name:      "FilterAndSort",
+			req:       &pb.ListUsersRequest{Page: 1, PageSize: 10, EmailPrefix: "a", SortBy: "username"},
+			wantUsers: []string{"albert", "alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := testSetup.GrpcClient.ListUsers(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if int(resp.GetTotalCount()) != len(tt.wantUsers) {
+				t.Errorf("Expected total count %d but got %d", len(tt.wantUsers), resp.GetTotalCount())
+			}
+
+			got := make([]string, 0, len(resp.Users))
+			for _, user := range resp.Users {
+				got = append(got, user.Username)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantUsers, ",") {
+				t.Errorf("Expected users %v but got %v", tt.wantUsers, got)
+			}
+		})
+	}
+
+	// Unsupported sort fields are invalid arguments, not internal errors
+	var serviceErr *service.Error
+	_, _, err := testSetup.UserService.ListUsers(auth.SystemContext(ctx), repository.UserFilter{SortBy: "password_hash"}, 1, 10)
+	if !errors.As(err, &serviceErr) || serviceErr.Kind != service.KindInvalidArgument || serviceErr.Field != "sort_by" {
+		t.Errorf("Expected an invalid sort_by error but got %v", err)
+	}
+	_, err = testSetup.UserService.ListUsersPage(auth.SystemContext(ctx), repository.UserFilter{SortBy: "password_hash"}, "", 10, false)
+	if !errors.As(err, &serviceErr) || serviceErr.Kind != service.KindInvalidArgument || serviceErr.Field != "sort_by" {
+		t.Errorf("Expected an invalid sort_by error but got %v", err)
+	}
+}
+
+func TestUserService_ListUsersCursor(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	// Clean up database first to ensure consistent state
+	CleanupDatabase(t, testSetup.DB)
+
+	for i := 1; i <= 5; i++ {
+		_, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
+			Username: fmt.Sprintf("cursoruser%d", i),
+			Email:    fmt.Sprintf("cursoruser%d@example.com", i),
+			Password: "s3cret-passw0rd",
+			FullName: fmt.Sprintf("Cursor User %d", i),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create test user %d: %v", i, err)
+		}
+	}
+
+	// Walk every page, sorted by username in descending order
+	var got []string
+	req := &pb.ListUsersRequest{PageSize: 2, SortBy: "username", SortOrder: "desc", IncludeTotalCount: true}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages, still paging after %d", pages)
+		}
+
+		resp, err := testSetup.GrpcClient.ListUsers(ctx, req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.TotalCount == nil || *resp.TotalCount != 5 {
+			t.Errorf("Expected total count 5 but got %v", resp.TotalCount)
+		}
+		for _, user := range resp.Users {
+			got = append(got, user.Username)
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+
+	want := "cursoruser5,cursoruser4,cursoruser3,cursoruser2,cursoruser1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Expected users %s but got %v", want, got)
+	}
+
+	// The total count is only computed on request
+	resp, err := testSetup.GrpcClient.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.TotalCount != nil {
+		t.Errorf("Expected no total count but got %d", *resp.TotalCount)
+	}
+
+	// A token cannot be reused with another sort order
+	_, err = testSetup.GrpcClient.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 2, PageToken: resp.NextPageToken, SortBy: "email"})
+	if err == nil || !strings.Contains(err.Error(), "invalid page token") {
+		t.Errorf("Expected invalid page token error but got %v", err)
+	}
+}
+
+func TestUserService_ErrorDetails(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	req := &pb.CreateUserRequest{
+		Username: "detailsuser",
+		Email:    "detailsuser@example.com",
+		Password: "s3cret-passw0rd",
+		FullName: "Details User",
+	}
+	if _, err := testSetup.GrpcClient.CreateUser(ctx, req); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// A duplicate username is reported as AlreadyExists with details
+	req.Email = "other@example.com"
+	_, err := testSetup.GrpcClient.CreateUser(ctx, req)
+
+	st := status.Convert(err)
+	if st.Code() != codes.AlreadyExists {
+		t.Fatalf("Expected code %v but got %v", codes.AlreadyExists, st.Code())
+	}
+
+	var reason, field string
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.Reason
+		case *errdetails.BadRequest:
+			if len(d.FieldViolations) > 0 {
+				field = d.FieldViolations[0].Field
+			}
+		}
+	}
+	if reason != "USERNAME_TAKEN" {
+		t.Errorf("Expected reason USERNAME_TAKEN but got %q", reason)
+	}
+	if field != "username" {
+		t.Errorf("Expected field violation on username but got %q", field)
+	}
+
+	// Missing users are reported as NotFound
+	_, err = testSetup.GrpcClient.GetUser(ctx, &pb.GetUserRequest{Id: 999999})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected code %v but got %v", codes.NotFound, status.Code(err))
+	}
+}
+
+func TestUserService_ConcurrentCreateUser(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	// Concurrent signups for the same username
+	const attempts = 10
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
+				Username: "racer",
+				Email:    fmt.Sprintf("racer%d@example.com", i),
+				Password: "s3cret-passw0rd",
+				FullName: "Racer",
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	// Exactly one wins, the others are told the username is taken
+	created := 0
+	for err := range errs {
+		switch status.Code(err) {
+		case codes.OK:
+			created++
+		case codes.AlreadyExists:
+		default:
+			t.Errorf("Expected code %v but got %v", codes.AlreadyExists, err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected exactly 1 user to be created but got %d", created)
+	}
+}
+
+func TestUserService_PasswordPolicy(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+
+	// Passphrases with spaces and non-ASCII letters are accepted
+	created, err := testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
+		Username: "passphrase",
+		Email:    "passphrase@example.com",
+		Password: "mật khẩu rất dài",
+		FullName: "Passphrase User",
+	})
+	if err != nil {
+		t.Fatalf("Expected passphrase to be accepted, got %v", err)
+	}
+
+	// Common passwords are rejected with one violation per broken rule
+	_, err = testSetup.GrpcClient.CreateUser(ctx, &pb.CreateUserRequest{
+		Username: "weakuser",
+		Email:    "weakuser@example.com",
+		Password: "qwerty",
+		FullName: "Weak User",
+	})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("Expected code %v but got %v", codes.InvalidArgument, st.Code())
+	}
+
+	var reasons []string
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range d.FieldViolations {
+				if v.Field != "password" {
+					t.Errorf("Expected violation on password but got %q", v.Field)
+				}
+				reasons = append(reasons, v.Reason)
+			}
+		}
+	}
+	if strings.Join(reasons, ",") != "PASSWORD_TOO_SHORT,PASSWORD_TOO_COMMON" {
+		t.Errorf("Expected too short and too common violations but got %v", reasons)
+	}
+
+	// Recent passwords cannot be reused
+	newPassword := "another passphrase"
+	if _, err := testSetup.UserService.ChangePassword(auth.SystemContext(ctx), created.User.Id, "mật khẩu rất dài", newPassword); err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+	_, err = testSetup.UserService.ChangePassword(auth.SystemContext(ctx), created.User.Id, newPassword, "mật khẩu rất dài")
+
+	var serviceErr *service.Error
+	if !errors.As(err, &serviceErr) || len(serviceErr.Violations) != 1 || serviceErr.Violations[0].Reason != user.PasswordRuleReused {
+		t.Errorf("Expected reused password violation but got %v", err)
+	}
+}
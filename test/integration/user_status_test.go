@@ -0,0 +1,88 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/truongtu268/project_maker/internal/auth"
+	"github.com/truongtu268/project_maker/internal/domain/user"
+	"github.com/truongtu268/project_maker/internal/repository"
+	"github.com/truongtu268/project_maker/internal/service"
+)
+
+func TestUserService_AccountStatus(t *testing.T) {
+	// Setup test environment
+	testSetup := SetupIntegrationTest(t)
+	defer testSetup.Cleanup()
+
+	ctx := context.Background()
+	adminCtx := auth.SystemContext(ctx)
+	CleanupDatabase(t, testSetup.DB)
+
+	created, err := testSetup.UserService.CreateUser(ctx, "troublemaker", "troublemaker@example.com", "s3cret-passw0rd", "Trouble Maker")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if created.Status != user.StatusPending {
+		t.Errorf("Expected status %q but got %q", user.StatusPending, created.Status)
+	}
+
+	pair, err := testSetup.AuthService.Login(ctx, "", "troublemaker", "s3cret-passw0rd", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	// Suspended users cannot log in, and their sessions are ended
+	suspended, err := testSetup.UserService.SuspendUser(adminCtx, created.ID, "spam")
+	if err != nil {
+		t.Fatalf("Failed to suspend user: %v", err)
+	}
+	if suspended.Status != user.StatusSuspended || suspended.StatusReason != "spam" {
+		t.Errorf("Expected a suspended user with reason %q but got %q with %q", "spam", suspended.Status, suspended.StatusReason)
+	}
+	if _, err := testSetup.UserService.SuspendUser(adminCtx, created.ID, "spam"); err == nil {
+		t.Error("Expected suspending a suspended user to fail")
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "troublemaker", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrAccountSuspended) {
+		t.Errorf("Expected error %v but got %v", service.ErrAccountSuspended, err)
+	}
+	if _, err := testSetup.AuthService.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("Expected error %v but got %v", service.ErrInvalidRefreshToken, err)
+	}
+
+	users, total, err := testSetup.UserService.ListUsers(adminCtx, repository.UserFilter{Status: user.StatusSuspended}, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].ID != created.ID {
+		t.Errorf("Expected only the suspended user but got %d users", total)
+	}
+
+	// Users who never verified their email go back to pending
+	reactivated, err := testSetup.UserService.ReactivateUser(adminCtx, created.ID, "appeal accepted")
+	if err != nil {
+		t.Fatalf("Failed to reactivate user: %v", err)
+	}
+	if reactivated.Status != user.StatusPending {
+		t.Errorf("Expected status %q but got %q", user.StatusPending, reactivated.Status)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "troublemaker", "s3cret-passw0rd", ""); err != nil {
+		t.Errorf("Failed to log in after reactivation: %v", err)
+	}
+
+	// Only admins change the status of users, including their own
+	selfCtx := auth.NewContext(ctx, &auth.Principal{UserID: created.ID, Username: created.Username})
+	if _, err := testSetup.UserService.SuspendUser(selfCtx, created.ID, ""); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.UserService.DeactivateUser(selfCtx, created.ID, "leaving"); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("Expected error %v but got %v", service.ErrPermissionDenied, err)
+	}
+	if _, err := testSetup.UserService.DeactivateUser(adminCtx, created.ID, "leaving"); err != nil {
+		t.Fatalf("Failed to deactivate user: %v", err)
+	}
+	if _, err := testSetup.AuthService.Login(ctx, "", "troublemaker", "s3cret-passw0rd", ""); !errors.Is(err, service.ErrAccountDeactivated) {
+		t.Errorf("Expected error %v but got %v", service.ErrAccountDeactivated, err)
+	}
+}